@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/auth"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/crbanman/aws-otp-auth/pkg/notify"
+	"github.com/spf13/pflag"
+
+	awsCredentials "github.com/aws/aws-sdk-go-v2/credentials"
+	awsIam "github.com/aws/aws-sdk-go-v2/service/iam"
+	awsSts "github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// App holds the dependencies of the commands so that they can be run in tests
+// without touching the process's streams, environment or AWS.
+type App struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Environ returns the environment in the form "key=value".
+	Environ func() []string
+	// Now returns the current time.
+	Now func() time.Time
+	// Clients returns the AWS clients for the source profile's long-term credentials.
+	Clients func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error)
+	// CredentialsClient returns an STS client that signs requests with the given credentials.
+	CredentialsClient func(ctx context.Context, creds aws.Credentials, endpoints *endpointOptions) (aws.STSClient, error)
+	// RunCommand runs a prepared child process for "exec".
+	RunCommand func(cmd *exec.Cmd) error
+	// NotificationBus connects to the desktop notification service for "notify".
+	NotificationBus func(ctx context.Context) (notify.Notifier, error)
+}
+
+// IAMClient combines the IAM methods used by the commands.
+type IAMClient interface {
+	aws.IAMListMFADevicesClient
+	aws.IAMMFAEnrollClient
+	aws.IAMMFAResyncClient
+	aws.IAMAccessKeysClient
+	aws.IAMRoleRevokeClient
+}
+
+// AWSClients are the clients that act with the source profile's long-term credentials.
+type AWSClients struct {
+	STS auth.STSClient
+	IAM IAMClient
+	// RoleClient returns an STS client in the same region that signs with the given session.
+	RoleClient func(session *aws.SessionCredentials) aws.STSAssumeRoleClient
+}
+
+// newApp returns an App wired to the process and to AWS.
+func newApp() *App {
+	return &App{
+		Stdin:             os.Stdin,
+		Stdout:            os.Stdout,
+		Stderr:            os.Stderr,
+		Environ:           os.Environ,
+		Now:               time.Now,
+		Clients:           newAWSClients,
+		CredentialsClient: credentialsSTSClient,
+		RunCommand:        (*exec.Cmd).Run,
+		NotificationBus: func(ctx context.Context) (notify.Notifier, error) {
+			return notify.DialSessionBus(ctx, "aws-otp-auth")
+		},
+	}
+}
+
+// newAWSClients loads the AWS config for the source profile and creates its clients.
+func newAWSClients(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+	cfg, err := loadAWSConfig(ctx, profile, endpoints)
+	if err != nil {
+		return nil, newConfigError(fmt.Errorf("failed to load AWS config: %w", err))
+	}
+	return &AWSClients{
+		STS: endpoints.newSTSClient(cfg),
+		IAM: awsIam.NewFromConfig(cfg),
+		RoleClient: func(session *aws.SessionCredentials) aws.STSAssumeRoleClient {
+			return endpoints.newSTSClient(cfg, func(o *awsSts.Options) {
+				o.Credentials = awsCredentials.NewStaticCredentialsProvider(session.AccessKeyID, session.SecretAccessKey, session.SessionToken)
+			})
+		},
+	}, nil
+}
+
+// getenv returns the value of the environment variable key, or "" if it is not set.
+func (a *App) getenv(key string) string {
+	for _, kv := range a.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// credentialsClient returns a function that creates STS clients for stored credentials.
+func (a *App) credentialsClient(endpoints *endpointOptions) credentialsClientFunc {
+	return func(ctx context.Context, creds aws.Credentials) (aws.STSClient, error) {
+		return a.CredentialsClient(ctx, creds, endpoints)
+	}
+}
+
+// credentialsClientFunc returns an STS client that signs requests with the given credentials.
+type credentialsClientFunc func(ctx context.Context, creds aws.Credentials) (aws.STSClient, error)
+
+// command is a subcommand of the tool.
+type command struct {
+	name    string
+	summary string
+	run     func(a *App, ctx context.Context, args []string) error
+}
+
+// commands lists the subcommands in the order they are shown in the usage. It is
+// filled in by init because the help command refers back to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"login", "Refresh a profile's session with MFA (the default command)", (*App).runLogin},
+		{"logout", "Remove a profile's session, optionally revoking the role's sessions", (*App).runLogout},
+		{"status", "Show the stored profiles or the source user's access keys", (*App).runStatus},
+		{"env", "Print a profile's credentials as environment variables", (*App).runEnv},
+		{"exec", "Run a command with a profile's credentials in its environment", (*App).runExec},
+		{"batch", "Refresh several config profiles with one MFA code", (*App).runBatch},
+		{"notify", "Show desktop notifications before a session expires", (*App).runNotify},
+		{"prompt", "Print a profile's remaining session time for a shell prompt", (*App).runPrompt},
+		{"mfa", "Enroll, resync or list MFA devices", (*App).runMFA},
+		{"config", "Validate the config file", (*App).runConfig},
+		{"audit", "Show the authentication audit log", (*App).runAudit},
+		{"help", "Show this help", (*App).runHelp},
+	}
+}
+
+// commandNames returns the names of the subcommands.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// exitStatus is returned by a command that has already reported its outcome and
+// only needs to set the exit code.
+type exitStatus struct {
+	code int
+}
+
+func (e *exitStatus) Error() string { return fmt.Sprintf("exit status %d", e.code) }
+
+// Run runs the command named by args[0], or login if args[0] is not a command,
+// and returns the process exit code.
+func (a *App) Run(ctx context.Context, args []string) int {
+	// Until a command sets up logging from its flags, log warnings to stderr.
+	if _, err := (&logOptions{level: "warn", format: "text"}).setup(a.Stderr); err != nil {
+		printError(a.Stderr, err)
+		return exitCode(err)
+	}
+
+	cmd := commands[0]
+	if len(args) > 0 {
+		for _, c := range commands {
+			if c.name == args[0] {
+				cmd, args = c, args[1:]
+				break
+			}
+		}
+	}
+	err := cmd.run(a, ctx, args)
+	if errors.Is(err, pflag.ErrHelp) {
+		return exitOK
+	}
+	printError(a.Stderr, err)
+	return exitCode(err)
+}
+
+// runHelp implements the "help" subcommand.
+func (a *App) runHelp(ctx context.Context, args []string) error {
+	a.printUsage(a.Stdout)
+	return nil
+}
+
+// printUsage writes the list of commands and exit codes.
+func (a *App) printUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: aws-otp-auth [command] [flags] [config-profile]")
+	fmt.Fprintln(out, "\nCommands:")
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.name, c.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(out, "\nRun 'aws-otp-auth <command> --help' for the flags of a command.")
+	fmt.Fprintf(out, "\nExit codes: %d refreshed, %d error, %d config error, %d invalid OTP, %d AWS error, %d already valid (login --detailed-exitcode)\n",
+		exitOK, exitError, exitConfigError, exitBadOTP, exitAWSError, exitAlreadyValid)
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/crbanman/aws-otp-auth/pkg/notify"
+)
+
+const testMFAArn = "arn:aws:iam::123456789012:mfa/alice"
+
+// newTestApp returns an App that writes to out and fails any attempt to reach AWS.
+func newTestApp(out io.Writer) *App {
+	return &App{
+		Stdin:   strings.NewReader(""),
+		Stdout:  out,
+		Stderr:  io.Discard,
+		Environ: func() []string { return nil },
+		Now:     time.Now,
+		Clients: func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+			return nil, errors.New("unexpected AWS call")
+		},
+		CredentialsClient: func(ctx context.Context, creds aws.Credentials, endpoints *endpointOptions) (aws.STSClient, error) {
+			return nil, errors.New("unexpected AWS call")
+		},
+		RunCommand: func(cmd *exec.Cmd) error { return errors.New("unexpected command") },
+		NotificationBus: func(ctx context.Context) (notify.Notifier, error) {
+			return nil, errors.New("unexpected notification")
+		},
+	}
+}
+
+// writeCredentials replaces ~/.aws/credentials under home.
+func writeCredentials(t *testing.T, home, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(home, ".aws"), 0700); err != nil {
+		t.Fatalf("Failed to create .aws directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".aws", "credentials"), []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write credentials file: %v", err)
+	}
+}
+
+func TestAppRun_Login(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeCredentials(t, home, "[default-long-term]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = SECRET\n")
+
+	var out bytes.Buffer
+	app := newTestApp(&out)
+	var profiles []string
+	app.Clients = func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+		profiles = append(profiles, profile)
+		return &AWSClients{STS: &mockSTSCombinedClient{CheckValid: true, SessionTokenValid: true}, IAM: &fakeIAMClient{}}, nil
+	}
+	app.CredentialsClient = func(ctx context.Context, creds aws.Credentials, endpoints *endpointOptions) (aws.STSClient, error) {
+		return &mockSTSCombinedClient{CheckValid: true}, nil
+	}
+
+	// Login is the default command.
+	if code := app.Run(context.Background(), []string{"--otp", "123456", "--mfa-arn", testMFAArn, "--output", "json"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	var result authResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode JSON output: %v", err)
+	}
+	if result.Action != ActionRefreshed || result.Profile != "default" || result.Account != "123456789012" || result.MFAArn != testMFAArn {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if !slices.Equal(profiles, []string{"default-long-term"}) {
+		t.Errorf("Expected clients for default-long-term, got %v", profiles)
+	}
+
+	out.Reset()
+	if code := app.Run(context.Background(), []string{"login", "--otp", "123456", "--mfa-arn", testMFAArn, "--detailed-exitcode"}); code != exitAlreadyValid {
+		t.Errorf("Expected exit code %d for a valid session, got %d", exitAlreadyValid, code)
+	}
+}
+
+func TestAppRun_LoginFailureKeepsSession(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	expiration := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	writeCredentials(t, home, "[default-long-term]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = SECRET\n"+sessionSection("default", expiration))
+
+	app := newTestApp(&bytes.Buffer{})
+	app.Clients = func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+		return &AWSClients{STS: &mockSTSCombinedClient{SessionTokenError: errors.New("throttled")}, IAM: &fakeIAMClient{}}, nil
+	}
+	if code := app.Run(context.Background(), []string{"--otp", "123456", "--mfa-arn", testMFAArn}); code == exitOK {
+		t.Fatalf("Expected the login to fail")
+	}
+
+	// The expired session is kept whole rather than losing its token but not its key.
+	creds, err := aws.ReadAWSCredentials("default")
+	if err != nil {
+		t.Fatalf("Failed to read the profile: %v", err)
+	}
+	if creds.AccessKeyID != "ASIAEXAMPLE" || creds.SessionToken != "TOKEN" || !creds.Expiration.Equal(expiration) {
+		t.Errorf("Expected the expired session to be left intact, got %+v", creds)
+	}
+}
+
+func TestAppRun_LoginSettingsFromEnvironment(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeCredentials(t, home, "")
+
+	var stderr bytes.Buffer
+	app := newTestApp(io.Discard)
+	app.Stderr = &stderr
+	app.Environ = func() []string { return []string{"AWS_OTP_AUTH_PROFILE_FROM=ci-long-term"} }
+	var profile string
+	app.Clients = func(ctx context.Context, p string, endpoints *endpointOptions) (*AWSClients, error) {
+		profile = p
+		return nil, newConfigError(errors.New("no such profile"))
+	}
+
+	if code := app.Run(context.Background(), []string{"--otp", "123456"}); code != exitConfigError {
+		t.Errorf("Expected exit code %d, got %d", exitConfigError, code)
+	}
+	if profile != "ci-long-term" {
+		t.Errorf("Expected source profile from the environment, got %q", profile)
+	}
+	if !strings.Contains(stderr.String(), "no such profile") {
+		t.Errorf("Expected the error on stderr, got %q", stderr.String())
+	}
+}
+
+func TestAppRun_Help(t *testing.T) {
+	var out bytes.Buffer
+	app := newTestApp(&out)
+	if code := app.Run(context.Background(), []string{"help"}); code != exitOK {
+		t.Errorf("Expected exit code %d, got %d", exitOK, code)
+	}
+	for _, name := range commandNames() {
+		if !strings.Contains(out.String(), "  "+name+" ") {
+			t.Errorf("Expected %s in usage:\n%s", name, out.String())
+		}
+	}
+	if code := app.Run(context.Background(), []string{"env", "--help"}); code != exitOK {
+		t.Errorf("Expected exit code %d for --help, got %d", exitOK, code)
+	}
+	if code := app.Run(context.Background(), []string{"--bogus"}); code != exitConfigError {
+		t.Errorf("Expected exit code %d for an unknown flag, got %d", exitConfigError, code)
+	}
+}
+
+func TestAppRun_Env(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	writeCredentials(t, home, `[default]
+aws_access_key_id = ASIAEXAMPLE
+aws_secret_access_key = it's-secret
+aws_session_token = TOKEN
+aws_session_token_expiration = 2025-03-01T13:00:00Z
+`)
+
+	var out bytes.Buffer
+	app := newTestApp(&out)
+	app.Now = func() time.Time { return now }
+	if code := app.Run(context.Background(), []string{"env"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	want := `export AWS_ACCESS_KEY_ID='ASIAEXAMPLE'
+export AWS_SECRET_ACCESS_KEY='it'\''s-secret'
+export AWS_SESSION_TOKEN='TOKEN'
+export AWS_CREDENTIAL_EXPIRATION='2025-03-01T13:00:00Z'
+`
+	if out.String() != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, out.String())
+	}
+
+	out.Reset()
+	if code := app.Run(context.Background(), []string{"env", "--format", "json"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	var vars map[string]string
+	if err := json.Unmarshal(out.Bytes(), &vars); err != nil {
+		t.Fatalf("Failed to decode JSON output: %v", err)
+	}
+	if vars["AWS_SESSION_TOKEN"] != "TOKEN" || len(vars) != 4 {
+		t.Errorf("Unexpected JSON output: %v", vars)
+	}
+
+	var stderr bytes.Buffer
+	app.Stderr = &stderr
+	app.Now = func() time.Time { return now.Add(2 * time.Hour) }
+	if code := app.Run(context.Background(), []string{"env"}); code != exitError {
+		t.Errorf("Expected exit code %d for an expired session, got %d", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "aws-otp-auth login") {
+		t.Errorf("Expected a hint to log in, got %q", stderr.String())
+	}
+}
+
+func TestAppRun_Exec(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeCredentials(t, home, `[dev]
+aws_access_key_id = ASIAEXAMPLE
+aws_secret_access_key = SECRET
+aws_session_token = TOKEN
+aws_session_token_expiration = `+time.Now().Add(time.Hour).UTC().Format(time.RFC3339)+`
+`)
+
+	app := newTestApp(io.Discard)
+	app.Environ = func() []string {
+		return []string{"PATH=/usr/bin", "AWS_PROFILE=prod", "AWS_SESSION_TOKEN=STALE"}
+	}
+	var ran *exec.Cmd
+	app.RunCommand = func(cmd *exec.Cmd) error {
+		ran = cmd
+		return nil
+	}
+	if code := app.Run(context.Background(), []string{"exec", "-t", "dev", "--", "aws", "s3", "ls"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if ran == nil || !slices.Equal(ran.Args, []string{"aws", "s3", "ls"}) {
+		t.Fatalf("Unexpected command: %+v", ran)
+	}
+	for _, kv := range []string{"PATH=/usr/bin", "AWS_ACCESS_KEY_ID=ASIAEXAMPLE", "AWS_SESSION_TOKEN=TOKEN"} {
+		if !slices.Contains(ran.Env, kv) {
+			t.Errorf("Expected %s in the environment, got %v", kv, ran.Env)
+		}
+	}
+	for _, kv := range []string{"AWS_PROFILE=prod", "AWS_SESSION_TOKEN=STALE"} {
+		if slices.Contains(ran.Env, kv) {
+			t.Errorf("Expected %s to be removed from the environment, got %v", kv, ran.Env)
+		}
+	}
+
+	if code := app.Run(context.Background(), []string{"exec", "-t", "dev", "aws", "s3", "ls"}); code != exitConfigError {
+		t.Errorf("Expected exit code %d without --, got %d", exitConfigError, code)
+	}
+
+	// The child's exit code is passed on.
+	app.RunCommand = (*exec.Cmd).Run
+	if code := app.Run(context.Background(), []string{"exec", "-t", "dev", "--", "sh", "-c", "exit 7"}); code != 7 {
+		t.Errorf("Expected the child's exit code 7, got %d", code)
+	}
+}
+
+func TestAppRun_StatusCheck(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	writeCredentials(t, home, `[default]
+aws_access_key_id = ASIAEXAMPLE
+aws_secret_access_key = SECRET
+aws_session_token = TOKEN
+aws_session_token_expiration = 2025-03-01T13:00:00Z
+`)
+
+	var out bytes.Buffer
+	app := newTestApp(&out)
+	app.Now = func() time.Time { return now }
+	var checked []string
+	app.CredentialsClient = func(ctx context.Context, creds aws.Credentials, endpoints *endpointOptions) (aws.STSClient, error) {
+		checked = append(checked, creds.AccessKeyID)
+		return &mockSTSCombinedClient{CheckValid: true}, nil
+	}
+	if code := app.Run(context.Background(), []string{"status", "--check", "--output", "json"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	var statuses []profileStatus
+	if err := json.Unmarshal(out.Bytes(), &statuses); err != nil {
+		t.Fatalf("Failed to decode JSON output: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].RemainingSeconds != 3600 || statuses[0].Account != "123456789012" {
+		t.Errorf("Unexpected statuses: %+v", statuses)
+	}
+	if !slices.Equal(checked, []string{"ASIAEXAMPLE"}) {
+		t.Errorf("Expected the session to be checked, got %v", checked)
+	}
+}
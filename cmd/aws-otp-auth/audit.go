@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"text/tabwriter"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/audit"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/spf13/pflag"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version string
+
+// toolVersion returns the version recorded in audit entries.
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// auditLog returns the audit log at its default location.
+func auditLog() (*audit.Log, error) {
+	path, err := audit.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return &audit.Log{Path: path, MaxBytes: audit.DefaultMaxBytes, MaxFiles: audit.DefaultMaxFiles}, nil
+}
+
+// recordAudit completes entry and appends it to the audit log. Failing to write
+// the audit log is logged but does not fail the command.
+func (a *App) recordAudit(entry audit.Entry) {
+	entry.Time = a.Now().UTC()
+	entry.Version = toolVersion()
+	entry.Hostname, _ = os.Hostname()
+	if entry.ARNAccount == "" {
+		entry.ARNAccount = aws.AccountFromARN(entry.RoleArn)
+	}
+	if entry.ARNAccount == "" {
+		entry.ARNAccount = aws.AccountFromARN(entry.MFAArn)
+	}
+
+	log, err := auditLog()
+	if err == nil {
+		err = log.Append(entry)
+	}
+	if err != nil {
+		slog.Warn("Failed to write audit log", "err", err)
+	}
+}
+
+// runAudit implements the "audit" subcommand.
+func (a *App) runAudit(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return newConfigError(fmt.Errorf("usage: aws-otp-auth audit show [--profile name] [--since date] [--until date] [--output text|json]"))
+	}
+	flags := pflag.NewFlagSet("audit show", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	profile := flags.String("profile", "", "Only show entries whose source or target is this profile")
+	since := flags.String("since", "", "Only show entries at or after this date (YYYY-MM-DD or RFC 3339)")
+	until := flags.String("until", "", "Only show entries up to and including this date (YYYY-MM-DD or RFC 3339)")
+	output := flags.String("output", "text", "Output format: text or json")
+	if err := flags.Parse(args[1:]); err != nil {
+		return newConfigError(err)
+	}
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+
+	filter := audit.Filter{Profile: *profile}
+	var err error
+	if filter.Since, err = parseAuditTime(*since, false); err != nil {
+		return newConfigError(fmt.Errorf("invalid --since: %w", err))
+	}
+	if filter.Until, err = parseAuditTime(*until, true); err != nil {
+		return newConfigError(fmt.Errorf("invalid --until: %w", err))
+	}
+
+	log, err := auditLog()
+	if err != nil {
+		return err
+	}
+	entries, err := log.Read(filter)
+	if err != nil {
+		return err
+	}
+	if *output == "json" {
+		if entries == nil {
+			entries = []audit.Entry{}
+		}
+		return writeJSON(a.Stdout, entries)
+	}
+	return writeAuditEntries(a.Stdout, entries)
+}
+
+// parseAuditTime parses a date or RFC 3339 time in local time. A bare date used
+// as an upper bound means the end of that day.
+func parseAuditTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC 3339, got %q", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// writeAuditEntries prints one row per entry.
+func writeAuditEntries(out io.Writer, entries []audit.Entry) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tSOURCE\tTARGET\tARN ACCOUNT\tDURATION\tOUTCOME\tMFA DEVICE")
+	for _, e := range entries {
+		account, duration, outcome := "-", "-", e.Outcome
+		if e.ARNAccount != "" {
+			account = e.ARNAccount
+		}
+		if e.DurationSeconds != 0 {
+			duration = (time.Duration(e.DurationSeconds) * time.Second).String()
+		}
+		if e.ErrorCode != "" {
+			outcome += " (" + e.ErrorCode + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format(time.RFC3339), e.SourceProfile, e.TargetProfile, account, duration, outcome, e.MFAArn)
+	}
+	return tw.Flush()
+}
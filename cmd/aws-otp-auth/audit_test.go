@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/audit"
+)
+
+func TestRecordAuditAndShow(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.FixedZone("test", 3600))
+	app := newTestApp(&bytes.Buffer{})
+	app.Now = func() time.Time { return now }
+
+	app.recordAudit(audit.Entry{SourceProfile: "default-long-term", TargetProfile: "default", MFAArn: "arn:aws:iam::123456789012:mfa/alice", DurationSeconds: 28800, Outcome: ActionRefreshed})
+	app.recordAudit(audit.Entry{SourceProfile: "default-long-term", TargetProfile: "prod-admin", MFAArn: "GAHT12345678", RoleArn: "arn:aws:iam::210987654321:role/Admin", DurationSeconds: 3600, Outcome: ActionFailed, ErrorCode: "bad_otp"})
+
+	var out bytes.Buffer
+	if err := newTestApp(&out).runAudit(context.Background(), []string{"show", "--profile", "prod-admin", "--output", "json"}); err != nil {
+		t.Fatalf("runAudit returned error: %v", err)
+	}
+	var entries []audit.Entry
+	if err := json.Unmarshal(out.Bytes(), &entries); err != nil {
+		t.Fatalf("Failed to decode JSON output: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected one entry for prod-admin, got %+v", entries)
+	}
+	e := entries[0]
+	if e.ARNAccount != "210987654321" || e.ErrorCode != "bad_otp" || e.Hostname == "" || e.Version == "" || !e.Time.Equal(now) || e.Time.Location() != time.UTC {
+		t.Errorf("Unexpected audit entry: %+v", e)
+	}
+
+	out.Reset()
+	today := now.Local().Format("2006-01-02")
+	if err := newTestApp(&out).runAudit(context.Background(), []string{"show", "--since", today, "--until", today}); err != nil {
+		t.Fatalf("runAudit returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "123456789012") || !strings.Contains(out.String(), "failed (bad_otp)") {
+		t.Errorf("Unexpected text output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := newTestApp(&out).runAudit(context.Background(), []string{"show", "--until", "2000-01-01", "--output", "json"}); err != nil {
+		t.Fatalf("runAudit returned error: %v", err)
+	}
+	if strings.TrimSpace(out.String()) != "[]" {
+		t.Errorf("Expected no entries, got %s", out.String())
+	}
+
+	if err := newTestApp(&out).runAudit(context.Background(), []string{"show", "--since", "yesterday"}); exitCode(err) != exitConfigError {
+		t.Errorf("Expected config error for invalid date, got %v", err)
+	}
+}
+
+func TestRecordAudit_NoSecretFields(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	newTestApp(&bytes.Buffer{}).recordAudit(audit.Entry{SourceProfile: "default-long-term", TargetProfile: "default", Outcome: ActionRefreshed})
+
+	log, err := auditLog()
+	if err != nil {
+		t.Fatalf("auditLog returned error: %v", err)
+	}
+	data, err := os.ReadFile(log.Path)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(data), &fields); err != nil {
+		t.Fatalf("Failed to decode audit line: %v", err)
+	}
+	for key := range fields {
+		for _, word := range []string{"secret", "token", "otp", "key"} {
+			if strings.Contains(key, word) {
+				t.Errorf("Audit entry has sensitive field %q", key)
+			}
+		}
+	}
+}
+
+func TestParseAuditTime(t *testing.T) {
+	start, err := parseAuditTime("2025-03-01", false)
+	if err != nil || start.Format("2006-01-02 15:04") != "2025-03-01 00:00" {
+		t.Errorf("Unexpected start of day: %v (err %v)", start, err)
+	}
+	end, err := parseAuditTime("2025-03-01", true)
+	if err != nil || end.Format("2006-01-02 15:04") != "2025-03-02 00:00" {
+		t.Errorf("Unexpected end of day: %v (err %v)", end, err)
+	}
+	exact, err := parseAuditTime("2025-03-01T12:30:00Z", true)
+	if err != nil || !exact.Equal(time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected RFC 3339 time: %v (err %v)", exact, err)
+	}
+}
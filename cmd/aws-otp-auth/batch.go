@@ -0,0 +1,300 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/audit"
+	"github.com/crbanman/aws-otp-auth/pkg/auth"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/crbanman/aws-otp-auth/pkg/config"
+	"github.com/spf13/pflag"
+)
+
+// batchTarget is one credentials profile refreshed by the batch command.
+type batchTarget struct {
+	Name     string
+	Profile  string
+	RoleArn  string
+	Duration int32
+	Hooks    hookSettings
+}
+
+// batchResult is the outcome of refreshing one target.
+type batchResult struct {
+	Name       string
+	Profile    string
+	Action     string
+	Expiration time.Time
+	Err        error
+	HookErrors []string
+}
+
+// runBatch implements the "batch" subcommand.
+func (a *App) runBatch(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("batch", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	configPath := flags.String("config", "", "Path to the config file (default ~/.config/aws-otp-auth/config.toml)")
+	all := flags.Bool("all", false, "Refresh every profile in the config file")
+	mfaArn := flags.StringP("mfa-arn", "m", "", "MFA device ARN to use for authentication (if not provided, will auto lookup)")
+	awsUser := flags.StringP("user", "u", "", "AWS username (if not provided, derived from the source profile's credentials)")
+	otpCode := flags.StringP("otp", "o", "", "One Time Password for authentication")
+	otpProvider := flags.String("otp-provider", "", "How to obtain the OTP: prompt or totp (default from config, else prompt)")
+	force := flags.BoolP("force", "F", false, "Force re-authentication even if credentials are valid")
+	minRemaining := flags.Duration("min-remaining", defaultMinRemaining, "Renew sessions that expire within this long")
+	duration := durationValue(defaultSessionDuration)
+	flags.VarP(&duration, "duration", "d", "Session duration in seconds or with units such as 12h when no profile sets one")
+	endOfDay := flags.String("end-of-day", "", "Shorten the sessions so that they end by this local time (HH:MM)")
+	output := flags.String("output", "text", "Output format: text or json")
+	hookOpts := addHookFlags(flags)
+	endpoints := addEndpointFlags(flags, a.getenv)
+	logOpts := addLogFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(a.Stderr, "Usage: aws-otp-auth batch [flags] <config-profile>... | --all")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return newConfigError(err)
+	}
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+
+	cfgFile, err := loadConfig(*configPath)
+	if err != nil {
+		return newConfigError(err)
+	}
+	names := flags.Args()
+	if *all {
+		names = names[:0]
+		for name := range cfgFile.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		flags.Usage()
+		return newConfigError(fmt.Errorf("no profiles given"))
+	}
+
+	// All targets are derived from one session, so they must share a source profile and
+	// MFA device. The other shared settings are taken from the first profile that sets them.
+	profiles := make([]config.Profile, len(names))
+	var shared config.Profile
+	var profileFrom, mfaFrom string
+	mfaOverridden := fromFlagOrEnv(flags, "mfa-arn", a.getenv)
+	for i, name := range names {
+		p, _, err := cfgFile.Lookup(name)
+		if err != nil {
+			return newConfigError(err)
+		}
+		profiles[i] = p
+		source := p.Source
+		if source == "" {
+			source = "default-long-term"
+		}
+		if profileFrom != "" && source != profileFrom {
+			return newConfigError(fmt.Errorf("profile %q uses source %q but earlier profiles use %q; batch targets must share a source profile", name, source, profileFrom))
+		}
+		profileFrom = source
+		if p.MFAArn != "" && !mfaOverridden {
+			if mfaFrom != "" && p.MFAArn != shared.MFAArn {
+				return newConfigError(fmt.Errorf("profile %q sets mfa_arn %q but profile %q sets %q; batch targets share one MFA session", name, p.MFAArn, mfaFrom, shared.MFAArn))
+			}
+			shared.MFAArn, mfaFrom = p.MFAArn, name
+		}
+		shared.Region = cmp.Or(shared.Region, p.Region)
+		shared.STSEndpoint = cmp.Or(shared.STSEndpoint, p.STSEndpoint)
+		shared.STSRegionalEndpoints = cmp.Or(shared.STSRegionalEndpoints, p.STSRegionalEndpoints)
+		shared.UseFIPS = shared.UseFIPS || p.UseFIPS
+		shared.OTPProvider = cmp.Or(shared.OTPProvider, p.OTPProvider)
+		shared.EndOfDay = cmp.Or(shared.EndOfDay, p.EndOfDay)
+	}
+	if err := applySettings(flags, shared, a.getenv); err != nil {
+		return newConfigError(err)
+	}
+	closeLog, err := logOpts.setup(a.Stderr)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	var targets []batchTarget
+	var durationFrom string
+	sessionDuration := int32(duration)
+	targetFrom := map[string]string{}
+	for i, name := range names {
+		p := profiles[i]
+		target := batchTarget{Name: name, Profile: p.Target, RoleArn: p.RoleArn, Duration: int32(p.Duration)}
+		if target.Profile == "" {
+			target.Profile = name
+		}
+		if other, ok := targetFrom[target.Profile]; ok {
+			return newConfigError(fmt.Errorf("profiles %q and %q both write credentials profile %q", other, name, target.Profile))
+		}
+		targetFrom[target.Profile] = name
+		if target.Hooks, err = profileHooks(flags, hookOpts, p); err != nil {
+			return newConfigError(fmt.Errorf("profile %q: %w", name, err))
+		}
+		// The profiles without a role all receive the MFA session, so they must agree on its duration.
+		if target.RoleArn == "" && target.Duration != 0 && !flags.Changed("duration") {
+			if durationFrom != "" && target.Duration != sessionDuration {
+				return newConfigError(fmt.Errorf("profile %q sets duration %d but profile %q sets %d; batch profiles without a role share one session", name, target.Duration, durationFrom, sessionDuration))
+			}
+			sessionDuration, durationFrom = target.Duration, name
+		}
+		if target.Duration == 0 && target.RoleArn != "" {
+			target.Duration = defaultRoleDuration
+		}
+		targets = append(targets, target)
+	}
+	for i := range targets {
+		if targets[i].RoleArn == "" {
+			targets[i].Duration = sessionDuration
+		}
+	}
+
+	if err := endpoints.validate(); err != nil {
+		return err
+	}
+	now := a.Now()
+	if sessionDuration, err = limitDuration(aws.SessionTokenDuration, sessionDuration, now, *endOfDay); err != nil {
+		return newConfigError(err)
+	}
+	// Roles are assumed with the MFA session, so STS limits them to chained role durations.
+	for i, target := range targets {
+		kind := aws.SessionTokenDuration
+		if target.RoleArn != "" {
+			kind = aws.ChainedRoleDuration
+		}
+		if targets[i].Duration, err = limitDuration(kind, target.Duration, now, *endOfDay); err != nil {
+			return newConfigError(fmt.Errorf("profile %q: %w", target.Name, err))
+		}
+	}
+
+	otpSource, err := a.otpProvider(*otpProvider, *otpCode, profileFrom)
+	if err != nil {
+		return newConfigError(err)
+	}
+	clients, err := a.Clients(ctx, profileFrom, endpoints)
+	if err != nil {
+		return err
+	}
+	if *mfaArn == "" {
+		userName, err := resolveUser(ctx, clients.STS, *awsUser)
+		if err != nil {
+			return err
+		}
+		if *mfaArn, err = resolveMFAArn(ctx, clients.IAM, userName, profileFrom, isTerminal(a.Stdin), a.Stdin, a.Stderr); err != nil {
+			return err
+		}
+	}
+
+	hooksByProfile := map[string]hookSettings{}
+	for _, target := range targets {
+		hooksByProfile[target.Profile] = target.Hooks
+	}
+	hooks := a.newHookRunner(func(profile string) hookSettings { return hooksByProfile[profile] })
+	authenticator := auth.New(clients.STS,
+		auth.WithOTPProvider(otpSource),
+		auth.WithClock(a.Now),
+		auth.WithMinRemaining(*minRemaining),
+		auth.WithHooks(hooks.hooks()),
+		auth.WithRedactor(secrets),
+		auth.WithRoleClient(clients.RoleClient),
+	)
+	results, err := refreshTargets(ctx, authenticator, targets, *mfaArn, sessionDuration, *force)
+	mfaErr := err
+	for _, r := range results {
+		if aws.IsInvalidMFACode(r.Err) {
+			mfaErr = r.Err
+			break
+		}
+	}
+	a.trackMFAMismatch(*mfaArn, mfaErr)
+	for i, r := range results {
+		results[i].HookErrors = hooks.failures[r.Profile]
+		a.recordAudit(audit.Entry{
+			SourceProfile:   profileFrom,
+			TargetProfile:   r.Profile,
+			MFAArn:          *mfaArn,
+			RoleArn:         targets[i].RoleArn,
+			DurationSeconds: targets[i].Duration,
+			Outcome:         r.Action,
+			ErrorCode:       errorCode(r.Err),
+		})
+	}
+	if *output == "json" {
+		if werr := writeJSON(a.Stdout, batchJSON(ctx, results, a.credentialsClient(endpoints))); werr != nil && err == nil {
+			err = werr
+		}
+		return err
+	}
+	if werr := writeBatchResults(a.Stdout, results); werr != nil && err == nil {
+		err = werr
+	}
+	return err
+}
+
+// refreshTargets refreshes the targets from a single MFA session with one OTP; see
+// auth.Authenticator.AuthenticateChain.
+func refreshTargets(ctx context.Context, authenticator *auth.Authenticator, targets []batchTarget, mfaArn string, sessionDuration int32, force bool) ([]batchResult, error) {
+	chain := auth.ChainRequest{MFAArn: mfaArn, DurationSeconds: sessionDuration}
+	for _, target := range targets {
+		chain.Targets = append(chain.Targets, auth.Request{Profile: target.Profile, RoleArn: target.RoleArn, DurationSeconds: target.Duration, Force: force})
+	}
+	res, err := authenticator.AuthenticateChain(ctx, chain)
+	results := make([]batchResult, len(targets))
+	for i, target := range targets {
+		results[i] = batchResult{Name: target.Name, Profile: target.Profile, Action: res[i].Action, Expiration: res[i].Expiration, Err: res[i].Err}
+	}
+	return results, err
+}
+
+// batchJSON converts the results to the --output json schema, looking up the identity
+// of every profile that holds usable credentials.
+func batchJSON(ctx context.Context, results []batchResult, newClient credentialsClientFunc) []authResult {
+	entries := make([]authResult, 0, len(results))
+	for _, r := range results {
+		entry := authResult{Name: r.Name, Profile: r.Profile, Action: r.Action, HookErrors: r.HookErrors}
+		if r.Err != nil {
+			entry.setError(r.Err)
+		} else {
+			entry.describeProfile(ctx, newClient)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// writeBatchResults prints one row per target.
+func writeBatchResults(out io.Writer, results []batchResult) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPROFILE\tRESULT\tEXPIRES\tERROR")
+	for _, r := range results {
+		expires, errMsg := "-", ""
+		if !r.Expiration.IsZero() {
+			expires = r.Expiration.Local().Format(time.RFC3339)
+		}
+		if r.Err != nil {
+			errMsg = r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.Profile, r.Action, expires, errMsg)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	shown := map[string]bool{}
+	for _, r := range results {
+		if hint := remediationHint(r.Err); hint != "" && !shown[hint] {
+			shown[hint] = true
+			fmt.Fprintf(out, "Hint: %s\n", hint)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+	"github.com/crbanman/aws-otp-auth/pkg/auth"
+	awsPkg "github.com/crbanman/aws-otp-auth/pkg/aws"
+	"gopkg.in/ini.v1"
+)
+
+// countingSessionClient counts GetSessionToken calls. Roles are assumed with roleClient instead.
+type countingSessionClient struct {
+	Calls int
+}
+
+func (c *countingSessionClient) GetCallerIdentity(ctx context.Context, input *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return nil, errors.New("not used")
+}
+
+func (c *countingSessionClient) AssumeRole(ctx context.Context, input *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	return nil, errors.New("roles must be assumed with the MFA session")
+}
+
+func (c *countingSessionClient) GetSessionToken(ctx context.Context, input *sts.GetSessionTokenInput, optFns ...func(*sts.Options)) (*sts.GetSessionTokenOutput, error) {
+	c.Calls++
+	return &sts.GetSessionTokenOutput{
+		Credentials: &types.Credentials{
+			AccessKeyId:     aws.String("sessionKey"),
+			SecretAccessKey: aws.String("sessionSecret"),
+			SessionToken:    aws.String("sessionToken"),
+			Expiration:      aws.Time(time.Now().Add(8 * time.Hour)),
+		},
+	}, nil
+}
+
+// roleClient assumes roles on behalf of a session, failing for roles named "Broken".
+type roleClient struct {
+	Session *awsPkg.SessionCredentials
+}
+
+func (r *roleClient) AssumeRole(ctx context.Context, input *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	if strings.HasSuffix(aws.ToString(input.RoleArn), "/Broken") {
+		return nil, errors.New("AccessDenied")
+	}
+	return &sts.AssumeRoleOutput{
+		Credentials: &types.Credentials{
+			AccessKeyId:     aws.String("roleKey-" + r.Session.SessionToken),
+			SecretAccessKey: aws.String("roleSecret"),
+			SessionToken:    aws.String("roleToken:" + aws.ToString(input.RoleArn)),
+			Expiration:      aws.Time(time.Now().Add(1 * time.Hour)),
+		},
+	}, nil
+}
+
+func TestRefreshTargets(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	awsDir := filepath.Join(tempHome, ".aws")
+	if err := os.MkdirAll(awsDir, 0755); err != nil {
+		t.Fatalf("Failed to create .aws directory: %v", err)
+	}
+	credsPath := filepath.Join(awsDir, "credentials")
+	validExpiry := time.Now().Add(1 * time.Hour).Format(time.RFC3339)
+	content := `[current]
+aws_access_key_id = CUR
+aws_secret_access_key = CURSECRET
+aws_session_token = CURTOKEN
+aws_session_token_expiration = ` + validExpiry + `
+`
+	if err := os.WriteFile(credsPath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write credentials file: %v", err)
+	}
+
+	targets := []batchTarget{
+		{Name: "dev", Profile: "default", Duration: 28800},
+		{Name: "prod", Profile: "prod-admin", RoleArn: "arn:aws:iam::210987654321:role/Admin", Duration: 3600},
+		{Name: "current", Profile: "current", RoleArn: "arn:aws:iam::210987654321:role/ReadOnly", Duration: 3600},
+		{Name: "broken", Profile: "broken", RoleArn: "arn:aws:iam::210987654321:role/Broken", Duration: 3600},
+	}
+	sessionClient := &countingSessionClient{}
+	newRoleClient := func(session *awsPkg.SessionCredentials) awsPkg.STSAssumeRoleClient {
+		return &roleClient{Session: session}
+	}
+
+	authenticator := auth.New(sessionClient, auth.WithOTPProvider(auth.PromptOTP(strings.NewReader("123456\n"))), auth.WithRoleClient(newRoleClient))
+	results, err := refreshTargets(context.Background(), authenticator, targets, "dummy-mfa-arn", 28800, false)
+	if err == nil || !strings.Contains(err.Error(), "1 of 4 profiles failed") {
+		t.Errorf("Expected one failure to be reported, got %v", err)
+	}
+	if sessionClient.Calls != 1 {
+		t.Errorf("Expected a single GetSessionToken call, got %d", sessionClient.Calls)
+	}
+
+	wantActions := []string{"refreshed", "refreshed", "valid", "failed"}
+	for i, r := range results {
+		if r.Action != wantActions[i] {
+			t.Errorf("Target %s: expected %s, got %s (err %v)", r.Name, wantActions[i], r.Action, r.Err)
+		}
+	}
+
+	cfg, err := ini.Load(credsPath)
+	if err != nil {
+		t.Fatalf("Failed to load credentials file: %v", err)
+	}
+	if got := cfg.Section("default").Key("aws_session_token").String(); got != "sessionToken" {
+		t.Errorf("Expected session target to hold the MFA session, got %s", got)
+	}
+	if got := cfg.Section("prod-admin").Key("aws_access_key_id").String(); got != "roleKey-sessionToken" {
+		t.Errorf("Expected role to be assumed with the MFA session, got %s", got)
+	}
+	if got := cfg.Section("current").Key("aws_session_token").String(); got != "CURTOKEN" {
+		t.Errorf("Expected valid profile to be left alone, got %s", got)
+	}
+	if cfg.HasSection("broken") {
+		t.Errorf("Failed profile should not be written")
+	}
+
+	var out bytes.Buffer
+	if err := writeBatchResults(&out, results); err != nil {
+		t.Fatalf("writeBatchResults returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "prod-admin") || !strings.Contains(out.String(), "AccessDenied") {
+		t.Errorf("Unexpected result table:\n%s", out.String())
+	}
+}
+
+func TestRefreshTargets_AllValid(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	awsDir := filepath.Join(tempHome, ".aws")
+	if err := os.MkdirAll(awsDir, 0755); err != nil {
+		t.Fatalf("Failed to create .aws directory: %v", err)
+	}
+	content := "[default]\naws_access_key_id = A\naws_secret_access_key = B\naws_session_token = C\naws_session_token_expiration = " + time.Now().Add(time.Hour).Format(time.RFC3339) + "\n"
+	if err := os.WriteFile(filepath.Join(awsDir, "credentials"), []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write credentials file: %v", err)
+	}
+
+	sessionClient := &countingSessionClient{}
+	authenticator := auth.New(sessionClient, auth.WithOTPProvider(auth.PromptOTP(strings.NewReader(""))))
+	_, err := refreshTargets(context.Background(), authenticator, []batchTarget{{Name: "dev", Profile: "default"}}, "dummy-mfa-arn", 28800, false)
+	if err != nil {
+		t.Fatalf("refreshTargets returned error: %v", err)
+	}
+	if sessionClient.Calls != 0 {
+		t.Errorf("Expected no OTP or STS call when all profiles are valid, got %d calls", sessionClient.Calls)
+	}
+}
+
+func TestAppRun_BatchSettingsPrecedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	config := filepath.Join(home, "config.toml")
+	if err := os.WriteFile(config, []byte(`[profiles.dev]
+region = "eu-west-1"
+sts_endpoint = "https://sts.config.example"
+mfa_arn = "arn:aws:iam::123456789012:mfa/phone"
+
+[profiles.ops]
+mfa_arn = "arn:aws:iam::123456789012:mfa/token"
+`), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	var stderr bytes.Buffer
+	app := newTestApp(&bytes.Buffer{})
+	app.Stderr = &stderr
+	var got *endpointOptions
+	app.Clients = func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+		got = endpoints
+		return nil, errors.New("stop")
+	}
+
+	// Profiles that name different MFA devices cannot share one session.
+	if code := app.Run(context.Background(), []string{"batch", "--config", config, "--otp", "123456", "dev", "ops"}); code != exitConfigError {
+		t.Fatalf("Expected exit code %d, got %d", exitConfigError, code)
+	}
+	if !strings.Contains(stderr.String(), `profile "ops" sets mfa_arn "arn:aws:iam::123456789012:mfa/token" but profile "dev" sets`) {
+		t.Errorf("Expected the conflicting MFA devices in the error, got %q", stderr.String())
+	}
+
+	// The environment beats the config file, and settles the MFA device.
+	app.Environ = func() []string {
+		return []string{"AWS_REGION=ca-central-1", "AWS_OTP_AUTH_STS_ENDPOINT=https://sts.env.example", "AWS_OTP_AUTH_MFA_ARN=" + testMFAArn}
+	}
+	if code := app.Run(context.Background(), []string{"batch", "--config", config, "--otp", "123456", "dev", "ops"}); code != exitError {
+		t.Fatalf("Expected exit code %d, got %d (%s)", exitError, code, stderr.String())
+	}
+	if got == nil || got.region != "ca-central-1" || got.stsEndpoint != "https://sts.env.example" {
+		t.Errorf("Expected the region and STS endpoint from the environment, got %+v", got)
+	}
+
+	// Flags beat the environment.
+	if code := app.Run(context.Background(), []string{"batch", "--config", config, "--otp", "123456", "--region", "us-west-2", "dev"}); code != exitError {
+		t.Fatalf("Expected exit code %d, got %d", exitError, code)
+	}
+	if got.region != "us-west-2" || got.stsEndpoint != "https://sts.env.example" {
+		t.Errorf("Expected the region from the flag, got %+v", got)
+	}
+}
+
+func TestAppRun_BatchDuplicateTargets(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	config := filepath.Join(home, "config.toml")
+	if err := os.WriteFile(config, []byte("[profiles.dev]\n\n[profiles.other]\ntarget = \"dev\"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	var stderr bytes.Buffer
+	app := newTestApp(&bytes.Buffer{})
+	app.Stderr = &stderr
+	if code := app.Run(context.Background(), []string{"batch", "--config", config, "--otp", "123456", "dev", "other"}); code != exitConfigError {
+		t.Errorf("Expected exit code %d, got %d", exitConfigError, code)
+	}
+	if !strings.Contains(stderr.String(), `profiles "dev" and "other" both write credentials profile "dev"`) {
+		t.Errorf("Expected the duplicate target in the error, got %q", stderr.String())
+	}
+	stderr.Reset()
+	if code := app.Run(context.Background(), []string{"batch", "--config", config, "--otp", "123456", "dev", "dev"}); code != exitConfigError {
+		t.Errorf("Expected exit code %d for a repeated profile, got %d", exitConfigError, code)
+	}
+}
+
+func TestAppRun_BatchMFAMismatch(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	config := filepath.Join(home, "config.toml")
+	if err := os.WriteFile(config, []byte("[profiles.dev]\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	var stderr bytes.Buffer
+	app := newTestApp(&bytes.Buffer{})
+	app.Stderr = &stderr
+	badOTP := &smithy.GenericAPIError{Code: "AccessDenied", Message: "MultiFactorAuthentication failed with invalid MFA one time pass code."}
+	app.Clients = func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+		return &AWSClients{STS: &mockSTSCombinedClient{SessionTokenError: badOTP}, IAM: &fakeIAMClient{}}, nil
+	}
+	args := []string{"batch", "--config", config, "--otp", "123456", "--mfa-arn", testMFAArn, "--force", "dev"}
+
+	// A rejected code during batch counts towards the resync suggestion, as it does for login.
+	if code := app.Run(context.Background(), args); code == exitOK {
+		t.Fatalf("Expected the batch to fail")
+	}
+	if strings.Contains(stderr.String(), "mfa resync") {
+		t.Errorf("Expected no resync suggestion after one rejected code, got %q", stderr.String())
+	}
+	if code := app.Run(context.Background(), args); code == exitOK {
+		t.Fatalf("Expected the batch to fail")
+	}
+	if !strings.Contains(stderr.String(), "rejected 2 times in a row") {
+		t.Errorf("Expected a resync suggestion after two rejected codes, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/crbanman/aws-otp-auth/pkg/config"
+	"github.com/spf13/pflag"
+)
+
+// flagEnv lists the environment variables that can set each flag, in order of preference.
+var flagEnv = map[string][]string{
+	"profile-from":           {"AWS_OTP_AUTH_PROFILE_FROM"},
+	"profile-to":             {"AWS_OTP_AUTH_PROFILE_TO"},
+	"mfa-arn":                {"AWS_OTP_AUTH_MFA_ARN"},
+	"role-arn":               {"AWS_OTP_AUTH_ROLE_ARN"},
+	"user":                   {"AWS_OTP_AUTH_USER"},
+	"duration":               {"AWS_OTP_AUTH_DURATION"},
+	"otp-provider":           {"AWS_OTP_AUTH_OTP_PROVIDER"},
+	"region":                 {"AWS_REGION", "AWS_DEFAULT_REGION"},
+	"validate":               {"AWS_OTP_AUTH_VALIDATE"},
+	"min-remaining":          {"AWS_OTP_AUTH_MIN_REMAINING"},
+	"end-of-day":             {"AWS_OTP_AUTH_END_OF_DAY"},
+	"pre-refresh":            {"AWS_OTP_AUTH_PRE_REFRESH"},
+	"post-refresh":           {"AWS_OTP_AUTH_POST_REFRESH"},
+	"hook-timeout":           {"AWS_OTP_AUTH_HOOK_TIMEOUT"},
+	"hook-secrets":           {"AWS_OTP_AUTH_HOOK_SECRETS"},
+	"notify-before":          {"AWS_OTP_AUTH_NOTIFY_BEFORE"},
+	"notify-command":         {"AWS_OTP_AUTH_NOTIFY_COMMAND"},
+	"refresh-command":        {"AWS_OTP_AUTH_REFRESH_COMMAND"},
+	"max-key-age":            {"AWS_OTP_AUTH_MAX_KEY_AGE"},
+	"sts-endpoint":           {"AWS_OTP_AUTH_STS_ENDPOINT"},
+	"use-fips":               {"AWS_OTP_AUTH_USE_FIPS"},
+	"sts-regional-endpoints": {"AWS_OTP_AUTH_STS_REGIONAL_ENDPOINTS", "AWS_STS_REGIONAL_ENDPOINTS"},
+	"log-level":              {"AWS_OTP_AUTH_LOG_LEVEL"},
+	"log-format":             {"AWS_OTP_AUTH_LOG_FORMAT"},
+	"log-file":               {"AWS_OTP_AUTH_LOG_FILE"},
+}
+
+// applySettings fills in the flags that were not given on the command line, first
+// from their environment variables and then from the config profile, so that
+// flags take precedence over the environment, the config file and the defaults.
+func applySettings(flags *pflag.FlagSet, profile config.Profile, getenv func(string) string) error {
+	fromConfig := map[string]string{
+		"profile-from":           profile.Source,
+		"profile-to":             profile.Target,
+		"mfa-arn":                profile.MFAArn,
+		"role-arn":               profile.RoleArn,
+		"user":                   profile.User,
+		"otp-provider":           profile.OTPProvider,
+		"region":                 profile.Region,
+		"sts-endpoint":           profile.STSEndpoint,
+		"sts-regional-endpoints": profile.STSRegionalEndpoints,
+		"min-remaining":          profile.MinRemaining,
+		"end-of-day":             profile.EndOfDay,
+		"pre-refresh":            profile.PreRefresh,
+		"post-refresh":           profile.PostRefresh,
+		"hook-timeout":           profile.HookTimeout,
+		"notify-before":          strings.Join(profile.NotifyBefore, ","),
+		"notify-command":         profile.NotifyCommand,
+		"refresh-command":        profile.RefreshCommand,
+	}
+	if profile.Validate {
+		fromConfig["validate"] = "true"
+	}
+	if profile.UseFIPS {
+		fromConfig["use-fips"] = "true"
+	}
+	if profile.HookSecrets {
+		fromConfig["hook-secrets"] = "true"
+	}
+	if profile.Duration != 0 {
+		fromConfig["duration"] = strconv.Itoa(int(profile.Duration))
+	}
+	if profile.MaxKeyAge != nil {
+		fromConfig["max-key-age"] = strconv.Itoa(*profile.MaxKeyAge)
+	}
+
+	for name, envs := range flagEnv {
+		if flags.Lookup(name) == nil || flags.Changed(name) {
+			continue
+		}
+		value := fromConfig[name]
+		for _, env := range envs {
+			if v := getenv(env); v != "" {
+				value = v
+				break
+			}
+		}
+		if value == "" {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for --%s: %w", value, name, err)
+		}
+	}
+	return nil
+}
+
+// fromFlagOrEnv reports whether the flag was given on the command line or through
+// one of its environment variables, which take precedence over the config file.
+func fromFlagOrEnv(flags *pflag.FlagSet, name string, getenv func(string) string) bool {
+	if flags.Changed(name) {
+		return true
+	}
+	for _, env := range flagEnv[name] {
+		if getenv(env) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// loadConfig loads the config file at path, or the default location if path is empty.
+func loadConfig(path string) (*config.Config, error) {
+	if path == "" {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			return nil, err
+		}
+	}
+	return config.Load(path)
+}
+
+// configReport is the result of "config validate" as reported with --output json.
+type configReport struct {
+	Path     string   `json:"path"`
+	Valid    bool     `json:"valid"`
+	Profiles int      `json:"profiles"`
+	Errors   []string `json:"errors"`
+}
+
+// runConfig implements the "config" subcommand.
+func (a *App) runConfig(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return newConfigError(fmt.Errorf("usage: aws-otp-auth config validate [--config path] [--output text|json]"))
+	}
+	flags := pflag.NewFlagSet("config validate", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	configPath := flags.String("config", "", "Path to the config file (default ~/.config/aws-otp-auth/config.toml)")
+	output := flags.String("output", "text", "Output format: text or json")
+	if err := flags.Parse(args[1:]); err != nil {
+		return newConfigError(err)
+	}
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+
+	path := *configPath
+	if path == "" {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			return err
+		}
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return newConfigError(err)
+	}
+	errs := cfg.Validate(commandNames()...)
+	report := configReport{Path: path, Valid: len(errs) == 0, Profiles: len(cfg.Profiles), Errors: []string{}}
+	for _, err := range errs {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	if *output == "json" {
+		if err := writeJSON(a.Stdout, report); err != nil {
+			return err
+		}
+	} else {
+		for _, msg := range report.Errors {
+			fmt.Fprintf(a.Stdout, "  %s\n", msg)
+		}
+		if report.Valid {
+			fmt.Fprintf(a.Stdout, "Config is valid (%d profiles).\n", report.Profiles)
+		}
+	}
+	if !report.Valid {
+		return newConfigError(fmt.Errorf("config file has %d problem(s)", len(errs)))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crbanman/aws-otp-auth/pkg/config"
+	"github.com/spf13/pflag"
+)
+
+func TestApplySettings_Precedence(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	profileFrom := flags.String("profile-from", "default-long-term", "")
+	profileTo := flags.String("profile-to", "default", "")
+	region := flags.String("region", "", "")
+	duration := flags.Int("duration", 28800, "")
+	mfaArn := flags.String("mfa-arn", "", "")
+	if err := flags.Parse([]string{"--profile-to", "from-flag"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	profile := config.Profile{
+		Source:   "from-config",
+		Target:   "config-target",
+		Region:   "eu-west-1",
+		Duration: 3600,
+	}
+	env := map[string]string{
+		"AWS_OTP_AUTH_PROFILE_FROM": "from-env",
+		"AWS_DEFAULT_REGION":        "ca-central-1",
+	}
+	if err := applySettings(flags, profile, func(k string) string { return env[k] }); err != nil {
+		t.Fatalf("applySettings returned error: %v", err)
+	}
+
+	if *profileTo != "from-flag" {
+		t.Errorf("Expected flag to win, got %s", *profileTo)
+	}
+	if *profileFrom != "from-env" {
+		t.Errorf("Expected environment to beat config, got %s", *profileFrom)
+	}
+	if *region != "ca-central-1" {
+		t.Errorf("Expected AWS_DEFAULT_REGION to beat config, got %s", *region)
+	}
+	if *duration != 3600 {
+		t.Errorf("Expected config duration, got %d", *duration)
+	}
+	if *mfaArn != "" {
+		t.Errorf("Expected default to remain when nothing is set, got %s", *mfaArn)
+	}
+}
+
+func TestApplySettings_InvalidEnv(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("duration", 28800, "")
+	env := map[string]string{"AWS_OTP_AUTH_DURATION": "forever"}
+	if err := applySettings(flags, config.Profile{}, func(k string) string { return env[k] }); err == nil {
+		t.Errorf("Expected error for invalid duration, got nil")
+	}
+}
+
+func TestRunConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.toml")
+	if err := os.WriteFile(valid, []byte("[profiles.dev]\nsource = \"dev-long-term\"\ntarget = \"dev\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	var out bytes.Buffer
+	if err := newTestApp(&out).runConfig(context.Background(), []string{"validate", "--config", valid}); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+	if !strings.Contains(out.String(), "1 profiles") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+
+	invalid := filepath.Join(dir, "invalid.toml")
+	if err := os.WriteFile(invalid, []byte("[profiles.mfa]\nduration = 10\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	out.Reset()
+	if err := newTestApp(&out).runConfig(context.Background(), []string{"validate", "--config", invalid}); err == nil {
+		t.Fatalf("Expected validation to fail")
+	}
+	if !strings.Contains(out.String(), "clashes with the \"mfa\" subcommand") || !strings.Contains(out.String(), "duration 10") {
+		t.Errorf("Expected problems to be listed, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	err := newTestApp(&out).runConfig(context.Background(), []string{"validate", "--config", invalid, "--output", "json"})
+	if exitCode(err) != exitConfigError {
+		t.Errorf("Expected config error exit code, got %d (%v)", exitCode(err), err)
+	}
+	var report configReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode JSON output: %v", err)
+	}
+	if report.Valid || report.Profiles != 1 || len(report.Errors) != 2 {
+		t.Errorf("Unexpected JSON report: %+v", report)
+	}
+}
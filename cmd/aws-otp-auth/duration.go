@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/crbanman/aws-otp-auth/pkg/config"
+)
+
+// Default session durations, in seconds.
+const (
+	defaultSessionDuration = 28800
+	defaultRoleDuration    = 3600
+)
+
+// defaultMinRemaining is how long a stored session must still be valid for to be kept.
+const defaultMinRemaining = 5 * time.Minute
+
+// durationValue is a flag holding a duration in seconds, given as seconds or with units such as 12h.
+type durationValue int32
+
+func (d *durationValue) Set(s string) error {
+	seconds, err := config.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = durationValue(seconds)
+	return nil
+}
+
+func (d *durationValue) String() string { return strconv.Itoa(int(*d)) }
+func (d *durationValue) Type() string   { return "duration" }
+
+// limitDuration checks the requested duration against the limits of the STS operation
+// and, if endOfDay (HH:MM) is set, shortens it so that the session ends by the next
+// occurrence of that local time. It never goes below the STS minimum.
+func limitDuration(kind aws.DurationKind, seconds int32, now time.Time, endOfDay string) (int32, error) {
+	if err := aws.CheckDuration(kind, seconds); err != nil {
+		return 0, err
+	}
+	if endOfDay == "" {
+		return seconds, nil
+	}
+	hour, minute, err := config.ParseEndOfDay(endOfDay)
+	if err != nil {
+		return 0, err
+	}
+	end := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !end.After(now) {
+		end = end.AddDate(0, 0, 1)
+	}
+	if left := int32(end.Sub(now) / time.Second); left < seconds {
+		seconds = max(left, aws.MinSessionDuration)
+	}
+	return seconds, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/spf13/pflag"
+)
+
+// recordingSTSClient records the durations requested from a mockSTSCombinedClient.
+type recordingSTSClient struct {
+	mockSTSCombinedClient
+	sessionDurations []int32
+	roleDurations    []int32
+}
+
+func (r *recordingSTSClient) GetSessionToken(ctx context.Context, input *sts.GetSessionTokenInput, optFns ...func(*sts.Options)) (*sts.GetSessionTokenOutput, error) {
+	r.sessionDurations = append(r.sessionDurations, *input.DurationSeconds)
+	r.SessionTokenValid = true
+	return r.mockSTSCombinedClient.GetSessionToken(ctx, input, optFns...)
+}
+
+func (r *recordingSTSClient) AssumeRole(ctx context.Context, input *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	r.roleDurations = append(r.roleDurations, *input.DurationSeconds)
+	return r.mockSTSCombinedClient.AssumeRole(ctx, input, optFns...)
+}
+
+func TestLimitDuration(t *testing.T) {
+	loc := time.FixedZone("test", -5*3600)
+	morning := time.Date(2025, 3, 3, 9, 0, 0, 0, loc)
+	tests := []struct {
+		name     string
+		kind     aws.DurationKind
+		seconds  int32
+		now      time.Time
+		endOfDay string
+		want     int32
+		wantErr  bool
+	}{
+		{"session token", aws.SessionTokenDuration, 43200, morning, "", 43200, false},
+		{"below minimum", aws.SessionTokenDuration, 600, morning, "", 0, true},
+		{"role above maximum", aws.RoleDuration, 86400, morning, "", 0, true},
+		{"chained role above one hour", aws.ChainedRoleDuration, 7200, morning, "", 0, true},
+		{"capped at end of day", aws.SessionTokenDuration, 43200, morning, "17:30", 8*3600 + 1800, false},
+		{"shorter than end of day", aws.SessionTokenDuration, 3600, morning, "17:30", 3600, false},
+		{"end of day passed uses tomorrow", aws.SessionTokenDuration, 129600, time.Date(2025, 3, 3, 19, 0, 0, 0, loc), "17:30", 22*3600 + 1800, false},
+		{"never below minimum", aws.SessionTokenDuration, 43200, time.Date(2025, 3, 3, 17, 25, 0, 0, loc), "17:30", 900, false},
+		{"invalid end of day", aws.SessionTokenDuration, 43200, morning, "5pm", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := limitDuration(tt.kind, tt.seconds, tt.now, tt.endOfDay)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %d seconds, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDurationValue(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.SetOutput(&bytes.Buffer{})
+	d := durationValue(defaultSessionDuration)
+	flags.VarP(&d, "duration", "d", "")
+	if err := flags.Parse([]string{"--duration", "12h"}); err != nil || d != 43200 {
+		t.Errorf("Expected 43200 seconds, got %d (err %v)", d, err)
+	}
+	if err := flags.Parse([]string{"-d", "3600"}); err != nil || d != 3600 {
+		t.Errorf("Expected 3600 seconds, got %d (err %v)", d, err)
+	}
+	if err := flags.Parse([]string{"-d", "forever"}); err == nil {
+		t.Errorf("Expected error for an invalid duration")
+	}
+	// A value that would wrap around to 28800 seconds in an int32 is rejected.
+	if err := flags.Parse([]string{"-d", "4294996096"}); err == nil || d != 3600 {
+		t.Errorf("Expected error for a duration beyond int32, got %d (err %v)", d, err)
+	}
+}
+
+func TestAppRun_LoginDuration(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeCredentials(t, home, "")
+
+	app := newTestApp(&bytes.Buffer{})
+	sts := &recordingSTSClient{}
+	app.Clients = func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+		return &AWSClients{STS: sts, IAM: &fakeIAMClient{}}, nil
+	}
+
+	// Assuming a role defaults to one hour rather than the session token default.
+	if code := app.Run(context.Background(), []string{"--otp", "123456", "--mfa-arn", testMFAArn, "--role-arn", "arn:aws:iam::210987654321:role/Admin"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if len(sts.roleDurations) != 1 || sts.roleDurations[0] != defaultRoleDuration {
+		t.Errorf("Expected AssumeRole for %d seconds, got %v", defaultRoleDuration, sts.roleDurations)
+	}
+
+	// Out-of-range durations are rejected before any request is made.
+	if code := app.Run(context.Background(), []string{"--otp", "123456", "--mfa-arn", testMFAArn, "--profile-to", "other", "--duration", "48h"}); code != exitConfigError {
+		t.Errorf("Expected exit code %d, got %d", exitConfigError, code)
+	}
+	if len(sts.sessionDurations) != 0 {
+		t.Errorf("Expected no GetSessionToken call, got %v", sts.sessionDurations)
+	}
+}
+
+func TestAppRun_BatchChainedRoleDuration(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	config := filepath.Join(home, "config.toml")
+	if err := os.WriteFile(config, []byte("[profiles.admin]\nrole_arn = \"arn:aws:iam::210987654321:role/Admin\"\nduration = \"2h\"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	var stderr bytes.Buffer
+	app := newTestApp(&bytes.Buffer{})
+	app.Stderr = &stderr
+	if code := app.Run(context.Background(), []string{"batch", "--config", config, "--otp", "123456", "admin"}); code != exitConfigError {
+		t.Errorf("Expected exit code %d, got %d", exitConfigError, code)
+	}
+	if !strings.Contains(stderr.String(), "chained AssumeRole duration 7200 is outside 900-3600 seconds") {
+		t.Errorf("Expected the chained role limit in the error, got %q", stderr.String())
+	}
+}
+
+func TestAppRun_BatchConflictingDurations(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	config := filepath.Join(home, "config.toml")
+	if err := os.WriteFile(config, []byte("[profiles.dev]\nduration = \"8h\"\n\n[profiles.ops]\ntarget = \"ops\"\nduration = \"4h\"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	var stderr bytes.Buffer
+	app := newTestApp(&bytes.Buffer{})
+	app.Stderr = &stderr
+	if code := app.Run(context.Background(), []string{"batch", "--config", config, "--otp", "123456", "dev", "ops"}); code != exitConfigError {
+		t.Errorf("Expected exit code %d, got %d", exitConfigError, code)
+	}
+	if !strings.Contains(stderr.String(), `profile "ops" sets duration 14400 but profile "dev" sets 28800`) {
+		t.Errorf("Expected the conflicting durations in the error, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/crbanman/aws-otp-auth/internal/fakeaws"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+)
+
+var (
+	e2eBuild    sync.Once
+	e2eBinary   string
+	e2eBuildErr error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if e2eBinary != "" {
+		os.RemoveAll(filepath.Dir(e2eBinary))
+	}
+	os.Exit(code)
+}
+
+// buildBinary compiles the command once per test run and returns its path.
+func buildBinary(t *testing.T) string {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	e2eBuild.Do(func() {
+		dir, err := os.MkdirTemp("", "aws-otp-auth-e2e")
+		if err != nil {
+			e2eBuildErr = err
+			return
+		}
+		e2eBinary = filepath.Join(dir, "aws-otp-auth")
+		if out, err := exec.Command("go", "build", "-o", e2eBinary, ".").CombinedOutput(); err != nil {
+			e2eBuildErr = errors.New(string(out))
+		}
+	})
+	if e2eBuildErr != nil {
+		t.Fatalf("Failed to build binary: %v", e2eBuildErr)
+	}
+	return e2eBinary
+}
+
+// e2eEnv is a home directory with long-term credentials for "default-long-term",
+// whose region is eu-west-1, and a fake STS and IAM server.
+type e2eEnv struct {
+	t      *testing.T
+	binary string
+	home   string
+	server *fakeaws.Server
+}
+
+func newE2E(t *testing.T, cfg fakeaws.Config) *e2eEnv {
+	e := &e2eEnv{t: t, binary: buildBinary(t), home: t.TempDir(), server: fakeaws.New(cfg)}
+	t.Cleanup(e.server.Close)
+	t.Setenv("HOME", e.home)
+	e.writeFile(".aws/credentials", "[default-long-term]\naws_access_key_id = AKIAFAKELONGTERM\naws_secret_access_key = long-term-secret\n")
+	e.writeFile(".aws/config", "[profile default-long-term]\nregion = eu-west-1\n")
+	return e
+}
+
+// writeFile writes content to a file relative to the home directory.
+func (e *e2eEnv) writeFile(name, content string) {
+	path := filepath.Join(e.home, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		e.t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		e.t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// run runs the binary with a clean environment that sends STS and IAM requests to the fake server.
+func (e *e2eEnv) run(args ...string) (stdout, stderr string, code int) {
+	cmd := exec.Command(e.binary, args...)
+	cmd.Env = []string{
+		"HOME=" + e.home,
+		"PATH=" + os.Getenv("PATH"),
+		"AWS_OTP_AUTH_STS_ENDPOINT=" + e.server.URL,
+		"AWS_ENDPOINT_URL_IAM=" + e.server.URL,
+		"AWS_EC2_METADATA_DISABLED=true",
+	}
+	var out, errOut bytes.Buffer
+	cmd.Stdin = strings.NewReader("")
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		e.t.Fatalf("Failed to run binary: %v", err)
+	}
+	return out.String(), errOut.String(), code
+}
+
+// runJSON runs the binary with --output json and decodes the result.
+func (e *e2eEnv) runJSON(args ...string) (authResult, int) {
+	stdout, stderr, code := e.run(append(args, "--output", "json")...)
+	var result authResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		e.t.Fatalf("Failed to decode output %q (stderr %q): %v", stdout, stderr, err)
+	}
+	return result, code
+}
+
+func TestE2E_SessionToken(t *testing.T) {
+	e := newE2E(t, fakeaws.Config{OTP: "123456"})
+
+	result, code := e.runJSON("--otp", "123456")
+	if code != exitOK || result.Action != ActionRefreshed {
+		t.Fatalf("Expected refresh with exit code %d, got %d: %+v", exitOK, code, result)
+	}
+	if result.Profile != "default" || result.Account != fakeaws.DefaultAccount || result.Arn != "arn:aws:iam::123456789012:user/alice" || result.Expiration == nil {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	if calls := e.server.Calls("ListMFADevices"); len(calls) != 1 || calls[0].Params["UserName"] != fakeaws.DefaultUserName {
+		t.Errorf("Expected MFA device lookup for alice, got %+v", calls)
+	}
+	calls := e.server.Calls("GetSessionToken")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 GetSessionToken call, got %d", len(calls))
+	}
+	if calls[0].Region != "eu-west-1" || calls[0].AccessKeyID != "AKIAFAKELONGTERM" || calls[0].Params["DurationSeconds"] != "28800" || calls[0].Params["SerialNumber"] != "arn:aws:iam::123456789012:mfa/alice" {
+		t.Errorf("Unexpected GetSessionToken call: %+v", calls[0])
+	}
+	creds, err := aws.ReadAWSCredentials("default")
+	if err != nil || !strings.HasPrefix(creds.AccessKeyID, "ASIAFAKE") {
+		t.Errorf("Expected session credentials in the default profile, got %+v (err %v)", creds, err)
+	}
+
+	_, _, code = e.run("--otp", "123456", "--detailed-exitcode")
+	if code != exitAlreadyValid {
+		t.Errorf("Expected exit code %d for a valid session, got %d", exitAlreadyValid, code)
+	}
+	if calls := e.server.Calls("GetSessionToken"); len(calls) != 1 {
+		t.Errorf("Expected the valid session to be kept, got %d GetSessionToken calls", len(calls))
+	}
+}
+
+func TestE2E_BadOTP(t *testing.T) {
+	e := newE2E(t, fakeaws.Config{OTP: "123456"})
+
+	result, code := e.runJSON("--otp", "000000", "--mfa-arn", "arn:aws:iam::123456789012:mfa/alice")
+	if code != exitBadOTP || result.Action != ActionFailed || result.ErrorCode != "bad_otp" || result.Hint == "" {
+		t.Errorf("Expected bad OTP failure with exit code %d, got %d: %+v", exitBadOTP, code, result)
+	}
+	if _, err := aws.ReadAWSCredentials("default"); err == nil {
+		t.Errorf("Expected no credentials to be written")
+	}
+}
+
+func TestE2E_ConfigProfileRole(t *testing.T) {
+	e := newE2E(t, fakeaws.Config{})
+	e.writeFile(".config/aws-otp-auth/config.toml", `[profiles.admin]
+source = "default-long-term"
+target = "admin"
+mfa_arn = "arn:aws:iam::123456789012:mfa/alice"
+role_arn = "arn:aws:iam::210987654321:role/Admin"
+duration = 3600
+region = "us-gov-west-1"
+`)
+
+	result, code := e.runJSON("admin", "--otp", "123456")
+	if code != exitOK || result.Profile != "admin" || result.Account != "210987654321" {
+		t.Fatalf("Expected role credentials in admin, got exit code %d: %+v", code, result)
+	}
+	if calls := e.server.Calls("ListMFADevices"); len(calls) != 0 {
+		t.Errorf("Expected no MFA device lookup with mfa_arn set, got %d calls", len(calls))
+	}
+	calls := e.server.Calls("AssumeRole")
+	if len(calls) != 1 || calls[0].Region != "us-gov-west-1" || calls[0].Params["RoleArn"] != "arn:aws:iam::210987654321:role/Admin" || calls[0].Params["DurationSeconds"] != "3600" {
+		t.Errorf("Unexpected AssumeRole calls: %+v", calls)
+	}
+}
+
+func TestE2E_ThrottlingIsRetried(t *testing.T) {
+	e := newE2E(t, fakeaws.Config{})
+	e.server.FailNext("GetSessionToken", fakeaws.ErrThrottling, fakeaws.ErrThrottling)
+
+	_, stderr, code := e.run("--otp", "123456", "--mfa-arn", "arn:aws:iam::123456789012:mfa/alice")
+	if code != exitOK {
+		t.Fatalf("Expected success after retries, got exit code %d: %s", code, stderr)
+	}
+	if calls := e.server.Calls("GetSessionToken"); len(calls) != 3 {
+		t.Errorf("Expected 3 GetSessionToken calls, got %d", len(calls))
+	}
+}
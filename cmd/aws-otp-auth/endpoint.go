@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/pflag"
+
+	awsPkg "github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	awsSts "github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyendpoints "github.com/aws/smithy-go/endpoints"
+)
+
+// defaultRegion is used when neither the flags, the environment nor the shared config name a region.
+const defaultRegion = "us-east-1"
+
+// STS endpoint modes for --sts-regional-endpoints, named after the AWS CLI setting.
+const (
+	stsEndpointsRegional = "regional" // sts.<region>.amazonaws.com
+	stsEndpointsLegacy   = "legacy"   // the global sts.amazonaws.com for the regions that had it
+)
+
+// endpointOptions holds the region and endpoint flags shared by the commands.
+type endpointOptions struct {
+	region       string
+	stsEndpoint  string
+	useFIPS      bool
+	stsEndpoints string
+	// getenv reads the environment the region is resolved from; nil means an empty one.
+	getenv func(string) string
+}
+
+// addEndpointFlags registers the region and endpoint flags on flags. The region
+// falls back to the environment read with getenv.
+func addEndpointFlags(flags *pflag.FlagSet, getenv func(string) string) *endpointOptions {
+	o := &endpointOptions{getenv: getenv}
+	flags.StringVarP(&o.region, "region", "r", "", "AWS region to use (default from the source profile, else us-east-1)")
+	flags.StringVar(&o.stsEndpoint, "sts-endpoint", "", "Send STS requests to this URL instead of the AWS endpoint, e.g. a VPC endpoint or proxy")
+	flags.BoolVar(&o.useFIPS, "use-fips", false, "Use FIPS 140 validated AWS endpoints")
+	flags.StringVar(&o.stsEndpoints, "sts-regional-endpoints", stsEndpointsRegional, "STS endpoint to use: regional, or legacy for the global endpoint")
+	return o
+}
+
+// validate checks the option values.
+func (o *endpointOptions) validate() error {
+	switch o.stsEndpoints {
+	case stsEndpointsRegional, stsEndpointsLegacy:
+	default:
+		return newConfigError(fmt.Errorf("invalid --sts-regional-endpoints %q: must be %s or %s", o.stsEndpoints, stsEndpointsRegional, stsEndpointsLegacy))
+	}
+	if o.stsEndpoint != "" {
+		u, err := url.Parse(o.stsEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return newConfigError(fmt.Errorf("invalid --sts-endpoint %q: must be an http or https URL", o.stsEndpoint))
+		}
+	}
+	return nil
+}
+
+// resolveRegion returns the region to use, preferring the flag value, then the
+// AWS_REGION and AWS_DEFAULT_REGION environment variables read with getenv. It
+// returns "" to leave the choice to the shared config profile.
+func resolveRegion(region string, getenv func(string) string) string {
+	if region != "" || getenv == nil {
+		return region
+	} else if envRegion := getenv("AWS_REGION"); envRegion != "" {
+		return envRegion
+	} else if envDefaultRegion := getenv("AWS_DEFAULT_REGION"); envDefaultRegion != "" {
+		return envDefaultRegion
+	}
+	return ""
+}
+
+// load loads the AWS config with the region and FIPS setting applied. The SDK's own
+// retries are disabled; pkg/aws retries transient failures under its own policy.
+func (o *endpointOptions) load(ctx context.Context, optFns ...func(*awsConfig.LoadOptions) error) (awsPkg.Config, error) {
+	opts := []func(*awsConfig.LoadOptions) error{awsConfig.WithRetryMaxAttempts(1)}
+	if region := resolveRegion(o.region, o.getenv); region != "" {
+		opts = append(opts, awsConfig.WithRegion(region))
+	}
+	if o.useFIPS {
+		opts = append(opts, awsConfig.WithUseFIPSEndpoint(awsPkg.FIPSEndpointStateEnabled))
+	}
+	cfg, err := awsConfig.LoadDefaultConfig(ctx, append(opts, optFns...)...)
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+	return cfg, nil
+}
+
+// newSTSClient returns an STS client for cfg that uses the configured STS endpoint.
+func (o *endpointOptions) newSTSClient(cfg awsPkg.Config, optFns ...func(*awsSts.Options)) *awsSts.Client {
+	return awsSts.NewFromConfig(cfg, append([]func(*awsSts.Options){o.applySTS}, optFns...)...)
+}
+
+// applySTS sets the custom endpoint or global endpoint mode on an STS client.
+// A custom endpoint is used as given: the SDK refuses to combine it with FIPS, so
+// --use-fips then only applies to IAM.
+func (o *endpointOptions) applySTS(opts *awsSts.Options) {
+	if o.stsEndpoint != "" {
+		opts.BaseEndpoint = awsPkg.String(o.stsEndpoint)
+		opts.EndpointOptions.UseFIPSEndpoint = awsPkg.FIPSEndpointStateDisabled
+	}
+	if o.stsEndpoints == stsEndpointsLegacy {
+		opts.EndpointResolverV2 = globalEndpointResolver{next: awsSts.NewDefaultEndpointResolverV2()}
+	}
+}
+
+// globalEndpointResolver resolves STS requests in the legacy regions to the global endpoint.
+type globalEndpointResolver struct {
+	next awsSts.EndpointResolverV2
+}
+
+func (r globalEndpointResolver) ResolveEndpoint(ctx context.Context, params awsSts.EndpointParameters) (smithyendpoints.Endpoint, error) {
+	params.UseGlobalEndpoint = awsPkg.Bool(true)
+	return r.next.ResolveEndpoint(ctx, params)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/crbanman/aws-otp-auth/pkg/config"
+	"github.com/spf13/pflag"
+
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	awsCredentials "github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+const callerIdentityResponse = `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>
+    <Arn>arn:aws:iam::123456789012:user/alice</Arn>
+    <UserId>AIDAEXAMPLEUSERID</UserId>
+    <Account>123456789012</Account>
+  </GetCallerIdentityResult>
+  <ResponseMetadata><RequestId>1</RequestId></ResponseMetadata>
+</GetCallerIdentityResponse>`
+
+// isolateAWSConfig points the SDK at empty shared config files and clears the environment that affects endpoints.
+func isolateAWSConfig(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION", "AWS_PROFILE", "AWS_USE_FIPS_ENDPOINT", "AWS_ENDPOINT_URL", "AWS_ENDPOINT_URL_STS", "AWS_CA_BUNDLE"} {
+		t.Setenv(env, "")
+	}
+	return dir
+}
+
+// hostRecorder answers every request with a GetCallerIdentity response and records the host.
+type hostRecorder struct {
+	hosts []string
+}
+
+func (r *hostRecorder) Do(req *http.Request) (*http.Response, error) {
+	r.hosts = append(r.hosts, req.URL.Host)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/xml"}},
+		Body:       io.NopCloser(strings.NewReader(callerIdentityResponse)),
+		Request:    req,
+	}, nil
+}
+
+func TestEndpointOptions_STSHost(t *testing.T) {
+	isolateAWSConfig(t)
+	creds := aws.Credentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret"}
+
+	tests := []struct {
+		name     string
+		opts     endpointOptions
+		wantHost string
+	}{
+		{"default region", endpointOptions{stsEndpoints: stsEndpointsRegional}, "sts.us-east-1.amazonaws.com"},
+		{"regional", endpointOptions{region: "eu-west-1", stsEndpoints: stsEndpointsRegional}, "sts.eu-west-1.amazonaws.com"},
+		{"legacy", endpointOptions{region: "eu-west-1", stsEndpoints: stsEndpointsLegacy}, "sts.amazonaws.com"},
+		{"fips", endpointOptions{region: "us-east-1", useFIPS: true, stsEndpoints: stsEndpointsRegional}, "sts-fips.us-east-1.amazonaws.com"},
+		{"govcloud", endpointOptions{region: "us-gov-west-1", stsEndpoints: stsEndpointsRegional}, "sts.us-gov-west-1.amazonaws.com"},
+		{"custom", endpointOptions{region: "us-gov-west-1", useFIPS: true, stsEndpoint: "https://sts.proxy.example", stsEndpoints: stsEndpointsRegional}, "sts.proxy.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &hostRecorder{}
+			cfg, err := tt.opts.load(context.Background(), awsConfig.WithHTTPClient(recorder),
+				awsConfig.WithCredentialsProvider(awsCredentials.NewStaticCredentialsProvider(creds.AccessKeyID, creds.SecretAccessKey, "")))
+			if err != nil {
+				t.Fatalf("load returned error: %v", err)
+			}
+			if _, err := aws.GetCallerIdentity(context.Background(), tt.opts.newSTSClient(cfg)); err != nil {
+				t.Fatalf("GetCallerIdentity returned error: %v", err)
+			}
+			if len(recorder.hosts) != 1 || recorder.hosts[0] != tt.wantHost {
+				t.Errorf("Expected request to %s, got %v", tt.wantHost, recorder.hosts)
+			}
+		})
+	}
+}
+
+func TestCredentialsSTSClient_CustomEndpoint(t *testing.T) {
+	isolateAWSConfig(t)
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "GetCallerIdentity" {
+			t.Errorf("Unexpected request: %v (%v)", r.Form, err)
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		io.WriteString(w, callerIdentityResponse)
+	}))
+	defer server.Close()
+
+	endpoints := &endpointOptions{stsEndpoint: server.URL, stsEndpoints: stsEndpointsRegional}
+	client, err := credentialsSTSClient(context.Background(), aws.Credentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret"}, endpoints)
+	if err != nil {
+		t.Fatalf("credentialsSTSClient returned error: %v", err)
+	}
+	identity, err := aws.GetCallerIdentity(context.Background(), client)
+	if err != nil {
+		t.Fatalf("GetCallerIdentity returned error: %v", err)
+	}
+	if calls != 1 || identity.Account != "123456789012" {
+		t.Errorf("Expected one call returning account 123456789012, got %d calls and %+v", calls, identity)
+	}
+}
+
+func TestLoadAWSConfig_Region(t *testing.T) {
+	dir := isolateAWSConfig(t)
+	if err := os.WriteFile(filepath.Join(dir, "config"), []byte("[profile gov]\nregion = us-gov-west-1\n"), 0600); err != nil {
+		t.Fatalf("Failed to write AWS config: %v", err)
+	}
+	ctx := context.Background()
+
+	cfg, err := loadAWSConfig(ctx, "gov", &endpointOptions{})
+	if err != nil || cfg.Region != "us-gov-west-1" {
+		t.Errorf("Expected region from the shared config profile, got %q (err %v)", cfg.Region, err)
+	}
+	cfg, err = loadAWSConfig(ctx, "gov", &endpointOptions{region: "us-gov-east-1"})
+	if err != nil || cfg.Region != "us-gov-east-1" {
+		t.Errorf("Expected --region to win, got %q (err %v)", cfg.Region, err)
+	}
+	cfg, err = (&endpointOptions{}).load(ctx)
+	if err != nil || cfg.Region != defaultRegion {
+		t.Errorf("Expected %s when no region is configured, got %q (err %v)", defaultRegion, cfg.Region, err)
+	}
+	// The region comes from the App's environment, not the process's.
+	env := map[string]string{"AWS_DEFAULT_REGION": "eu-north-1"}
+	cfg, err = loadAWSConfig(ctx, "gov", &endpointOptions{getenv: func(k string) string { return env[k] }})
+	if err != nil || cfg.Region != "eu-north-1" {
+		t.Errorf("Expected the region from the environment, got %q (err %v)", cfg.Region, err)
+	}
+}
+
+func TestEndpointOptions_Validate(t *testing.T) {
+	tests := []struct {
+		opts    endpointOptions
+		wantErr bool
+	}{
+		{endpointOptions{stsEndpoints: stsEndpointsRegional}, false},
+		{endpointOptions{stsEndpoints: stsEndpointsLegacy, stsEndpoint: "http://localhost:4566"}, false},
+		{endpointOptions{stsEndpoints: "global"}, true},
+		{endpointOptions{stsEndpoints: stsEndpointsRegional, stsEndpoint: "localhost:4566"}, true},
+	}
+	for _, tt := range tests {
+		err := tt.opts.validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validate(%+v) returned %v, want error %v", tt.opts, err, tt.wantErr)
+		}
+		if err != nil && exitCode(err) != exitConfigError {
+			t.Errorf("Expected a config error, got exit code %d", exitCode(err))
+		}
+	}
+}
+
+func TestApplySettings_Endpoints(t *testing.T) {
+	env := map[string]string{"AWS_STS_REGIONAL_ENDPOINTS": stsEndpointsLegacy}
+	getenv := func(k string) string { return env[k] }
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	endpoints := addEndpointFlags(flags, getenv)
+	if err := flags.Parse(nil); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	profile := config.Profile{STSEndpoint: "https://sts.example.internal", UseFIPS: true, STSRegionalEndpoints: stsEndpointsRegional}
+	if err := applySettings(flags, profile, getenv); err != nil {
+		t.Fatalf("applySettings returned error: %v", err)
+	}
+	if endpoints.stsEndpoint != "https://sts.example.internal" || !endpoints.useFIPS || endpoints.stsEndpoints != stsEndpointsLegacy {
+		t.Errorf("Unexpected endpoint options: %+v", *endpoints)
+	}
+}
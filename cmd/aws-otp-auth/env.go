@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/spf13/pflag"
+)
+
+// credentialEnvVars are the variables set from a profile's credentials. Inherited
+// values, and the profile selection variables, are dropped so they cannot take precedence.
+var credentialEnvVars = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_SECURITY_TOKEN",
+	"AWS_CREDENTIAL_EXPIRATION",
+	"AWS_PROFILE",
+	"AWS_DEFAULT_PROFILE",
+}
+
+// targetFlags registers the flags that select the profile whose credentials are used.
+func targetFlags(flags *pflag.FlagSet) (profileTo, configPath *string) {
+	profileTo = flags.StringP("profile-to", "t", "default", "AWS profile whose credentials are used")
+	configPath = flags.String("config", "", "Path to the config file (default ~/.config/aws-otp-auth/config.toml)")
+	return profileTo, configPath
+}
+
+// applyTarget fills in --profile-to from the environment and the named (or default) config profile.
+func (a *App) applyTarget(flags *pflag.FlagSet, configPath, name string) error {
+	cfgFile, err := loadConfig(configPath)
+	if err != nil {
+		return newConfigError(err)
+	}
+	profile, _, err := cfgFile.Lookup(name)
+	if err != nil {
+		return newConfigError(err)
+	}
+	if err := applySettings(flags, profile, a.getenv); err != nil {
+		return newConfigError(err)
+	}
+	return nil
+}
+
+// sessionEnv returns the environment variables that pass the profile's stored
+// credentials to other tools, as "key=value" pairs.
+func (a *App) sessionEnv(profile string) ([]string, error) {
+	creds, err := aws.ReadAWSCredentials(profile)
+	if err != nil {
+		return nil, err
+	}
+	if !creds.Expiration.IsZero() && !a.Now().Before(creds.Expiration) {
+		return nil, fmt.Errorf("the session in profile %s expired at %s; run 'aws-otp-auth login' to refresh it", profile, creds.Expiration.Local().Format(time.RFC3339))
+	}
+	secrets.Add(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken)
+
+	env := []string{
+		"AWS_ACCESS_KEY_ID=" + creds.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY=" + creds.SecretAccessKey,
+	}
+	if creds.SessionToken != "" {
+		env = append(env, "AWS_SESSION_TOKEN="+creds.SessionToken)
+	}
+	if !creds.Expiration.IsZero() {
+		env = append(env, "AWS_CREDENTIAL_EXPIRATION="+creds.Expiration.UTC().Format(time.RFC3339))
+	}
+	return env, nil
+}
+
+// runEnv implements the "env" subcommand.
+func (a *App) runEnv(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("env", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	profileTo, configPath := targetFlags(flags)
+	format := flags.String("format", "sh", "Output format: sh, fish or json")
+	flags.Usage = func() {
+		fmt.Fprintln(a.Stderr, "Usage: aws-otp-auth env [flags] [config-profile]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return newConfigError(err)
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return newConfigError(fmt.Errorf("too many arguments"))
+	}
+	if err := a.applyTarget(flags, *configPath, flags.Arg(0)); err != nil {
+		return err
+	}
+
+	env, err := a.sessionEnv(*profileTo)
+	if err != nil {
+		return err
+	}
+	return writeEnv(a.Stdout, env, *format)
+}
+
+// writeEnv prints the "key=value" pairs as shell commands or JSON.
+func writeEnv(out io.Writer, env []string, format string) error {
+	switch format {
+	case "sh":
+		for _, kv := range env {
+			k, v, _ := strings.Cut(kv, "=")
+			fmt.Fprintf(out, "export %s='%s'\n", k, strings.ReplaceAll(v, "'", `'\''`))
+		}
+	case "fish":
+		for _, kv := range env {
+			k, v, _ := strings.Cut(kv, "=")
+			v = strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(v)
+			fmt.Fprintf(out, "set -gx %s '%s'\n", k, v)
+		}
+	case "json":
+		vars := map[string]string{}
+		for _, kv := range env {
+			k, v, _ := strings.Cut(kv, "=")
+			vars[k] = v
+		}
+		return writeJSON(out, vars)
+	default:
+		return newConfigError(fmt.Errorf("unsupported env format %q", format))
+	}
+	return nil
+}
+
+// runExec implements the "exec" subcommand.
+func (a *App) runExec(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("exec", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	profileTo, configPath := targetFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(a.Stderr, "Usage: aws-otp-auth exec [flags] [config-profile] -- command [args...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return newConfigError(err)
+	}
+	dash := flags.ArgsLenAtDash()
+	if dash < 0 || dash > 1 || flags.NArg() == dash {
+		flags.Usage()
+		return newConfigError(fmt.Errorf("expected an optional config profile, then -- and the command to run"))
+	}
+	name := ""
+	if dash == 1 {
+		name = flags.Arg(0)
+	}
+	if err := a.applyTarget(flags, *configPath, name); err != nil {
+		return err
+	}
+
+	env, err := a.sessionEnv(*profileTo)
+	if err != nil {
+		return err
+	}
+	command := flags.Args()[dash:]
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(withoutEnv(a.Environ(), credentialEnvVars), env...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = a.Stdin, a.Stdout, a.Stderr
+
+	err = a.RunCommand(cmd)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return &exitStatus{code: exitErr.ExitCode()}
+	} else if err != nil {
+		return fmt.Errorf("failed to run %s: %w", command[0], err)
+	}
+	return nil
+}
+
+// withoutEnv returns environ without the given variables.
+func withoutEnv(environ, keys []string) []string {
+	out := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		k, _, _ := strings.Cut(kv, "=")
+		drop := false
+		for _, key := range keys {
+			drop = drop || k == key
+		}
+		if !drop {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
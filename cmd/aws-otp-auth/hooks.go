@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/auth"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/crbanman/aws-otp-auth/pkg/config"
+	"github.com/spf13/pflag"
+)
+
+// defaultHookTimeout is how long a hook may run before it is killed.
+const defaultHookTimeout = 30 * time.Second
+
+// hookSettings are the external commands run around a refresh of one profile.
+type hookSettings struct {
+	PreRefresh  string
+	PostRefresh string
+	Timeout     time.Duration
+	Secrets     bool
+}
+
+// addHookFlags registers the hook flags.
+func addHookFlags(flags *pflag.FlagSet) *hookSettings {
+	s := &hookSettings{}
+	flags.StringVar(&s.PreRefresh, "pre-refresh", "", "Shell command to run before requesting new credentials")
+	flags.StringVar(&s.PostRefresh, "post-refresh", "", "Shell command to run after new credentials were written")
+	flags.DurationVar(&s.Timeout, "hook-timeout", defaultHookTimeout, "How long a hook may run before it is killed")
+	flags.BoolVar(&s.Secrets, "hook-secrets", false, "Pass the new credentials to --post-refresh in AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN")
+	return s
+}
+
+// hookRunner runs the hooks of each profile and collects their failures. A failing
+// hook is reported but does not undo or prevent the refresh.
+type hookRunner struct {
+	app      *App
+	settings func(profile string) hookSettings
+	failures map[string][]string
+}
+
+// newHookRunner returns a runner that looks up the hooks of a profile with settings.
+func (a *App) newHookRunner(settings func(profile string) hookSettings) *hookRunner {
+	return &hookRunner{app: a, settings: settings, failures: map[string][]string{}}
+}
+
+// hooks returns the hooks to pass to the authentication flow.
+func (h *hookRunner) hooks() auth.Hooks {
+	return auth.Hooks{
+		BeforeRefresh: func(ctx context.Context, req auth.Request) error {
+			s := h.settings(req.Profile)
+			if s.PreRefresh != "" {
+				h.run(ctx, "pre-refresh", req.Profile, s.PreRefresh, s.Timeout, hookEnv(req, nil, false))
+			}
+			return nil
+		},
+		AfterRefresh: func(ctx context.Context, req auth.Request, res *auth.Result, creds *aws.SessionCredentials) {
+			s := h.settings(req.Profile)
+			if s.PostRefresh != "" {
+				h.run(ctx, "post-refresh", req.Profile, s.PostRefresh, s.Timeout, hookEnv(req, creds, s.Secrets))
+			}
+		},
+	}
+}
+
+// run runs command with sh, sending its output to stderr so that it cannot mix
+// with the command's own output, and records a failure for profile.
+func (h *hookRunner) run(ctx context.Context, name, profile, command string, timeout time.Duration, env []string) {
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(withoutEnv(h.app.Environ(), credentialEnvVars), env...)
+	cmd.Stdout = h.app.Stderr
+	cmd.Stderr = h.app.Stderr
+	// Do not wait for background processes started by the hook that keep its output open.
+	cmd.WaitDelay = time.Second
+
+	slog.Debug("Running hook", "hook", name, "profile", profile, "command", command)
+	err := h.app.RunCommand(cmd)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	if err == nil {
+		return
+	}
+	msg := fmt.Sprintf("%s hook failed: %v", name, err)
+	slog.Warn("Hook failed", "hook", name, "profile", profile, "err", err)
+	fmt.Fprintf(h.app.Stderr, "Warning: %s for profile %s\n", msg, profile)
+	h.failures[profile] = append(h.failures[profile], msg)
+}
+
+// hookEnv returns the variables describing a refresh to a hook. The credentials are
+// only included if withSecrets is set.
+func hookEnv(req auth.Request, creds *aws.SessionCredentials, withSecrets bool) []string {
+	env := []string{"AWS_OTP_AUTH_PROFILE=" + req.Profile}
+	account := arnAccount(req.RoleArn)
+	if account == "" {
+		account = arnAccount(req.MFAArn)
+	}
+	if account != "" {
+		env = append(env, "AWS_OTP_AUTH_ACCOUNT="+account)
+	}
+	if req.RoleArn != "" {
+		env = append(env, "AWS_OTP_AUTH_ROLE_ARN="+req.RoleArn)
+	}
+	if creds == nil {
+		return env
+	}
+	// The profile now holds the new session, so tools run by the hook can use it.
+	env = append(env, "AWS_PROFILE="+req.Profile)
+	if !creds.Expiration.IsZero() {
+		env = append(env, "AWS_OTP_AUTH_EXPIRATION="+creds.Expiration.UTC().Format(time.RFC3339))
+	}
+	if withSecrets {
+		env = append(env,
+			"AWS_ACCESS_KEY_ID="+creds.AccessKeyID,
+			"AWS_SECRET_ACCESS_KEY="+creds.SecretAccessKey,
+			"AWS_SESSION_TOKEN="+creds.SessionToken,
+		)
+	}
+	return env
+}
+
+// arnAccount returns the account ID in arn, or "" if it is not an ARN with one.
+func arnAccount(arn string) string {
+	if !strings.HasPrefix(arn, "arn:") {
+		return ""
+	}
+	if parts := strings.Split(arn, ":"); len(parts) > 4 {
+		return parts[4]
+	}
+	return ""
+}
+
+// profileHooks returns the hooks of a config profile, overridden by the hook flags
+// given on the command line.
+func profileHooks(flags *pflag.FlagSet, fromFlags *hookSettings, p config.Profile) (hookSettings, error) {
+	s := hookSettings{PreRefresh: p.PreRefresh, PostRefresh: p.PostRefresh, Timeout: defaultHookTimeout, Secrets: p.HookSecrets}
+	if p.HookTimeout != "" {
+		d, err := time.ParseDuration(p.HookTimeout)
+		if err != nil {
+			return s, fmt.Errorf("invalid hook_timeout %q: %w", p.HookTimeout, err)
+		}
+		s.Timeout = d
+	}
+	if flags.Changed("pre-refresh") {
+		s.PreRefresh = fromFlags.PreRefresh
+	}
+	if flags.Changed("post-refresh") {
+		s.PostRefresh = fromFlags.PostRefresh
+	}
+	if flags.Changed("hook-timeout") {
+		s.Timeout = fromFlags.Timeout
+	}
+	if flags.Changed("hook-secrets") {
+		s.Secrets = fromFlags.Secrets
+	}
+	return s, nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/auth"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/crbanman/aws-otp-auth/pkg/config"
+	"github.com/spf13/pflag"
+	"gopkg.in/ini.v1"
+)
+
+func TestHookEnv(t *testing.T) {
+	expiration := time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC)
+	creds := &aws.SessionCredentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "SECRET", SessionToken: "TOKEN", Expiration: expiration}
+
+	tests := []struct {
+		name    string
+		req     auth.Request
+		creds   *aws.SessionCredentials
+		secrets bool
+		want    []string
+	}{
+		{
+			name: "pre-refresh",
+			req:  auth.Request{Profile: "default", MFAArn: testMFAArn},
+			want: []string{"AWS_OTP_AUTH_PROFILE=default", "AWS_OTP_AUTH_ACCOUNT=123456789012"},
+		},
+		{
+			name:  "post-refresh with role",
+			req:   auth.Request{Profile: "admin", MFAArn: "GAHT12345678", RoleArn: "arn:aws:iam::210987654321:role/Admin"},
+			creds: creds,
+			want: []string{"AWS_OTP_AUTH_PROFILE=admin", "AWS_OTP_AUTH_ACCOUNT=210987654321", "AWS_OTP_AUTH_ROLE_ARN=arn:aws:iam::210987654321:role/Admin",
+				"AWS_PROFILE=admin", "AWS_OTP_AUTH_EXPIRATION=2025-03-01T20:00:00Z"},
+		},
+		{
+			name:    "post-refresh with secrets",
+			req:     auth.Request{Profile: "default", MFAArn: "GAHT12345678"},
+			creds:   creds,
+			secrets: true,
+			want: []string{"AWS_OTP_AUTH_PROFILE=default", "AWS_PROFILE=default", "AWS_OTP_AUTH_EXPIRATION=2025-03-01T20:00:00Z",
+				"AWS_ACCESS_KEY_ID=ASIAEXAMPLE", "AWS_SECRET_ACCESS_KEY=SECRET", "AWS_SESSION_TOKEN=TOKEN"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hookEnv(tt.req, tt.creds, tt.secrets); !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProfileHooks(t *testing.T) {
+	p := config.Profile{PreRefresh: "pre", PostRefresh: "post", HookTimeout: "1m", HookSecrets: true}
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fromFlags := addHookFlags(flags)
+	got, err := profileHooks(flags, fromFlags, p)
+	if err != nil {
+		t.Fatalf("profileHooks returned error: %v", err)
+	}
+	if want := (hookSettings{PreRefresh: "pre", PostRefresh: "post", Timeout: time.Minute, Secrets: true}); got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if err := flags.Parse([]string{"--post-refresh", "other", "--hook-secrets=false"}); err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	got, _ = profileHooks(flags, fromFlags, p)
+	if want := (hookSettings{PreRefresh: "pre", PostRefresh: "other", Timeout: time.Minute}); got != want {
+		t.Errorf("Expected flags to take precedence, got %+v", got)
+	}
+}
+
+// newHookTestApp returns an App that logs in to default with a mock STS client and runs hooks with sh.
+func newHookTestApp(t *testing.T, out *bytes.Buffer) *App {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeCredentials(t, home, "[default-long-term]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = SECRET\n")
+
+	app := newTestApp(out)
+	app.Environ = func() []string {
+		return []string{"PATH=" + os.Getenv("PATH"), "AWS_SESSION_TOKEN=INHERITED", "AWS_PROFILE=other"}
+	}
+	app.Clients = func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+		return &AWSClients{STS: &mockSTSCombinedClient{CheckValid: true, SessionTokenValid: true}, IAM: &fakeIAMClient{}}, nil
+	}
+	app.CredentialsClient = func(ctx context.Context, creds aws.Credentials, endpoints *endpointOptions) (aws.STSClient, error) {
+		return nil, errors.New("not reachable")
+	}
+	app.RunCommand = (*exec.Cmd).Run
+	return app
+}
+
+func TestAppRun_LoginHooks(t *testing.T) {
+	var out bytes.Buffer
+	app := newHookTestApp(t, &out)
+	dir := t.TempDir()
+	pre, post := filepath.Join(dir, "pre"), filepath.Join(dir, "post")
+
+	args := []string{"--otp", "123456", "--mfa-arn", testMFAArn, "--output", "json",
+		"--pre-refresh", "env > " + pre, "--post-refresh", "env > " + post, "--hook-secrets"}
+	if code := app.Run(context.Background(), args); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+
+	data, err := os.ReadFile(pre)
+	if err != nil {
+		t.Fatalf("Expected the pre-refresh hook to run: %v", err)
+	}
+	preEnv := strings.Split(string(data), "\n")
+	for _, want := range []string{"AWS_OTP_AUTH_PROFILE=default", "AWS_OTP_AUTH_ACCOUNT=123456789012"} {
+		if !slices.Contains(preEnv, want) {
+			t.Errorf("Expected %s in the pre-refresh environment:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "INHERITED") || strings.Contains(string(data), "AWS_PROFILE=") {
+		t.Errorf("Expected inherited credential variables to be dropped:\n%s", data)
+	}
+
+	data, err = os.ReadFile(post)
+	if err != nil {
+		t.Fatalf("Expected the post-refresh hook to run: %v", err)
+	}
+	postEnv := strings.Split(string(data), "\n")
+	for _, want := range []string{"AWS_OTP_AUTH_PROFILE=default", "AWS_PROFILE=default", "AWS_SESSION_TOKEN=newSessionToken"} {
+		if !slices.Contains(postEnv, want) {
+			t.Errorf("Expected %s in the post-refresh environment:\n%s", want, data)
+		}
+	}
+	if !strings.Contains(string(data), "AWS_OTP_AUTH_EXPIRATION=") {
+		t.Errorf("Expected the expiration in the post-refresh environment:\n%s", data)
+	}
+
+	// The session is now valid, so the hooks do not run again.
+	os.Remove(pre)
+	if code := app.Run(context.Background(), args); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if _, err := os.Stat(pre); err == nil {
+		t.Errorf("Expected no hook to run for a valid session")
+	}
+}
+
+func TestAppRun_LoginHookFailures(t *testing.T) {
+	var out, stderr bytes.Buffer
+	app := newHookTestApp(t, &out)
+	app.Stderr = &stderr
+
+	start := time.Now()
+	args := []string{"--otp", "123456", "--mfa-arn", testMFAArn, "--output", "json",
+		"--pre-refresh", "sleep 10", "--hook-timeout", "200ms", "--post-refresh", "echo post output; exit 3"}
+	if code := app.Run(context.Background(), args); code != exitOK {
+		t.Fatalf("Expected failing hooks not to change the exit code, got %d", code)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the pre-refresh hook to be killed, took %s", elapsed)
+	}
+
+	var result authResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode JSON output: %v", err)
+	}
+	if result.Action != ActionRefreshed || len(result.HookErrors) != 2 {
+		t.Fatalf("Expected a refresh with two hook errors, got %+v", result)
+	}
+	if !strings.Contains(result.HookErrors[0], "timed out") || !strings.Contains(result.HookErrors[1], "exit status 3") {
+		t.Errorf("Unexpected hook errors: %v", result.HookErrors)
+	}
+	if !strings.Contains(stderr.String(), "post output") || !strings.Contains(stderr.String(), "Warning: post-refresh hook failed") {
+		t.Errorf("Expected hook output and warnings on stderr, got %q", stderr.String())
+	}
+
+	// The credentials written before the failing hook are kept.
+	cfg, err := ini.Load(filepath.Join(os.Getenv("HOME"), ".aws", "credentials"))
+	if err != nil {
+		t.Fatalf("Failed to load credentials file: %v", err)
+	}
+	if got := cfg.Section("default").Key("aws_session_token").String(); got != "newSessionToken" {
+		t.Errorf("Expected the new session to be kept, got %s", got)
+	}
+}
+
+func TestRefreshTargets_Hooks(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeCredentials(t, home, "")
+
+	targets := []batchTarget{
+		{Name: "dev", Profile: "default", Duration: 28800},
+		{Name: "prod", Profile: "prod-admin", RoleArn: "arn:aws:iam::210987654321:role/Admin", Duration: 3600},
+	}
+	newRoleClient := func(session *aws.SessionCredentials) aws.STSAssumeRoleClient {
+		return &roleClient{Session: session}
+	}
+	var after []string
+	hooks := auth.Hooks{
+		BeforeRefresh: func(ctx context.Context, req auth.Request) error {
+			if req.Profile == "prod-admin" {
+				return errors.New("change freeze")
+			}
+			return nil
+		},
+		AfterRefresh: func(ctx context.Context, req auth.Request, res *auth.Result, creds *aws.SessionCredentials) {
+			after = append(after, req.Profile+" "+creds.SessionToken)
+		},
+	}
+
+	authenticator := auth.New(&countingSessionClient{}, auth.WithOTPProvider(auth.StaticOTP("123456")), auth.WithRoleClient(newRoleClient), auth.WithHooks(hooks))
+	results, err := refreshTargets(context.Background(), authenticator, targets, testMFAArn, 28800, false)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 profiles failed") {
+		t.Errorf("Expected the skipped profile to be reported, got %v", err)
+	}
+	if results[0].Action != ActionRefreshed || results[1].Action != ActionFailed || !strings.Contains(results[1].Err.Error(), "change freeze") {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+	if !slices.Equal(after, []string{"default sessionToken"}) {
+		t.Errorf("Expected the post-refresh hook for default only, got %v", after)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/crbanman/aws-otp-auth/pkg/logging"
+	"github.com/spf13/pflag"
+)
+
+// secrets collects the credentials and OTPs handled by this process so they are scrubbed from logs.
+var secrets = &logging.Redactor{}
+
+// logOptions holds the logging flags shared by the commands.
+type logOptions struct {
+	level  string
+	format string
+	file   string
+}
+
+// addLogFlags registers the logging flags on flags.
+func addLogFlags(flags *pflag.FlagSet) *logOptions {
+	o := &logOptions{}
+	flags.StringVar(&o.level, "log-level", "warn", "Log level: debug, info, warn or error")
+	flags.StringVar(&o.format, "log-format", "text", "Log format: text or json")
+	flags.StringVar(&o.file, "log-file", "", "Append logs to this file instead of stderr")
+	return o
+}
+
+// setup installs the default logger described by the options, writing to stderr
+// unless a log file is set. The returned function closes the log file, if any.
+func (o *logOptions) setup(stderr io.Writer) (func(), error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(o.level)); err != nil {
+		return nil, newConfigError(fmt.Errorf("invalid log level %q", o.level))
+	}
+	w := stderr
+	closeLog := func() {}
+	if o.file != "" {
+		f, err := os.OpenFile(o.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, newConfigError(fmt.Errorf("failed to open log file: %w", err))
+		}
+		w = f
+		closeLog = func() { f.Close() }
+	}
+	logger, err := logging.New(w, level, o.format, secrets)
+	if err != nil {
+		closeLog()
+		return nil, newConfigError(err)
+	}
+	slog.SetDefault(logger)
+	return closeLog, nil
+}
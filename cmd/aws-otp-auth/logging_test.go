@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/auth"
+)
+
+func TestAuthenticate_LogsNoSecrets(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	awsDir := filepath.Join(tempHome, ".aws")
+	if err := os.MkdirAll(awsDir, 0755); err != nil {
+		t.Fatalf("Failed to create .aws directory: %v", err)
+	}
+	content := `[default]
+aws_access_key_id = DUMMYKEY
+aws_secret_access_key = DUMMYSECRET
+aws_session_token = DUMMYTOKEN
+aws_session_token_expiration = ` + time.Now().Add(-time.Hour).Format(time.RFC3339) + `
+`
+	if err := os.WriteFile(filepath.Join(awsDir, "credentials"), []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write credentials file: %v", err)
+	}
+
+	for _, format := range []string{"text", "json"} {
+		logPath := filepath.Join(tempHome, "aws-otp-auth-"+format+".log")
+		closeLog, err := (&logOptions{level: "debug", format: format, file: logPath}).setup(io.Discard)
+		if err != nil {
+			t.Fatalf("setup returned error: %v", err)
+		}
+
+		mockClient := &mockSTSCombinedClient{SessionTokenValid: true}
+		a := auth.New(mockClient, auth.WithOTPProvider(auth.PromptOTP(strings.NewReader("654321\n"))), auth.WithRedactor(secrets))
+		if _, err := a.Authenticate(context.Background(), auth.Request{Profile: "default", MFAArn: "arn:aws:iam::123456789012:mfa/alice", DurationSeconds: 3600, Force: true}); err != nil {
+			t.Fatalf("Authenticate failed: %v", err)
+		}
+		a = auth.New(mockClient, auth.WithOTPProvider(auth.StaticOTP("654321")), auth.WithRedactor(secrets))
+		if _, err := a.Authenticate(context.Background(), auth.Request{Profile: "admin", MFAArn: "arn:aws:iam::123456789012:mfa/alice", RoleArn: "arn:aws:iam::123456789012:role/Admin", DurationSeconds: 3600, Force: true}); err != nil {
+			t.Fatalf("Authenticate failed: %v", err)
+		}
+		// Secrets that reach a log call by mistake are scrubbed too.
+		slog.Debug("leak attempt newSecretKey", "detail", "aws_session_token = roleSessionToken", "otp", "654321")
+		closeLog()
+
+		data, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatalf("Failed to read log file: %v", err)
+		}
+		out := string(data)
+		if !strings.Contains(out, "AWS credentials successfully updated") {
+			t.Errorf("Expected debug logs to be written, got:\n%s", out)
+		}
+		for _, secret := range []string{"DUMMYKEY", "DUMMYSECRET", "DUMMYTOKEN", "654321", "newAccessKey", "newSecretKey", "newSessionToken", "roleAccessKey", "roleSecretKey", "roleSessionToken"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("Secret %q leaked into %s log:\n%s", secret, format, out)
+			}
+		}
+	}
+}
+
+func TestLogOptionsSetup_Invalid(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	if _, err := (&logOptions{level: "loud", format: "text"}).setup(io.Discard); exitCode(err) != exitConfigError {
+		t.Errorf("Expected config error for invalid level, got %v", err)
+	}
+	if _, err := (&logOptions{level: "info", format: "xml"}).setup(io.Discard); exitCode(err) != exitConfigError {
+		t.Errorf("Expected config error for invalid format, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/audit"
+	"github.com/crbanman/aws-otp-auth/pkg/auth"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/spf13/pflag"
+)
+
+// runLogin implements the "login" subcommand, which is also run when no subcommand is given.
+func (a *App) runLogin(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("login", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	profileFrom := flags.StringP("profile-from", "f", "default-long-term", "AWS profile to use for obtaining session credentials")
+	profileTo := flags.StringP("profile-to", "t", "default", "AWS profile to update with new session credentials")
+	mfaArn := flags.StringP("mfa-arn", "m", "", "MFA device ARN to use for authentication (if not provided, will auto lookup)")
+	awsUser := flags.StringP("user", "u", "", "AWS username (if not provided, derived from the source profile's credentials)")
+	otpCode := flags.StringP("otp", "o", "", "One Time Password for authentication")
+	otpProvider := flags.String("otp-provider", "prompt", "How to obtain the OTP: prompt, or totp to generate it from the seed stored by 'mfa enroll --store-seed'")
+	verbose := flags.BoolP("verbose", "v", false, "Shorthand for --log-level info")
+	force := flags.BoolP("force", "F", false, "Force re-authentication even if credentials are valid")
+	duration := durationValue(defaultSessionDuration)
+	flags.VarP(&duration, "duration", "d", "Session duration in seconds or with units such as 12h (1h when assuming a role)")
+	endOfDay := flags.String("end-of-day", "", "Shorten the session so that it ends by this local time (HH:MM)")
+	roleArn := flags.String("role-arn", "", "IAM role to assume with MFA instead of requesting a session token")
+	minRemaining := flags.Duration("min-remaining", defaultMinRemaining, "Renew the session if it expires within this long")
+	validateSession := flags.Bool("validate", false, "Verify an unexpired session with STS GetCallerIdentity before trusting it")
+	validateTimeout := flags.Duration("validate-timeout", 5*time.Second, "How long to wait for STS when validating the session")
+	configPath := flags.String("config", "", "Path to the config file (default ~/.config/aws-otp-auth/config.toml)")
+	output := flags.String("output", "text", "Output format: text or json")
+	detailedExitCode := flags.Bool("detailed-exitcode", false, fmt.Sprintf("Exit with %d instead of %d when the existing session was kept", exitAlreadyValid, exitOK))
+	hookOpts := addHookFlags(flags)
+	endpoints := addEndpointFlags(flags, a.getenv)
+	logOpts := addLogFlags(flags)
+	flags.Usage = func() {
+		a.printUsage(a.Stderr)
+		fmt.Fprintln(a.Stderr, "\nLogin flags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return newConfigError(err)
+	}
+
+	result := authResult{}
+	closeLog := func() {}
+	defer func() { closeLog() }()
+	err := func() error {
+		// Fill in settings from the environment and the named (or default) config profile.
+		if flags.NArg() > 1 {
+			flags.Usage()
+			return newConfigError(fmt.Errorf("too many arguments"))
+		}
+		if err := checkOutputFormat(*output); err != nil {
+			return err
+		}
+		cfgFile, err := loadConfig(*configPath)
+		if err != nil {
+			return newConfigError(err)
+		}
+		profile, _, err := cfgFile.Lookup(flags.Arg(0))
+		if err != nil {
+			return newConfigError(err)
+		}
+		if err := applySettings(flags, profile, a.getenv); err != nil {
+			return newConfigError(err)
+		}
+		if *verbose && !flags.Changed("log-level") {
+			logOpts.level = "info"
+		}
+		if closeLog, err = logOpts.setup(a.Stderr); err != nil {
+			return err
+		}
+		if err := endpoints.validate(); err != nil {
+			return err
+		}
+		kind := aws.SessionTokenDuration
+		if *roleArn != "" {
+			kind = aws.RoleDuration
+			if !flags.Changed("duration") {
+				duration = defaultRoleDuration
+			}
+		}
+		seconds, err := limitDuration(kind, int32(duration), a.Now(), *endOfDay)
+		if err != nil {
+			return newConfigError(err)
+		}
+
+		// The OTP is only obtained, or generated from the stored seed, once it is needed.
+		otpSource, err := a.otpProvider(*otpProvider, *otpCode, *profileFrom)
+		if err != nil {
+			return newConfigError(err)
+		}
+
+		clients, err := a.Clients(ctx, *profileFrom, endpoints)
+		if err != nil {
+			return err
+		}
+
+		// Auto lookup MFA ARN if not provided.
+		if *mfaArn == "" {
+			// Determine the AWS username if not provided.
+			if *awsUser, err = resolveUser(ctx, clients.STS, *awsUser); err != nil {
+				return err
+			}
+			*mfaArn, err = resolveMFAArn(ctx, clients.IAM, *awsUser, *profileFrom, isTerminal(a.Stdin), a.Stdin, a.Stderr)
+			if err != nil {
+				return err
+			}
+			slog.Info("Using MFA device", "mfa_arn", *mfaArn, "user", *awsUser)
+		}
+
+		// The target profile is left alone until new credentials replace its session in
+		// one atomic write, so a failed refresh keeps the old, complete (if expired) session.
+		hooks := a.newHookRunner(func(string) hookSettings { return *hookOpts })
+		opts := []auth.Option{
+			auth.WithOTPProvider(otpSource),
+			auth.WithClock(a.Now),
+			auth.WithMinRemaining(*minRemaining),
+			auth.WithHooks(hooks.hooks()),
+			auth.WithRedactor(secrets),
+		}
+		if *validateSession {
+			opts = append(opts, auth.WithValidator(newSessionValidator(a.credentialsClient(endpoints), *validateTimeout, a.Now)))
+		}
+
+		// Run the authentication flow with the MFA ARN we determined.
+		res, err := auth.New(clients.STS, opts...).Authenticate(ctx, auth.Request{
+			Profile:         *profileTo,
+			MFAArn:          *mfaArn,
+			RoleArn:         *roleArn,
+			DurationSeconds: seconds,
+			Force:           *force,
+		})
+		result.Action = res.Action
+		result.HookErrors = hooks.failures[*profileTo]
+		a.recordAudit(audit.Entry{
+			SourceProfile:   *profileFrom,
+			TargetProfile:   *profileTo,
+			MFAArn:          *mfaArn,
+			RoleArn:         *roleArn,
+			DurationSeconds: seconds,
+			Outcome:         result.Action,
+			ErrorCode:       errorCode(err),
+		})
+		a.trackMFAMismatch(*mfaArn, err)
+		if err != nil {
+			return fmt.Errorf("authentication flow failed: %w", err)
+		}
+		return nil
+	}()
+
+	result.Profile, result.MFAArn, result.RoleArn = *profileTo, *mfaArn, *roleArn
+	if err != nil {
+		result.setError(err)
+	} else if *detailedExitCode && result.Action != ActionRefreshed {
+		err = &exitStatus{code: exitAlreadyValid}
+	}
+	if *output == "json" {
+		if result.Action != ActionFailed {
+			result.describeProfile(ctx, a.credentialsClient(endpoints))
+		}
+		if werr := writeJSON(a.Stdout, result); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
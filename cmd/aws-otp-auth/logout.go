@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/spf13/pflag"
+)
+
+// revokeTarget is a role whose older sessions logout revokes, with the source profile allowed to do so.
+type revokeTarget struct {
+	RoleArn     string
+	ProfileFrom string
+}
+
+// runLogout implements the "logout" subcommand.
+func (a *App) runLogout(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("logout", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	profileTo, configPath := targetFlags(flags)
+	profileFrom := flags.StringP("profile-from", "f", "default-long-term", "AWS profile whose long-term credentials revoke role sessions")
+	roleArn := flags.String("role-arn", "", "IAM role whose sessions --revoke invalidates")
+	all := flags.Bool("all", false, "Log out of every profile that holds a session written by aws-otp-auth")
+	restore := flags.Bool("restore", false, "Put back the profile's contents from before its first session")
+	revoke := flags.Bool("revoke", false, "Also deny the role's sessions issued until now, wherever they are used (needs iam:PutRolePolicy)")
+	output := flags.String("output", "text", "Output format: text or json")
+	endpoints := addEndpointFlags(flags, a.getenv)
+	logOpts := addLogFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(a.Stderr, "Usage: aws-otp-auth logout [flags] [config-profile] | --all")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return newConfigError(err)
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return newConfigError(fmt.Errorf("too many arguments"))
+	}
+	if *all && (flags.NArg() > 0 || flags.Changed("profile-to") || flags.Changed("role-arn")) {
+		return newConfigError(fmt.Errorf("--all cannot be combined with a config profile, --profile-to or --role-arn"))
+	}
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+	closeLog, err := logOpts.setup(a.Stderr)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	// results holds one entry per profile logged out and per role revoked.
+	var results []authResult
+	now := a.Now()
+	err = func() error {
+		var profiles []string
+		var roles map[string][]revokeTarget // by credentials profile
+		if *all {
+			var foreign []string
+			if profiles, foreign, roles, err = allSessionTargets(*configPath, *profileFrom, flags.Changed("profile-from")); err != nil {
+				return err
+			}
+			for _, profile := range foreign {
+				fmt.Fprintf(a.Stderr, "Skipping profile %s: its session was not written by aws-otp-auth; name it to log out of it\n", profile)
+			}
+		} else {
+			if err := a.applyTarget(flags, *configPath, flags.Arg(0)); err != nil {
+				return err
+			}
+			if *revoke && *roleArn == "" {
+				return newConfigError(fmt.Errorf("--revoke needs the role whose sessions to revoke; set --role-arn or role_arn in the config profile"))
+			}
+			profiles = []string{*profileTo}
+			if *roleArn != "" {
+				roles = map[string][]revokeTarget{*profileTo: {{RoleArn: *roleArn, ProfileFrom: *profileFrom}}}
+			}
+		}
+		if *revoke {
+			if err := endpoints.validate(); err != nil {
+				return err
+			}
+		}
+
+		cleared, err := aws.ClearSessions(profiles, *restore)
+		if err != nil {
+			return fmt.Errorf("failed to clear sessions: %w", err)
+		}
+		for _, c := range cleared {
+			slog.Info("Cleared session", "profile", c.Profile, "restored", c.Restored)
+			action := ActionLoggedOut
+			if c.Restored {
+				action = ActionRestored
+			}
+			results = append(results, authResult{Profile: c.Profile, Action: action})
+		}
+		if len(cleared) == 0 && !*all {
+			results = append(results, authResult{Profile: *profileTo, Action: ActionNoSession})
+		}
+		if !*revoke {
+			return nil
+		}
+
+		// With --all, only the roles of sessions that were found are revoked; for a single
+		// profile the role is revoked even if its session was already gone from this machine.
+		revokeFrom := profiles
+		if *all {
+			revokeFrom = nil
+			for _, c := range cleared {
+				revokeFrom = append(revokeFrom, c.Profile)
+			}
+		}
+		revoked := map[string]bool{}
+		for _, profile := range revokeFrom {
+			for _, target := range roles[profile] {
+				if revoked[target.RoleArn] {
+					continue
+				}
+				revoked[target.RoleArn] = true
+				clients, err := a.Clients(ctx, target.ProfileFrom, endpoints)
+				if err != nil {
+					return err
+				}
+				result := authResult{Profile: profile, RoleArn: target.RoleArn, Action: ActionRevoked}
+				err = aws.RevokeRoleSessions(ctx, clients.IAM, target.RoleArn, now)
+				var denied *aws.AccessDeniedError
+				if errors.As(err, &denied) {
+					// Lacking the permission is a warning: the local session is already gone.
+					slog.Warn("Not allowed to revoke role sessions", "role_arn", target.RoleArn, "err", err)
+					fmt.Fprintf(a.Stderr, "Warning: profile %s may not revoke the sessions of role %s (it needs iam:PutRolePolicy on the role); they stay valid until they expire\n", target.ProfileFrom, target.RoleArn)
+					result.setError(err)
+				} else if err != nil {
+					return err
+				} else {
+					slog.Info("Revoked role sessions", "role_arn", target.RoleArn, "before", now)
+				}
+				results = append(results, result)
+			}
+		}
+		return nil
+	}()
+
+	if *output == "json" {
+		if err != nil {
+			failed := authResult{Profile: *profileTo}
+			failed.setError(err)
+			results = append(results, failed)
+		}
+		if results == nil {
+			results = []authResult{}
+		}
+		if werr := writeJSON(a.Stdout, results); werr != nil && err == nil {
+			err = werr
+		}
+		return err
+	}
+	for _, r := range results {
+		switch r.Action {
+		case ActionLoggedOut:
+			fmt.Fprintf(a.Stdout, "Logged out of profile %s\n", r.Profile)
+		case ActionRestored:
+			fmt.Fprintf(a.Stdout, "Logged out of profile %s and restored its previous contents\n", r.Profile)
+		case ActionNoSession:
+			fmt.Fprintf(a.Stdout, "Profile %s holds no session\n", r.Profile)
+		case ActionRevoked:
+			fmt.Fprintf(a.Stdout, "Revoked the sessions of role %s issued before %s\n", r.RoleArn, now.Local().Format(time.RFC3339))
+		}
+	}
+	return err
+}
+
+// allSessionTargets returns every profile in the credentials file that holds a session
+// written by this tool, the profiles holding sessions written by other tools, such as SSO
+// exports, which are left alone, and the roles the config profiles writing to them assume.
+// A config profile's source is used to revoke its role unless --profile-from was given.
+func allSessionTargets(configPath, profileFrom string, profileFromSet bool) (profiles, foreign []string, roles map[string][]revokeTarget, err error) {
+	stored, err := aws.ListAWSCredentials()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, p := range stored {
+		if p.Type != aws.ProfileTypeSession {
+			continue
+		}
+		if p.Managed {
+			profiles = append(profiles, p.Profile)
+		} else {
+			foreign = append(foreign, p.Profile)
+		}
+	}
+
+	cfgFile, err := loadConfig(configPath)
+	if err != nil {
+		return nil, nil, nil, newConfigError(err)
+	}
+	names := make([]string, 0, len(cfgFile.Profiles))
+	for name := range cfgFile.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	roles = map[string][]revokeTarget{}
+	for _, name := range names {
+		p := cfgFile.Profiles[name]
+		if p.RoleArn == "" {
+			continue
+		}
+		target := revokeTarget{RoleArn: p.RoleArn, ProfileFrom: profileFrom}
+		if !profileFromSet && p.Source != "" {
+			target.ProfileFrom = p.Source
+		}
+		profile := p.Target
+		if profile == "" {
+			profile = name
+		}
+		roles[profile] = append(roles[profile], target)
+	}
+	return profiles, foreign, roles, nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+	"gopkg.in/ini.v1"
+)
+
+func TestAppRun_Logout(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	sso := "[sso]\naws_access_key_id = ASIASSO\naws_secret_access_key = SECRET\naws_session_token = TOKEN\n"
+	writeCredentials(t, home, sessionSection("default", now.Add(time.Hour))+sessionSection("dev", now.Add(time.Hour))+sso+
+		"[default-long-term]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = SECRET\n")
+
+	var out, stderr bytes.Buffer
+	app := newTestApp(&out)
+	app.Stderr = &stderr
+	app.Now = func() time.Time { return now }
+	if code := app.Run(context.Background(), []string{"logout"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if out.String() != "Logged out of profile default\n" {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+	cfg, err := ini.Load(filepath.Join(home, ".aws", "credentials"))
+	if err != nil {
+		t.Fatalf("Failed to load credentials file: %v", err)
+	}
+	if len(cfg.Section("default").Keys()) != 0 || !cfg.Section("dev").HasKey("aws_session_token") {
+		t.Errorf("Expected only the default session to be cleared, got default=%v dev=%v", cfg.Section("default").KeysHash(), cfg.Section("dev").KeysHash())
+	}
+
+	out.Reset()
+	if code := app.Run(context.Background(), []string{"logout"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if out.String() != "Profile default holds no session\n" {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+
+	out.Reset()
+	if code := app.Run(context.Background(), []string{"logout", "--all"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if out.String() != "Logged out of profile dev\n" {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+	// Sessions written by other tools are left alone by --all.
+	if !strings.Contains(stderr.String(), "Skipping profile sso") {
+		t.Errorf("Expected the SSO session to be skipped, got %q", stderr.String())
+	}
+	if creds, err := ini.Load(filepath.Join(home, ".aws", "credentials")); err != nil || !creds.Section("sso").HasKey("aws_session_token") {
+		t.Errorf("Expected the SSO session to be kept (err %v)", err)
+	}
+
+	if code := app.Run(context.Background(), []string{"logout", "--all", "--profile-to", "dev"}); code != exitConfigError {
+		t.Errorf("Expected exit code %d for --all with --profile-to, got %d", exitConfigError, code)
+	}
+	if code := app.Run(context.Background(), []string{"logout", "--revoke"}); code != exitConfigError {
+		t.Errorf("Expected exit code %d for --revoke without a role, got %d", exitConfigError, code)
+	}
+}
+
+func TestAppRun_LogoutRevoke(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	writeCredentials(t, home, sessionSection("admin", now.Add(time.Hour))+sessionSection("ops", now.Add(time.Hour)))
+	config := filepath.Join(home, "config.toml")
+	if err := os.WriteFile(config, []byte(`[profiles.admin]
+source = "admin-long-term"
+role_arn = "arn:aws:iam::210987654321:role/Admin"
+
+[profiles.ops]
+role_arn = "arn:aws:iam::210987654321:role/Ops"
+
+[profiles.gone]
+role_arn = "arn:aws:iam::210987654321:role/Gone"
+`), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	var out, stderr bytes.Buffer
+	app := newTestApp(&out)
+	app.Stderr = &stderr
+	app.Now = func() time.Time { return now }
+	iam := &fakeIAMClient{}
+	var sources []string
+	app.Clients = func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+		sources = append(sources, profile)
+		return &AWSClients{IAM: iam}, nil
+	}
+
+	if code := app.Run(context.Background(), []string{"logout", "--config", config, "--revoke", "--all"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (%s)", exitOK, code, stderr.String())
+	}
+	if len(iam.Policies) != 2 {
+		t.Fatalf("Expected the roles of the two sessions to be revoked, got %d policies", len(iam.Policies))
+	}
+	if got := awsSdk.ToString(iam.Policies[0].RoleName) + "," + awsSdk.ToString(iam.Policies[1].RoleName); got != "Admin,Ops" {
+		t.Errorf("Expected roles Admin and Ops to be revoked, got %s", got)
+	}
+	if !strings.Contains(awsSdk.ToString(iam.Policies[0].PolicyDocument), `"aws:TokenIssueTime":"2025-03-01T12:00:00Z"`) {
+		t.Errorf("Expected the revocation to cover sessions issued before now, got %s", awsSdk.ToString(iam.Policies[0].PolicyDocument))
+	}
+	if got := strings.Join(sources, ","); got != "admin-long-term,default-long-term" {
+		t.Errorf("Expected each role to be revoked with its profile's source, got %s", got)
+	}
+	if !strings.Contains(out.String(), "Revoked the sessions of role arn:aws:iam::210987654321:role/Ops") {
+		t.Errorf("Expected the revocation to be reported, got %q", out.String())
+	}
+
+	// Lacking permission to revoke is a warning; the local session is still cleared.
+	writeCredentials(t, home, sessionSection("admin", now.Add(time.Hour)))
+	iam.PolicyErr = &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform: iam:PutRolePolicy"}
+	stderr.Reset()
+	if code := app.Run(context.Background(), []string{"logout", "--config", config, "--revoke", "--profile-to", "admin", "admin"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (%s)", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "needs iam:PutRolePolicy") {
+		t.Errorf("Expected a warning about the missing permission, got %q", stderr.String())
+	}
+	if creds, err := ini.Load(filepath.Join(home, ".aws", "credentials")); err != nil || creds.Section("admin").HasKey("aws_session_token") {
+		t.Errorf("Expected the admin session to be cleared (err %v)", err)
+	}
+}
+
+func TestAppRun_LogoutOutputJSON(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	writeCredentials(t, home, sessionSection("admin", now.Add(time.Hour)))
+
+	var out bytes.Buffer
+	app := newTestApp(&out)
+	app.Now = func() time.Time { return now }
+	iam := &fakeIAMClient{PolicyErr: &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform: iam:PutRolePolicy"}}
+	app.Clients = func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+		return &AWSClients{IAM: iam}, nil
+	}
+
+	args := []string{"logout", "--profile-to", "admin", "--role-arn", "arn:aws:iam::210987654321:role/Admin", "--revoke", "--output", "json"}
+	if code := app.Run(context.Background(), args); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	var results []authResult
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatalf("Failed to parse output %q: %v", out.String(), err)
+	}
+	if len(results) != 2 || results[0].Action != ActionLoggedOut || results[0].Profile != "admin" {
+		t.Fatalf("Unexpected results: %+v", results)
+	}
+	if results[1].RoleArn != "arn:aws:iam::210987654321:role/Admin" || results[1].Action != ActionFailed || results[1].ErrorCode != "aws_error" {
+		t.Errorf("Expected the denied revocation to be reported, got %+v", results[1])
+	}
+
+	out.Reset()
+	if code := app.Run(context.Background(), []string{"logout", "--profile-to", "admin", "--output", "json"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	results = nil
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil || len(results) != 1 || results[0].Action != ActionNoSession {
+		t.Errorf("Expected no_session, got %+v (err %v)", results, err)
+	}
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/crbanman/aws-otp-auth/pkg/otp"
+	"github.com/spf13/pflag"
+)
+
+// runMFA implements the "mfa" subcommand and its actions.
+func (a *App) runMFA(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return newConfigError(fmt.Errorf("usage: aws-otp-auth mfa enroll|resync|list [flags]"))
+	}
+	switch args[0] {
+	case "enroll":
+		return a.runMFAEnroll(ctx, args[1:])
+	case "resync":
+		return a.runMFAResync(ctx, args[1:])
+	case "list":
+		return a.runMFAList(ctx, args[1:])
+	default:
+		return newConfigError(fmt.Errorf("unknown mfa command %q", args[0]))
+	}
+}
+
+// runMFAEnroll implements "mfa enroll".
+func (a *App) runMFAEnroll(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("mfa enroll", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	profileFrom := flags.StringP("profile-from", "f", "default-long-term", "AWS profile holding the long-term credentials")
+	awsUser := flags.StringP("user", "u", "", "AWS username (if not provided, derived from the source profile's credentials)")
+	deviceName := flags.String("device-name", "", "Name of the virtual MFA device (defaults to the username)")
+	storeSeed := flags.Bool("store-seed", false, "Store the seed for use with --otp-provider totp")
+	output := flags.String("output", "text", "Output format: text or json (the QR code and prompts then go to stderr)")
+	endpoints := addEndpointFlags(flags, a.getenv)
+	logOpts := addLogFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return newConfigError(err)
+	}
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+	closeLog, err := logOpts.setup(a.Stderr)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	result := authResult{Profile: *profileFrom}
+	err = func() error {
+		if err := endpoints.validate(); err != nil {
+			return err
+		}
+		// The seed is stored under the profile name, so reject a bad name before creating the device.
+		if *storeSeed {
+			if err := otp.ValidateSecretName(*profileFrom); err != nil {
+				return newConfigError(err)
+			}
+		}
+		clients, err := a.Clients(ctx, *profileFrom, endpoints)
+		if err != nil {
+			return err
+		}
+		userName, err := resolveUser(ctx, clients.STS, *awsUser)
+		if err != nil {
+			return err
+		}
+		if *deviceName == "" {
+			*deviceName = userName
+		}
+
+		seedName := ""
+		if *storeSeed {
+			seedName = *profileFrom
+		}
+		out := a.Stdout
+		if *output == "json" {
+			out = a.Stderr
+		}
+		result.MFAArn, err = enrollMFADevice(ctx, clients.IAM, userName, *deviceName, seedName, a.Stdin, out)
+		return err
+	}()
+	if *output != "json" {
+		return err
+	}
+	result.Action = ActionEnrolled
+	return writeResultJSON(a.Stdout, &result, err)
+}
+
+// enrollMFADevice creates a virtual MFA device, shows its seed, and enables it
+// with two consecutive codes read from in. If seedName is set, the seed is
+// stored for the built-in TOTP provider once the device is enabled. If the
+// device is not enabled, it is deleted again so that enrollment can be retried.
+func enrollMFADevice(ctx context.Context, client aws.IAMMFAEnrollClient, userName, deviceName, seedName string, in io.Reader, out io.Writer) (string, error) {
+	device, err := aws.CreateVirtualMFADevice(ctx, client, deviceName)
+	if err != nil {
+		return "", err
+	}
+	enabled := false
+	defer func() {
+		if enabled {
+			return
+		}
+		// The cleanup must run even when enrollment was abandoned by cancelling ctx.
+		if err := aws.DeleteVirtualMFADevice(context.WithoutCancel(ctx), client, device.SerialNumber); err != nil {
+			fmt.Fprintf(out, "Warning: %v; delete it before enrolling again.\n", err)
+		}
+	}()
+
+	account := deviceName
+	if parts := strings.Split(device.SerialNumber, ":"); len(parts) > 4 && parts[4] != "" {
+		account = deviceName + "@" + parts[4]
+	}
+	fmt.Fprintf(out, "Created virtual MFA device %s\n\n", device.SerialNumber)
+	if len(device.QRCodePNG) > 0 {
+		if qr, err := otp.RenderQRCode(device.QRCodePNG); err == nil {
+			fmt.Fprintln(out, "Scan this QR code with your authenticator app:")
+			fmt.Fprintln(out, qr)
+		}
+	}
+	fmt.Fprintf(out, "Or add it manually with this URI:\n  %s\n\n", otp.OTPAuthURI("Amazon Web Services", account, device.Seed))
+
+	reader := bufio.NewReader(in)
+	code1, err := promptLine(reader, out, "Enter the first code: ")
+	if err != nil {
+		return "", fmt.Errorf("failed to read first code: %w", err)
+	}
+	code2, err := promptLine(reader, out, "Enter the next code: ")
+	if err != nil {
+		return "", fmt.Errorf("failed to read second code: %w", err)
+	}
+
+	if err := aws.EnableMFADevice(ctx, client, userName, device.SerialNumber, code1, code2); err != nil {
+		return "", err
+	}
+	enabled = true
+	fmt.Fprintf(out, "MFA device %s enabled for user %s.\n", device.SerialNumber, userName)
+
+	if seedName != "" {
+		if err := otp.SaveTOTPSecret(seedName, device.Seed); err != nil {
+			return device.SerialNumber, err
+		}
+		fmt.Fprintf(out, "Seed stored; use --otp-provider totp --profile-from %s to generate codes automatically.\n", seedName)
+	}
+	return device.SerialNumber, nil
+}
+
+// runMFAResync implements "mfa resync".
+func (a *App) runMFAResync(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("mfa resync", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	profileFrom := flags.StringP("profile-from", "f", "default-long-term", "AWS profile holding the long-term credentials")
+	awsUser := flags.StringP("user", "u", "", "AWS username (if not provided, derived from the source profile's credentials)")
+	mfaArn := flags.StringP("mfa-arn", "m", "", "MFA device ARN to resync (if not provided, will auto lookup)")
+	output := flags.String("output", "text", "Output format: text or json (prompts then go to stderr)")
+	endpoints := addEndpointFlags(flags, a.getenv)
+	logOpts := addLogFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return newConfigError(err)
+	}
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+	closeLog, err := logOpts.setup(a.Stderr)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	out := a.Stdout
+	if *output == "json" {
+		out = a.Stderr
+	}
+	result := authResult{Profile: *profileFrom}
+	err = func() error {
+		if err := endpoints.validate(); err != nil {
+			return err
+		}
+		clients, err := a.Clients(ctx, *profileFrom, endpoints)
+		if err != nil {
+			return err
+		}
+		userName, err := resolveUser(ctx, clients.STS, *awsUser)
+		if err != nil {
+			return err
+		}
+		if *mfaArn == "" {
+			if *mfaArn, err = resolveMFAArn(ctx, clients.IAM, userName, *profileFrom, isTerminal(a.Stdin), a.Stdin, out); err != nil {
+				return err
+			}
+		}
+		result.MFAArn = *mfaArn
+		return resyncMFADevice(ctx, clients.IAM, userName, *mfaArn, a.Stdin, out)
+	}()
+	if *output != "json" {
+		return err
+	}
+	result.Action = ActionResynced
+	return writeResultJSON(a.Stdout, &result, err)
+}
+
+// mfaDeviceJSON is an MFA device as reported by "mfa list --output json".
+type mfaDeviceJSON struct {
+	SerialNumber  string    `json:"serial_number"`
+	Type          string    `json:"type"`
+	EnableDate    time.Time `json:"enable_date"`
+	UsableWithSTS bool      `json:"usable_with_sts"`
+}
+
+// runMFAList implements "mfa list".
+func (a *App) runMFAList(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("mfa list", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	profileFrom := flags.StringP("profile-from", "f", "default-long-term", "AWS profile holding the long-term credentials")
+	awsUser := flags.StringP("user", "u", "", "AWS username (if not provided, derived from the source profile's credentials)")
+	output := flags.String("output", "text", "Output format: text or json")
+	endpoints := addEndpointFlags(flags, a.getenv)
+	logOpts := addLogFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return newConfigError(err)
+	}
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+	closeLog, err := logOpts.setup(a.Stderr)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	var devices []aws.MFADevice
+	err = func() error {
+		if err := endpoints.validate(); err != nil {
+			return err
+		}
+		clients, err := a.Clients(ctx, *profileFrom, endpoints)
+		if err != nil {
+			return err
+		}
+		userName, err := resolveUser(ctx, clients.STS, *awsUser)
+		if err != nil {
+			return err
+		}
+		devices, err = aws.ListMFADevices(ctx, clients.IAM, userName)
+		return err
+	}()
+	if *output == "json" {
+		if err != nil {
+			return writeResultJSON(a.Stdout, &authResult{Profile: *profileFrom}, err)
+		}
+		entries := make([]mfaDeviceJSON, 0, len(devices))
+		for _, d := range devices {
+			entries = append(entries, mfaDeviceJSON{SerialNumber: d.SerialNumber, Type: d.Type, EnableDate: d.EnableDate, UsableWithSTS: d.SupportsSessionToken()})
+		}
+		return writeJSON(a.Stdout, entries)
+	}
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(a.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERIAL\tTYPE\tENABLED\tSTS")
+	for _, d := range devices {
+		usable := "yes"
+		if !d.SupportsSessionToken() {
+			usable = "no"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.SerialNumber, d.Type, d.EnableDate.Format("2006-01-02"), usable)
+	}
+	return tw.Flush()
+}
+
+// resyncMFADevice reads two consecutive codes from in and resynchronizes the MFA device with them.
+func resyncMFADevice(ctx context.Context, client aws.IAMMFAResyncClient, userName, serialNumber string, in io.Reader, out io.Writer) error {
+	fmt.Fprintf(out, "Resynchronizing MFA device %s.\n", serialNumber)
+	reader := bufio.NewReader(in)
+	code1, err := promptLine(reader, out, "Enter the current code: ")
+	if err != nil {
+		return fmt.Errorf("failed to read first code: %w", err)
+	}
+	code2, err := promptLine(reader, out, "Wait for the code to change, then enter the next code: ")
+	if err != nil {
+		return fmt.Errorf("failed to read second code: %w", err)
+	}
+
+	if err := aws.ResyncMFADevice(ctx, client, userName, serialNumber, code1, code2); err != nil {
+		return err
+	}
+	if err := resetMFAMismatches(serialNumber); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "MFA device %s resynchronized.\n", serialNumber)
+	return nil
+}
+
+// mfaMismatchThreshold is the number of consecutive rejected codes after which a resync is suggested.
+const mfaMismatchThreshold = 2
+
+// mfaMismatchesFile tracks consecutive rejected codes per MFA device.
+const mfaMismatchesFile = "mfa-mismatches.json"
+
+// recordMFAMismatch increments the count of consecutive rejected codes for the device and returns it.
+func recordMFAMismatch(serialNumber string) (int, error) {
+	counts := map[string]int{}
+	if err := loadState(mfaMismatchesFile, &counts); err != nil {
+		return 0, err
+	}
+	counts[serialNumber]++
+	return counts[serialNumber], saveState(mfaMismatchesFile, counts)
+}
+
+// resetMFAMismatches clears the count of consecutive rejected codes for the device.
+func resetMFAMismatches(serialNumber string) error {
+	counts := map[string]int{}
+	if err := loadState(mfaMismatchesFile, &counts); err != nil {
+		return err
+	}
+	if _, ok := counts[serialNumber]; !ok {
+		return nil
+	}
+	delete(counts, serialNumber)
+	return saveState(mfaMismatchesFile, counts)
+}
+
+// trackMFAMismatch counts a rejected MFA code against the device, suggesting a resync
+// once mfaMismatchThreshold codes in a row have been rejected, and clears the count
+// after a successful authentication. Other failures leave the count alone.
+func (a *App) trackMFAMismatch(serialNumber string, err error) {
+	switch {
+	case err == nil:
+		_ = resetMFAMismatches(serialNumber)
+	case aws.IsInvalidMFACode(err):
+		if count, _ := recordMFAMismatch(serialNumber); count >= mfaMismatchThreshold {
+			fmt.Fprintf(a.Stderr, "The MFA code has been rejected %d times in a row; your device may be out of sync. Run 'aws-otp-auth mfa resync' to fix it.\n", count)
+		}
+	}
+}
+
+// mfaDevicesFile remembers the MFA device chosen for each source profile.
+const mfaDevicesFile = "mfa-devices.json"
+
+// resolveMFAArn returns the ARN of the MFA device to use for the source profile:
+// the device remembered from an earlier choice, the user's only device usable
+// with STS, or one picked from a numbered list when interactive is set.
+func resolveMFAArn(ctx context.Context, client aws.IAMListMFADevicesClient, userName, profile string, interactive bool, in io.Reader, out io.Writer) (string, error) {
+	devices, err := aws.ListMFADevices(ctx, client, userName)
+	if err != nil {
+		return "", err
+	}
+	var usable []aws.MFADevice
+	for _, device := range devices {
+		if device.SupportsSessionToken() {
+			usable = append(usable, device)
+		}
+	}
+
+	remembered := map[string]string{}
+	if err := loadState(mfaDevicesFile, &remembered); err != nil {
+		return "", err
+	}
+	for _, device := range usable {
+		if device.SerialNumber == remembered[profile] {
+			slog.Debug("Using remembered MFA device", "profile", profile, "mfa_arn", device.SerialNumber)
+			return device.SerialNumber, nil
+		}
+	}
+
+	switch {
+	case len(usable) == 1:
+		return usable[0].SerialNumber, nil
+	case len(usable) == 0 && len(devices) == 0:
+		return "", fmt.Errorf("no MFA devices found for user %s", userName)
+	case len(usable) == 0:
+		return "", fmt.Errorf("no MFA devices usable with STS found for user %s. Devices:\n%s", userName, formatMFADevices(devices))
+	case !interactive:
+		return "", newConfigError(fmt.Errorf("multiple MFA devices found for user %s. Please specify one with --mfa-arn. Devices:\n%s", userName, formatMFADevices(devices)))
+	}
+
+	fmt.Fprintf(out, "Multiple MFA devices found for user %s:\n", userName)
+	for i, device := range usable {
+		fmt.Fprintf(out, "  %d) %s\n", i+1, describeMFADevice(device))
+	}
+	reader := bufio.NewReader(in)
+	for {
+		answer, err := promptLine(reader, out, fmt.Sprintf("Select MFA device [1-%d]: ", len(usable)))
+		if err != nil {
+			return "", fmt.Errorf("failed to read MFA device choice: %w", err)
+		}
+		choice, err := strconv.Atoi(answer)
+		if err != nil || choice < 1 || choice > len(usable) {
+			fmt.Fprintf(out, "Please enter a number between 1 and %d.\n", len(usable))
+			continue
+		}
+		serial := usable[choice-1].SerialNumber
+		remembered[profile] = serial
+		if err := saveState(mfaDevicesFile, remembered); err != nil {
+			return "", err
+		}
+		return serial, nil
+	}
+}
+
+// describeMFADevice returns a one-line description of the device.
+func describeMFADevice(device aws.MFADevice) string {
+	desc := fmt.Sprintf("%s (%s, enabled %s)", device.SerialNumber, device.Type, device.EnableDate.Format("2006-01-02"))
+	if !device.SupportsSessionToken() {
+		desc += " - not usable with STS"
+	}
+	return desc
+}
+
+// formatMFADevices returns an indented list of device descriptions.
+func formatMFADevices(devices []aws.MFADevice) string {
+	lines := make([]string, 0, len(devices))
+	for _, device := range devices {
+		lines = append(lines, "  "+describeMFADevice(device))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// promptLine writes the prompt and returns the next trimmed line from reader.
+func promptLine(reader *bufio.Reader, out io.Writer, prompt string) (string, error) {
+	fmt.Fprint(out, prompt)
+	line, err := reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
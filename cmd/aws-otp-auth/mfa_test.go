@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/smithy-go"
+	"github.com/crbanman/aws-otp-auth/pkg/otp"
+)
+
+// fakeIAMClient is an in-memory IAM client for the mfa, logout and status subcommands.
+type fakeIAMClient struct {
+	EnableErr  error
+	Enabled    *iam.EnableMFADeviceInput
+	Deleted    []string
+	Resynced   *iam.ResyncMFADeviceInput
+	Devices    []string
+	PolicyErr  error
+	Policies   []*iam.PutRolePolicyInput
+	AccessKeys []types.AccessKeyMetadata
+}
+
+func (f *fakeIAMClient) CreateVirtualMFADevice(ctx context.Context, input *iam.CreateVirtualMFADeviceInput, optFns ...func(*iam.Options)) (*iam.CreateVirtualMFADeviceOutput, error) {
+	return &iam.CreateVirtualMFADeviceOutput{
+		VirtualMFADevice: &types.VirtualMFADevice{
+			SerialNumber:     aws.String("arn:aws:iam::123456789012:mfa/" + aws.ToString(input.VirtualMFADeviceName)),
+			Base32StringSeed: []byte("JBSWY3DPEHPK3PXP"),
+		},
+	}, nil
+}
+
+func (f *fakeIAMClient) EnableMFADevice(ctx context.Context, input *iam.EnableMFADeviceInput, optFns ...func(*iam.Options)) (*iam.EnableMFADeviceOutput, error) {
+	if f.EnableErr != nil {
+		return nil, f.EnableErr
+	}
+	f.Enabled = input
+	return &iam.EnableMFADeviceOutput{}, nil
+}
+
+func (f *fakeIAMClient) DeleteVirtualMFADevice(ctx context.Context, input *iam.DeleteVirtualMFADeviceInput, optFns ...func(*iam.Options)) (*iam.DeleteVirtualMFADeviceOutput, error) {
+	f.Deleted = append(f.Deleted, aws.ToString(input.SerialNumber))
+	return &iam.DeleteVirtualMFADeviceOutput{}, nil
+}
+
+func (f *fakeIAMClient) ResyncMFADevice(ctx context.Context, input *iam.ResyncMFADeviceInput, optFns ...func(*iam.Options)) (*iam.ResyncMFADeviceOutput, error) {
+	f.Resynced = input
+	return &iam.ResyncMFADeviceOutput{}, nil
+}
+
+func (f *fakeIAMClient) ListMFADevices(ctx context.Context, input *iam.ListMFADevicesInput, optFns ...func(*iam.Options)) (*iam.ListMFADevicesOutput, error) {
+	out := &iam.ListMFADevicesOutput{}
+	for _, serial := range f.Devices {
+		out.MFADevices = append(out.MFADevices, types.MFADevice{SerialNumber: aws.String(serial), UserName: input.UserName})
+	}
+	return out, nil
+}
+
+func (f *fakeIAMClient) ListAccessKeys(ctx context.Context, input *iam.ListAccessKeysInput, optFns ...func(*iam.Options)) (*iam.ListAccessKeysOutput, error) {
+	return &iam.ListAccessKeysOutput{AccessKeyMetadata: f.AccessKeys}, nil
+}
+
+func (f *fakeIAMClient) GetAccessKeyLastUsed(ctx context.Context, input *iam.GetAccessKeyLastUsedInput, optFns ...func(*iam.Options)) (*iam.GetAccessKeyLastUsedOutput, error) {
+	return &iam.GetAccessKeyLastUsedOutput{}, nil
+}
+
+func (f *fakeIAMClient) PutRolePolicy(ctx context.Context, input *iam.PutRolePolicyInput, optFns ...func(*iam.Options)) (*iam.PutRolePolicyOutput, error) {
+	if f.PolicyErr != nil {
+		return nil, f.PolicyErr
+	}
+	f.Policies = append(f.Policies, input)
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func TestEnrollMFADevice(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client := &fakeIAMClient{}
+	var out bytes.Buffer
+
+	_, err := enrollMFADevice(context.Background(), client, "alice", "alice-laptop", "default-long-term", strings.NewReader("123456\n654321\n"), &out)
+	if err != nil {
+		t.Fatalf("enrollMFADevice returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "otpauth://totp/Amazon%20Web%20Services:alice-laptop@123456789012?") {
+		t.Errorf("Output missing otpauth URI:\n%s", out.String())
+	}
+	if client.Enabled == nil {
+		t.Fatalf("EnableMFADevice was not called")
+	}
+	if aws.ToString(client.Enabled.UserName) != "alice" ||
+		aws.ToString(client.Enabled.AuthenticationCode1) != "123456" ||
+		aws.ToString(client.Enabled.AuthenticationCode2) != "654321" {
+		t.Errorf("Unexpected EnableMFADevice input: %+v", client.Enabled)
+	}
+
+	secret, err := otp.LoadTOTPSecret("default-long-term")
+	if err != nil {
+		t.Fatalf("Seed was not stored: %v", err)
+	}
+	if secret != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Expected stored seed, got %s", secret)
+	}
+}
+
+func TestEnrollMFADevice_EnableFails(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client := &fakeIAMClient{EnableErr: errors.New("InvalidAuthenticationCode")}
+	var out bytes.Buffer
+
+	_, err := enrollMFADevice(context.Background(), client, "alice", "alice", "default-long-term", strings.NewReader("111111\n222222\n"), &out)
+	if err == nil {
+		t.Fatalf("Expected enrollment to fail")
+	}
+	if len(client.Deleted) != 1 {
+		t.Errorf("Expected the unassigned device to be deleted, got %v", client.Deleted)
+	}
+	if _, err := otp.LoadTOTPSecret("default-long-term"); err == nil {
+		t.Errorf("Seed should not be stored when enabling fails")
+	}
+}
+
+func TestEnrollMFADevice_NoCodes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client := &fakeIAMClient{}
+	var out bytes.Buffer
+
+	// Stdin is closed before any code is entered.
+	_, err := enrollMFADevice(context.Background(), client, "alice", "alice", "", strings.NewReader(""), &out)
+	if err == nil || !strings.Contains(err.Error(), "failed to read first code") {
+		t.Fatalf("Expected enrollment to fail reading the first code, got %v", err)
+	}
+	if client.Enabled != nil {
+		t.Errorf("Expected the device not to be enabled")
+	}
+	if len(client.Deleted) != 1 {
+		t.Errorf("Expected the unassigned device to be deleted, got %v", client.Deleted)
+	}
+}
+
+func TestAppRun_MFAEnrollInvalidSeedName(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	app := newTestApp(&bytes.Buffer{})
+	created := false
+	app.Clients = func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+		created = true
+		return nil, errors.New("unexpected AWS call")
+	}
+	if code := app.Run(context.Background(), []string{"mfa", "enroll", "--store-seed", "--profile-from", "../../.ssh/x"}); code != exitConfigError {
+		t.Errorf("Expected exit code %d, got %d", exitConfigError, code)
+	}
+	if created {
+		t.Errorf("Expected the seed name to be rejected before any AWS call")
+	}
+}
+
+func TestResyncMFADevice(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	serial := "arn:aws:iam::123456789012:mfa/alice"
+	client := &fakeIAMClient{}
+	var out bytes.Buffer
+
+	// Two rejected codes are on record before the resync.
+	for i := 0; i < 2; i++ {
+		if _, err := recordMFAMismatch(serial); err != nil {
+			t.Fatalf("recordMFAMismatch returned error: %v", err)
+		}
+	}
+
+	if err := resyncMFADevice(context.Background(), client, "alice", serial, strings.NewReader("111111\n222222\n"), &out); err != nil {
+		t.Fatalf("resyncMFADevice returned error: %v", err)
+	}
+	if client.Resynced == nil ||
+		aws.ToString(client.Resynced.AuthenticationCode1) != "111111" ||
+		aws.ToString(client.Resynced.AuthenticationCode2) != "222222" {
+		t.Errorf("Unexpected ResyncMFADevice input: %+v", client.Resynced)
+	}
+
+	count, err := recordMFAMismatch(serial)
+	if err != nil {
+		t.Fatalf("recordMFAMismatch returned error: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected mismatch count to restart after resync, got %d", count)
+	}
+}
+
+func TestResolveMFAArn(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	var out bytes.Buffer
+
+	arn, err := resolveMFAArn(ctx, &fakeIAMClient{Devices: []string{"arn:aws:iam::123456789012:mfa/alice"}}, "alice", "dev", false, nil, &out)
+	if err != nil || arn != "arn:aws:iam::123456789012:mfa/alice" {
+		t.Errorf("Expected single device ARN, got %q (err %v)", arn, err)
+	}
+
+	if _, err := resolveMFAArn(ctx, &fakeIAMClient{}, "alice", "dev", false, nil, &out); err == nil || !strings.Contains(err.Error(), "no MFA devices") {
+		t.Errorf("Expected no devices error, got %v", err)
+	}
+
+	// Passkeys are listed but never picked.
+	passkey := "arn:aws:iam::123456789012:u2f/user/alice/key-ABC"
+	arn, err = resolveMFAArn(ctx, &fakeIAMClient{Devices: []string{passkey, "GAHT12345678"}}, "alice", "dev", false, nil, &out)
+	if err != nil || arn != "GAHT12345678" {
+		t.Errorf("Expected hardware device to be chosen over passkey, got %q (err %v)", arn, err)
+	}
+
+	client := &fakeIAMClient{Devices: []string{"arn:aws:iam::123456789012:mfa/phone", "GAHT12345678", passkey}}
+	_, err = resolveMFAArn(ctx, client, "alice", "dev", false, nil, &out)
+	if err == nil || !strings.Contains(err.Error(), "GAHT12345678 (hardware") || !strings.Contains(err.Error(), "not usable with STS") {
+		t.Errorf("Expected multiple devices error describing each device, got %v", err)
+	}
+
+	// An invalid answer is asked again, then the choice is remembered for the profile.
+	out.Reset()
+	arn, err = resolveMFAArn(ctx, client, "alice", "dev", true, strings.NewReader("5\n2\n"), &out)
+	if err != nil || arn != "GAHT12345678" {
+		t.Fatalf("Expected picked device, got %q (err %v)", arn, err)
+	}
+	if !strings.Contains(out.String(), "2) GAHT12345678") || !strings.Contains(out.String(), "Please enter a number") {
+		t.Errorf("Unexpected picker output:\n%s", out.String())
+	}
+	arn, err = resolveMFAArn(ctx, client, "alice", "dev", false, nil, &out)
+	if err != nil || arn != "GAHT12345678" {
+		t.Errorf("Expected remembered device, got %q (err %v)", arn, err)
+	}
+	if _, err := resolveMFAArn(ctx, client, "alice", "other", false, nil, &out); err == nil {
+		t.Errorf("Expected the choice to apply only to its profile")
+	}
+}
+
+func TestAppRun_MFAOutputJSON(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client := &fakeIAMClient{Devices: []string{"arn:aws:iam::123456789012:mfa/alice", "arn:aws:iam::123456789012:u2f/user/alice/key"}}
+	var out, stderr bytes.Buffer
+	app := newTestApp(&out)
+	app.Stderr = &stderr
+	app.Clients = func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+		return &AWSClients{IAM: client}, nil
+	}
+
+	if code := app.Run(context.Background(), []string{"mfa", "list", "--user", "alice", "--output", "json"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (%s)", exitOK, code, stderr.String())
+	}
+	var devices []mfaDeviceJSON
+	if err := json.Unmarshal(out.Bytes(), &devices); err != nil {
+		t.Fatalf("Failed to parse output %q: %v", out.String(), err)
+	}
+	if len(devices) != 2 || !devices[0].UsableWithSTS || devices[1].UsableWithSTS {
+		t.Errorf("Unexpected devices: %+v", devices)
+	}
+
+	out.Reset()
+	app.Stdin = strings.NewReader("123456\n654321\n")
+	if code := app.Run(context.Background(), []string{"mfa", "enroll", "--user", "alice", "--output", "json"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (%s)", exitOK, code, stderr.String())
+	}
+	var result authResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse output %q: %v", out.String(), err)
+	}
+	if result.Action != ActionEnrolled || result.MFAArn != "arn:aws:iam::123456789012:mfa/alice" || result.Profile != "default-long-term" {
+		t.Errorf("Unexpected enroll result: %+v", result)
+	}
+	if !strings.Contains(stderr.String(), "Enter the first code") {
+		t.Errorf("Expected the prompts on stderr, got %q", stderr.String())
+	}
+
+	out.Reset()
+	app.Stdin = strings.NewReader("111111\n222222\n")
+	if code := app.Run(context.Background(), []string{"mfa", "resync", "--user", "alice", "--mfa-arn", testMFAArn, "--output", "json"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (%s)", exitOK, code, stderr.String())
+	}
+	result = authResult{}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil || result.Action != ActionResynced || result.MFAArn != testMFAArn {
+		t.Errorf("Unexpected resync result %+v (err %v)", result, err)
+	}
+
+	// Failures are reported in the same schema, with the matching exit code.
+	out.Reset()
+	client.EnableErr = &smithy.GenericAPIError{Code: "InvalidAuthenticationCode", Message: "Authentication code for device is not valid"}
+	app.Stdin = strings.NewReader("111111\n222222\n")
+	if code := app.Run(context.Background(), []string{"mfa", "enroll", "--user", "alice", "--output", "json"}); code != exitAWSError {
+		t.Fatalf("Expected exit code %d, got %d", exitAWSError, code)
+	}
+	result = authResult{}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil || result.Action != ActionFailed || result.ErrorCode != "aws_error" {
+		t.Errorf("Unexpected failure result %+v (err %v)", result, err)
+	}
+}
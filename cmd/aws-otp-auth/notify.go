@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/crbanman/aws-otp-auth/pkg/notify"
+	"github.com/spf13/pflag"
+)
+
+// defaultNotifyBefore are the lead times at which a session's expiry is announced.
+var defaultNotifyBefore = []time.Duration{30 * time.Minute, 10 * time.Minute, 2 * time.Minute}
+
+// refreshAction is the key of the action that refreshes the session.
+const refreshAction = "refresh"
+
+// runNotify implements the "notify" subcommand.
+func (a *App) runNotify(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("notify", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	profileTo, configPath := targetFlags(flags)
+	before := flags.DurationSlice("notify-before", defaultNotifyBefore, "Notify when the session has this long left (comma-separated)")
+	notifyCommand := flags.String("notify-command", "", "Shell command that shows the notification, instead of the desktop notification service")
+	refreshCommand := flags.String("refresh-command", "", "Shell command run by the Refresh action (default: aws-otp-auth login for the profile)")
+	interval := flags.Duration("interval", 30*time.Second, "How often to check the session's expiration")
+	once := flags.Bool("once", false, "Check once and exit instead of watching")
+	logOpts := addLogFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(a.Stderr, "Usage: aws-otp-auth notify [flags] [config-profile]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return newConfigError(err)
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return newConfigError(fmt.Errorf("too many arguments"))
+	}
+	if err := a.applyTarget(flags, *configPath, flags.Arg(0)); err != nil {
+		return err
+	}
+	closeLog, err := logOpts.setup(a.Stderr)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	for _, d := range *before {
+		if d <= 0 {
+			return newConfigError(fmt.Errorf("--notify-before must be positive, got %s", d))
+		}
+	}
+	if *interval <= 0 {
+		return newConfigError(fmt.Errorf("--interval must be positive, got %s", *interval))
+	}
+
+	var notifier notify.Notifier
+	if *notifyCommand != "" {
+		notifier = &notify.CommandNotifier{Command: *notifyCommand, Env: a.Environ(), Stderr: a.Stderr}
+	} else if notifier, err = a.NotificationBus(ctx); err != nil {
+		return fmt.Errorf("failed to connect to the desktop notification service (use --notify-command to show notifications another way): %w", err)
+	}
+	defer notifier.Close()
+
+	// The refresh runs without a terminal, so unless --refresh-command opens one,
+	// it needs an OTP that can be obtained without a prompt, such as otp_provider = "totp".
+	refreshArgs := []string{"sh", "-c", *refreshCommand}
+	if *refreshCommand == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate aws-otp-auth: %w", err)
+		}
+		refreshArgs = []string{exe, "login", "--profile-to", *profileTo}
+		if *configPath != "" {
+			refreshArgs = append(refreshArgs, "--config", *configPath)
+		}
+		if name := flags.Arg(0); name != "" {
+			refreshArgs = append(refreshArgs, name)
+		}
+	}
+
+	w := newExpiryWatcher(*profileTo, *before, notifier, a.Stderr)
+	w.refresh = func(ctx context.Context) error {
+		cmd := exec.CommandContext(ctx, refreshArgs[0], refreshArgs[1:]...)
+		cmd.Env = a.Environ()
+		cmd.Stdout = a.Stderr
+		cmd.Stderr = a.Stderr
+		return a.RunCommand(cmd)
+	}
+	for {
+		w.check(ctx, a.Now())
+		if *once {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// expiryWatcher notifies the user as the session stored in a profile approaches its expiration.
+type expiryWatcher struct {
+	profile  string
+	before   []time.Duration // longest first
+	notifier notify.Notifier
+	stderr   io.Writer
+	// refresh renews the session when the user picks the Refresh action. If nil, the action is not offered.
+	refresh func(ctx context.Context) error
+
+	expiration time.Time // the session the state below refers to
+	notified   int       // how many of before have been announced
+	expired    bool      // whether the expiry itself has been announced
+
+	refreshing sync.Mutex
+}
+
+// newExpiryWatcher returns a watcher that notifies when the session in profile has each of before left.
+func newExpiryWatcher(profile string, before []time.Duration, notifier notify.Notifier, stderr io.Writer) *expiryWatcher {
+	sorted := slices.Clone(before)
+	slices.Sort(sorted)
+	slices.Reverse(sorted)
+	return &expiryWatcher{profile: profile, before: slices.Compact(sorted), notifier: notifier, stderr: stderr}
+}
+
+// check reads the profile's expiration and sends the notification that is due at now,
+// if any. A session that moves past several lead times between checks is announced once.
+func (w *expiryWatcher) check(ctx context.Context, now time.Time) {
+	var expiration time.Time
+	if creds, err := aws.ReadAWSCredentials(w.profile); err == nil {
+		expiration = creds.Expiration
+	}
+	if !expiration.Equal(w.expiration) {
+		// The session was refreshed or removed; start over.
+		w.expiration, w.notified, w.expired = expiration, 0, false
+	}
+	if expiration.IsZero() || w.expired {
+		return
+	}
+
+	at := expiration.Local().Format("15:04")
+	remaining := expiration.Sub(now)
+	if remaining <= 0 {
+		w.expired = true
+		w.notify(ctx, notify.Notification{
+			Summary: fmt.Sprintf("AWS session %s has expired", w.profile),
+			Body:    fmt.Sprintf("The session in profile %s expired at %s.", w.profile, at),
+			Urgency: notify.UrgencyCritical,
+		})
+		return
+	}
+	due := w.notified
+	for due < len(w.before) && remaining <= w.before[due] {
+		due++
+	}
+	if due == w.notified {
+		return
+	}
+	w.notified = due
+	urgency := notify.UrgencyNormal
+	if due == len(w.before) {
+		urgency = notify.UrgencyCritical
+	}
+	w.notify(ctx, notify.Notification{
+		Summary: fmt.Sprintf("AWS session %s expires in %s", w.profile, formatRemaining(remaining)),
+		Body:    fmt.Sprintf("The session in profile %s expires at %s.", w.profile, at),
+		Urgency: urgency,
+	})
+}
+
+// notify shows n with the Refresh action, reporting failures to stderr.
+func (w *expiryWatcher) notify(ctx context.Context, n notify.Notification) {
+	var onAction func(string)
+	if w.refresh != nil {
+		n.Actions = []notify.Action{{Key: refreshAction, Label: "Refresh"}}
+		onAction = func(key string) {
+			if key == refreshAction {
+				w.runRefresh(ctx)
+			}
+		}
+	}
+	slog.Info("Sending expiry notification", "profile", w.profile, "summary", n.Summary)
+	if err := w.notifier.Notify(ctx, n, onAction); err != nil {
+		slog.Warn("Failed to send notification", "profile", w.profile, "err", err)
+		fmt.Fprintf(w.stderr, "Warning: %v\n", err)
+	}
+}
+
+// runRefresh refreshes the session, unless a refresh is already running, and reports a failure.
+func (w *expiryWatcher) runRefresh(ctx context.Context) {
+	if !w.refreshing.TryLock() {
+		return
+	}
+	defer w.refreshing.Unlock()
+	slog.Info("Refreshing session from notification", "profile", w.profile)
+	if err := w.refresh(ctx); err != nil {
+		slog.Warn("Refresh failed", "profile", w.profile, "err", err)
+		if nerr := w.notifier.Notify(ctx, notify.Notification{
+			Summary: fmt.Sprintf("Refreshing AWS session %s failed", w.profile),
+			Body:    fmt.Sprintf("%v. Run 'aws-otp-auth login' in a terminal.", err),
+			Urgency: notify.UrgencyCritical,
+		}, nil); nerr != nil {
+			fmt.Fprintf(w.stderr, "Warning: refreshing profile %s failed: %v\n", w.profile, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/notify"
+)
+
+// fakeNotifier records the notifications it is asked to show.
+type fakeNotifier struct {
+	mu       sync.Mutex
+	shown    []notify.Notification
+	onAction []func(string)
+	err      error
+	closed   bool
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, n notify.Notification, onAction func(key string)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.shown = append(f.shown, n)
+	f.onAction = append(f.onAction, onAction)
+	return nil
+}
+
+func (f *fakeNotifier) Close() error {
+	f.closed = true
+	return nil
+}
+
+// summaries returns the summaries of the notifications shown so far.
+func (f *fakeNotifier) summaries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, n := range f.shown {
+		out = append(out, n.Summary)
+	}
+	return out
+}
+
+func TestExpiryWatcher(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	expiration := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	writeCredentials(t, home, sessionSection("dev", expiration))
+
+	notifier := &fakeNotifier{}
+	w := newExpiryWatcher("dev", []time.Duration{2 * time.Minute, 30 * time.Minute, 10 * time.Minute}, notifier, &bytes.Buffer{})
+	for _, left := range []time.Duration{time.Hour, 29 * time.Minute, 25 * time.Minute, 5 * time.Minute, 90 * time.Second, 0, -time.Minute} {
+		w.check(context.Background(), expiration.Add(-left))
+	}
+	want := []string{"AWS session dev expires in 29m", "AWS session dev expires in 5m", "AWS session dev expires in 1m", "AWS session dev has expired"}
+	if got := notifier.summaries(); !slices.Equal(got, want) {
+		t.Errorf("Expected notifications %q, got %q", want, got)
+	}
+	if notifier.shown[1].Urgency != notify.UrgencyNormal || notifier.shown[2].Urgency != notify.UrgencyCritical {
+		t.Errorf("Expected the last lead time to be critical, got %+v", notifier.shown)
+	}
+	if len(notifier.shown[0].Actions) != 0 {
+		t.Errorf("Expected no Refresh action without a refresh function, got %+v", notifier.shown[0].Actions)
+	}
+
+	// A refreshed session is announced afresh; several lead times passed at once are announced once.
+	expiration = expiration.Add(8 * time.Hour)
+	writeCredentials(t, home, sessionSection("dev", expiration))
+	w.check(context.Background(), expiration.Add(-time.Minute))
+	if got := notifier.summaries(); len(got) != 5 || got[4] != "AWS session dev expires in 1m" {
+		t.Errorf("Expected a single notification for the new session, got %q", got)
+	}
+}
+
+func TestExpiryWatcher_Refresh(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	expiration := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	writeCredentials(t, home, sessionSection("dev", expiration))
+
+	notifier := &fakeNotifier{}
+	w := newExpiryWatcher("dev", defaultNotifyBefore, notifier, &bytes.Buffer{})
+	refreshes := 0
+	w.refresh = func(ctx context.Context) error {
+		refreshes++
+		return errors.New("no OTP available")
+	}
+	w.check(context.Background(), expiration.Add(-5*time.Minute))
+	if len(notifier.shown) != 1 || !slices.Equal(notifier.shown[0].Actions, []notify.Action{{Key: refreshAction, Label: "Refresh"}}) {
+		t.Fatalf("Expected a notification with a Refresh action, got %+v", notifier.shown)
+	}
+
+	notifier.onAction[0]("dismiss")
+	notifier.onAction[0](refreshAction)
+	if refreshes != 1 {
+		t.Errorf("Expected one refresh, got %d", refreshes)
+	}
+	if got := notifier.summaries(); len(got) != 2 || got[1] != "Refreshing AWS session dev failed" || !strings.Contains(notifier.shown[1].Body, "no OTP available") {
+		t.Errorf("Expected the failed refresh to be reported, got %+v", notifier.shown)
+	}
+}
+
+func TestAppRun_Notify(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	writeCredentials(t, home, sessionSection("default", now.Add(20*time.Minute)))
+
+	var stderr bytes.Buffer
+	app := newTestApp(&bytes.Buffer{})
+	app.Stderr = &stderr
+	app.Now = func() time.Time { return now }
+	notifier := &fakeNotifier{}
+	app.NotificationBus = func(ctx context.Context) (notify.Notifier, error) { return notifier, nil }
+	var ran []string
+	app.RunCommand = func(cmd *exec.Cmd) error {
+		ran = cmd.Args
+		return nil
+	}
+
+	if code := app.Run(context.Background(), []string{"notify", "--once", "--notify-before", "1h,15m", "--refresh-command", "my-refresh"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (%s)", exitOK, code, stderr.String())
+	}
+	if got := notifier.summaries(); !slices.Equal(got, []string{"AWS session default expires in 20m"}) {
+		t.Errorf("Unexpected notifications: %q", got)
+	}
+	if !notifier.closed {
+		t.Errorf("Expected the notifier to be closed")
+	}
+	notifier.onAction[0](refreshAction)
+	if !slices.Equal(ran, []string{"sh", "-c", "my-refresh"}) {
+		t.Errorf("Expected the refresh command to run, got %q", ran)
+	}
+
+	app.NotificationBus = func(ctx context.Context) (notify.Notifier, error) { return nil, errors.New("no session bus") }
+	if code := app.Run(context.Background(), []string{"notify", "--once"}); code != exitError {
+		t.Errorf("Expected exit code %d without a notification service, got %d", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "--notify-command") {
+		t.Errorf("Expected a hint about --notify-command, got %q", stderr.String())
+	}
+	if code := app.Run(context.Background(), []string{"notify", "--once", "--notify-before", "0s"}); code != exitConfigError {
+		t.Errorf("Expected exit code %d for a zero lead time, got %d", exitConfigError, code)
+	}
+}
+
+// sessionSection returns a credentials file section holding a session that expires at expiration.
+func sessionSection(profile string, expiration time.Time) string {
+	return "[" + profile + "]\naws_access_key_id = ASIAEXAMPLE\naws_secret_access_key = SECRET\naws_session_token = TOKEN\naws_session_token_expiration = " + expiration.Format(time.RFC3339) + "\naws_otp_auth_session = ASIAEXAMPLE\n"
+}
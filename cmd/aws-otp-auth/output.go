@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/crbanman/aws-otp-auth/pkg/auth"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+)
+
+// Exit codes. Scripts can rely on these values. A kept session exits with exitOK
+// unless --detailed-exitcode is given, so that "aws-otp-auth && ..." works either way.
+const (
+	exitOK           = 0  // success; credentials were refreshed, or kept without --detailed-exitcode
+	exitError        = 1  // any failure not covered below
+	exitConfigError  = 2  // invalid flags, settings or config file
+	exitBadOTP       = 3  // AWS rejected the MFA code
+	exitAWSError     = 4  // an AWS call failed or AWS could not be reached
+	exitAlreadyValid = 10 // with --detailed-exitcode: the stored session was kept
+)
+
+// Actions reported for a profile.
+const (
+	ActionValid     = auth.ActionValid
+	ActionKept      = auth.ActionKept
+	ActionRefreshed = auth.ActionRefreshed
+	ActionFailed    = auth.ActionFailed
+)
+
+// Actions reported by the mfa and logout subcommands.
+const (
+	ActionEnrolled  = "enrolled"   // a virtual MFA device was created and enabled
+	ActionResynced  = "resynced"   // the MFA device was resynchronized
+	ActionLoggedOut = "logged_out" // the profile's session was removed
+	ActionRestored  = "restored"   // the profile's session was replaced by its earlier contents
+	ActionNoSession = "no_session" // the profile held no session to remove
+	ActionRevoked   = "revoked"    // the role's sessions issued until now were revoked
+)
+
+// configError marks an error caused by the user's flags, settings or config file.
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string { return e.err.Error() }
+func (e *configError) Unwrap() error { return e.err }
+
+// newConfigError wraps err as a configuration error.
+func newConfigError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &configError{err: err}
+}
+
+// exitCode maps an error to the process exit code.
+func exitCode(err error) int {
+	var status *exitStatus
+	var cfgErr *configError
+	var apiErr smithy.APIError
+	var sendErr *smithyhttp.RequestSendError
+	var netErr net.Error
+	var awsNetErr *aws.NetworkError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &status):
+		return status.code
+	case errors.As(err, &cfgErr):
+		return exitConfigError
+	case aws.IsInvalidMFACode(err):
+		return exitBadOTP
+	case errors.As(err, &apiErr), errors.As(err, &sendErr), errors.As(err, &netErr), errors.As(err, &awsNetErr):
+		return exitAWSError
+	}
+	return exitError
+}
+
+// errorCode returns the stable name of err's exit code for JSON output.
+func errorCode(err error) string {
+	switch exitCode(err) {
+	case exitOK:
+		return ""
+	case exitConfigError:
+		return "config_error"
+	case exitBadOTP:
+		return "bad_otp"
+	case exitAWSError:
+		return "aws_error"
+	}
+	return "error"
+}
+
+// remediationHint suggests how to fix the AWS failure behind err, or returns "" if there is no specific advice.
+func remediationHint(err error) string {
+	var (
+		codeErr     *aws.InvalidMFACodeError
+		noMFAErr    *aws.MFANotEnabledError
+		credsErr    *aws.InvalidCredentialsError
+		durationErr *aws.InvalidDurationError
+		throttleErr *aws.ThrottlingError
+		netErr      *aws.NetworkError
+		deniedErr   *aws.AccessDeniedError
+	)
+	switch {
+	case errors.As(err, &codeErr):
+		return "Enter the current code from the device matching --mfa-arn, and do not reuse a code that was already accepted; wait for the next one."
+	case errors.As(err, &noMFAErr):
+		return "The MFA device is not assigned to this IAM user. Check --mfa-arn, omit it to look the device up, or enroll one with 'aws-otp-auth mfa enroll'."
+	case errors.As(err, &credsErr):
+		return "The access key in the source profile is invalid, inactive or deleted. Create a new access key in IAM and update --profile-from in ~/.aws/credentials."
+	case errors.As(err, &durationErr):
+		return "Session tokens allow --duration between 900 and 129600 seconds (15m to 36h). An assumed role allows at most its maximum session duration (1h unless raised on the role, up to 12h), and a role assumed from a session, as in batch, at most 1h."
+	case errors.As(err, &throttleErr):
+		return "AWS is throttling requests. Wait a moment and try again."
+	case errors.As(err, &netErr):
+		return "AWS could not be reached. Check your network connection, proxy settings, --region and --sts-endpoint."
+	case errors.As(err, &deniedErr):
+		return "The IAM user is not allowed to perform this action. Check its IAM policies and, with --role-arn, that the role's trust policy allows it and requires MFA only if an MFA code is sent."
+	}
+	return ""
+}
+
+// printError writes err and any remediation hint to w. It writes nothing for a nil
+// error or an exit status.
+func printError(w io.Writer, err error) {
+	var status *exitStatus
+	if err == nil || errors.As(err, &status) {
+		return
+	}
+	fmt.Fprintf(w, "Error: %v\n", err)
+	if hint := remediationHint(err); hint != "" {
+		fmt.Fprintf(w, "Hint: %s\n", hint)
+	}
+}
+
+// checkOutputFormat rejects output formats other than text and json.
+func checkOutputFormat(format string) error {
+	if format != "text" && format != "json" {
+		return newConfigError(fmt.Errorf("unsupported output format %q", format))
+	}
+	return nil
+}
+
+// authResult is the outcome for one credentials profile, as reported with --output json.
+type authResult struct {
+	Name       string     `json:"name,omitempty"`
+	Profile    string     `json:"profile"`
+	Account    string     `json:"account,omitempty"`
+	Arn        string     `json:"arn,omitempty"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	MFAArn     string     `json:"mfa_arn,omitempty"`
+	RoleArn    string     `json:"role_arn,omitempty"`
+	Action     string     `json:"action"`
+	ErrorCode  string     `json:"error_code,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Hint       string     `json:"hint,omitempty"`
+	HookErrors []string   `json:"hook_errors,omitempty"`
+}
+
+// setError records err as the reason the profile was not updated.
+func (r *authResult) setError(err error) {
+	r.Action = ActionFailed
+	r.ErrorCode = errorCode(err)
+	r.Error = err.Error()
+	r.Hint = remediationHint(err)
+}
+
+// describeProfile fills in the expiration and identity of the profile's stored credentials.
+// Lookup failures leave the fields empty.
+func (r *authResult) describeProfile(ctx context.Context, newClient credentialsClientFunc) {
+	creds, err := aws.ReadAWSCredentials(r.Profile)
+	if err != nil {
+		return
+	}
+	if !creds.Expiration.IsZero() {
+		exp := creds.Expiration
+		r.Expiration = &exp
+	}
+	client, err := newClient(ctx, *creds)
+	if err != nil {
+		return
+	}
+	if identity, err := aws.GetCallerIdentity(ctx, client); err == nil {
+		r.Account = identity.Account
+		r.Arn = identity.Arn
+	}
+}
+
+// writeResultJSON records err, if any, in result and writes result as JSON. It returns
+// err, or the write error if err is nil.
+func writeResultJSON(out io.Writer, result *authResult, err error) error {
+	if err != nil {
+		result.setError(err)
+	}
+	if werr := writeJSON(out, result); werr != nil && err == nil {
+		return werr
+	}
+	return err
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(out io.Writer, v any) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+)
+
+func TestExitCode(t *testing.T) {
+	badOTP := &smithy.GenericAPIError{Code: "AccessDenied", Message: "MultiFactorAuthentication failed with invalid MFA one time pass code."}
+	tests := []struct {
+		name     string
+		err      error
+		wantExit int
+		wantCode string
+	}{
+		{"success", nil, exitOK, ""},
+		{"config", newConfigError(errors.New("profile \"x\" not found in config file")), exitConfigError, "config_error"},
+		{"wrapped config", fmt.Errorf("batch: %w", newConfigError(errors.New("no profiles given"))), exitConfigError, "config_error"},
+		{"bad otp", fmt.Errorf("authentication flow failed: %w", badOTP), exitBadOTP, "bad_otp"},
+		{"api error", fmt.Errorf("failed: %w", &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized"}), exitAWSError, "aws_error"},
+		{"network", &smithyhttp.RequestSendError{Err: errors.New("dial tcp: no such host")}, exitAWSError, "aws_error"},
+		{"other", errors.New("failed to update credentials file"), exitError, "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.wantExit {
+				t.Errorf("Expected exit code %d, got %d", tt.wantExit, got)
+			}
+			if got := errorCode(tt.err); got != tt.wantCode {
+				t.Errorf("Expected error code %q, got %q", tt.wantCode, got)
+			}
+		})
+	}
+}
+
+func TestAuthResultSetError(t *testing.T) {
+	r := authResult{Profile: "default", Action: ActionRefreshed}
+	r.setError(newConfigError(errors.New("bad duration")))
+	if r.Action != ActionFailed || r.ErrorCode != "config_error" || r.Error != "bad duration" {
+		t.Errorf("Unexpected result: %+v", r)
+	}
+}
+
+func TestRemediationHint(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&aws.InvalidMFACodeError{Err: errors.New("invalid code")}, "wait for the next one"},
+		{&aws.MFANotEnabledError{Err: errors.New("unable to validate")}, "mfa enroll"},
+		{fmt.Errorf("failed to get session token: %w", &aws.InvalidCredentialsError{Err: errors.New("InvalidClientTokenId")}), "new access key"},
+		{&aws.InvalidDurationError{Err: errors.New("durationSeconds")}, "900 and 129600"},
+		{&aws.ThrottlingError{Err: errors.New("Rate exceeded")}, "throttling"},
+		{&aws.NetworkError{Err: errors.New("no such host")}, "--region"},
+		{&aws.AccessDeniedError{Err: errors.New("not authorized")}, "trust policy"},
+		{errors.New("something else"), ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		got := remediationHint(tt.err)
+		if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+			t.Errorf("remediationHint(%v): expected hint containing %q, got %q", tt.err, tt.want, got)
+		}
+	}
+
+	var r authResult
+	r.setError(aws.ClassifyError(&smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}))
+	if r.Hint == "" || r.ErrorCode != "aws_error" {
+		t.Errorf("Expected throttling to be reported as an AWS error with a hint, got %+v", r)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/spf13/pflag"
+)
+
+// defaultPromptFormat renders "dev:2h13m", or "dev:EXPIRED" once the session has expired.
+const defaultPromptFormat = "{{.Profile}}:{{.Remaining}}"
+
+// promptCacheFile caches the expirations parsed from the credentials file, so that a
+// prompt drawn on every command does not parse the file each time.
+const promptCacheFile = "prompt-cache.json"
+
+// promptCache holds the session expirations of the credentials file as of its
+// recorded size and modification time.
+type promptCache struct {
+	Size    int64 `json:"size"`
+	ModTime int64 `json:"mod_time"`
+	// Expirations maps every session profile to its expiration.
+	Expirations map[string]time.Time `json:"expirations"`
+}
+
+// promptData is the data available to --format templates.
+type promptData struct {
+	Profile    string
+	Remaining  string // e.g. "2h13m", or "EXPIRED"
+	Expiration time.Time
+	Seconds    int // time left, 0 once expired
+	Expired    bool
+	Expiring   bool // less than --warn left
+}
+
+// runPrompt implements the "prompt" subcommand. It reads nothing but the credentials
+// file, and a profile without a session prints nothing, so it can run in PS1.
+func (a *App) runPrompt(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("prompt", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	profile := flags.StringP("profile", "p", "", "AWS profile to show (default $AWS_PROFILE, or default)")
+	format := flags.String("format", defaultPromptFormat, "Go template for the output, with .Profile, .Remaining, .Expiration, .Seconds, .Expired and .Expiring")
+	warn := flags.Duration("warn", 15*time.Minute, "Set .Expiring when the session has less than this left")
+	flags.Usage = func() {
+		fmt.Fprintln(a.Stderr, "Usage: aws-otp-auth prompt [flags]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return newConfigError(err)
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return newConfigError(fmt.Errorf("too many arguments"))
+	}
+	tmpl, err := template.New("prompt").Parse(*format)
+	if err != nil {
+		return newConfigError(fmt.Errorf("invalid --format: %w", err))
+	}
+	if *profile == "" {
+		*profile = a.getenv("AWS_PROFILE")
+	}
+	if *profile == "" {
+		*profile = "default"
+	}
+
+	expirations, err := sessionExpirations()
+	if err != nil {
+		return err
+	}
+	expiration, ok := expirations[*profile]
+	if !ok {
+		return nil
+	}
+	data := promptData{Profile: *profile, Expiration: expiration, Remaining: "EXPIRED", Expired: true}
+	if remaining := expiration.Sub(a.Now()); remaining > 0 {
+		data.Remaining, data.Seconds, data.Expired = formatRemaining(remaining), int(remaining/time.Second), false
+		data.Expiring = remaining < *warn
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return newConfigError(fmt.Errorf("invalid --format: %w", err))
+	}
+	fmt.Fprintln(a.Stdout, out.String())
+	return nil
+}
+
+// sessionExpirations returns the expiration of every session profile in the credentials
+// file. The file is only parsed when it has changed since the cached result was saved.
+func sessionExpirations() (map[string]time.Time, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine user home directory: %w", err)
+	}
+	info, err := os.Stat(filepath.Join(home, ".aws", "credentials"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	var cache promptCache
+	// An unreadable cache is rebuilt below.
+	_ = loadState(promptCacheFile, &cache)
+	if cache.Expirations != nil && cache.Size == info.Size() && cache.ModTime == info.ModTime().UnixNano() {
+		return cache.Expirations, nil
+	}
+
+	profiles, err := aws.ListAWSCredentials()
+	if err != nil {
+		return nil, err
+	}
+	cache = promptCache{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Expirations: map[string]time.Time{}}
+	for _, p := range profiles {
+		if p.Type == aws.ProfileTypeSession && !p.Expiration.IsZero() {
+			cache.Expirations[p.Profile] = p.Expiration
+		}
+	}
+	// The cache only saves time; the prompt is still right without it.
+	_ = saveState(promptCacheFile, cache)
+	return cache.Expirations, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppRun_Prompt(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	writeCredentials(t, home, sessionSection("dev", now.Add(2*time.Hour+13*time.Minute+30*time.Second))+
+		sessionSection("old", now.Add(-time.Minute))+
+		"[default-long-term]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = SECRET\n")
+
+	tests := []struct {
+		name    string
+		args    []string
+		environ []string
+		want    string
+		code    int
+	}{
+		{"remaining", []string{"--profile", "dev"}, nil, "dev:2h13m\n", exitOK},
+		{"expired", []string{"-p", "old"}, nil, "old:EXPIRED\n", exitOK},
+		{"from AWS_PROFILE", nil, []string{"AWS_PROFILE=dev"}, "dev:2h13m\n", exitOK},
+		{"long-term profile", []string{"-p", "default-long-term"}, nil, "", exitOK},
+		{"missing profile", []string{"-p", "nope"}, nil, "", exitOK},
+		{"template", []string{"-p", "dev", "--warn", "3h", "--format", "{{if .Expiring}}!{{end}}{{.Seconds}}"}, nil, "!8010\n", exitOK},
+		{"bad template", []string{"-p", "dev", "--format", "{{.Nope"}, nil, "", exitConfigError},
+		{"unknown field", []string{"-p", "dev", "--format", "{{.Nope}}"}, nil, "", exitConfigError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			app := newTestApp(&out)
+			app.Now = func() time.Time { return now }
+			app.Environ = func() []string { return tt.environ }
+			if code := app.Run(context.Background(), append([]string{"prompt"}, tt.args...)); code != tt.code {
+				t.Fatalf("Expected exit code %d, got %d", tt.code, code)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Expected output %q, got %q", tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestSessionExpirations_Cache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	expiration := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	writeCredentials(t, home, sessionSection("dev", expiration))
+	path := filepath.Join(home, ".aws", "credentials")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat credentials file: %v", err)
+	}
+
+	if got, err := sessionExpirations(); err != nil || !got["dev"].Equal(expiration) {
+		t.Fatalf("Expected dev to expire at %s, got %v (err %v)", expiration, got, err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "aws-otp-auth", promptCacheFile)); err != nil {
+		t.Fatalf("Expected the cache to be saved: %v", err)
+	}
+
+	// A file of the same size and modification time is not parsed again.
+	writeCredentials(t, home, sessionSection("dev", expiration.Add(time.Hour)))
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Failed to reset modification time: %v", err)
+	}
+	if got, _ := sessionExpirations(); !got["dev"].Equal(expiration) {
+		t.Errorf("Expected the cached expiration %s, got %s", expiration, got["dev"])
+	}
+
+	// A changed file is parsed again.
+	if err := os.Chtimes(path, info.ModTime().Add(time.Second), info.ModTime().Add(time.Second)); err != nil {
+		t.Fatalf("Failed to change modification time: %v", err)
+	}
+	if got, _ := sessionExpirations(); !got["dev"].Equal(expiration.Add(time.Hour)) {
+		t.Errorf("Expected the new expiration %s, got %s", expiration.Add(time.Hour), got["dev"])
+	}
+
+	// Without a credentials file there are no sessions.
+	os.Remove(path)
+	if got, err := sessionExpirations(); err != nil || len(got) != 0 {
+		t.Errorf("Expected no sessions without a credentials file, got %v (err %v)", got, err)
+	}
+}
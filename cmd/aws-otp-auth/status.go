@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/spf13/pflag"
+
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	awsCredentials "github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// runStatus implements the "status" subcommand.
+func (a *App) runStatus(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("status", pflag.ContinueOnError)
+	flags.SetOutput(a.Stderr)
+	keys := flags.Bool("keys", false, "Report on the access keys of the source profile's IAM user instead of the profiles")
+	check := flags.Bool("check", false, "Verify each profile's credentials with STS GetCallerIdentity")
+	profileFrom := flags.StringP("profile-from", "f", "default-long-term", "AWS profile holding the long-term credentials")
+	awsUser := flags.StringP("user", "u", "", "AWS username (if not provided, derived from the source profile's credentials)")
+	maxKeyAge := flags.Int("max-key-age", 90, "Warn when an active access key is older than this many days (0 disables)")
+	output := flags.String("output", "text", "Output format: text or json")
+	configPath := flags.String("config", "", "Path to the config file (default ~/.config/aws-otp-auth/config.toml)")
+	endpoints := addEndpointFlags(flags, a.getenv)
+	logOpts := addLogFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(a.Stderr, "Usage: aws-otp-auth status [flags] [config-profile]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return newConfigError(err)
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return newConfigError(fmt.Errorf("too many arguments"))
+	}
+	// The source profile, user, region and key age come from the environment and the config profile.
+	if err := a.applyTarget(flags, *configPath, flags.Arg(0)); err != nil {
+		return err
+	}
+	closeLog, err := logOpts.setup(a.Stderr)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+	if err := endpoints.validate(); err != nil {
+		return err
+	}
+	if !*keys {
+		profiles, err := aws.ListAWSCredentials()
+		if err != nil {
+			return err
+		}
+		var checkFn func(aws.ProfileCredentials) (*aws.CallerIdentity, error)
+		if *check {
+			checkFn = func(p aws.ProfileCredentials) (*aws.CallerIdentity, error) {
+				client, err := a.CredentialsClient(ctx, p.Credentials, endpoints)
+				if err != nil {
+					return nil, err
+				}
+				return aws.GetCallerIdentity(ctx, client)
+			}
+		}
+		return writeProfileStatuses(a.Stdout, collectProfileStatuses(profiles, a.Now(), checkFn), *output)
+	}
+
+	clients, err := a.Clients(ctx, *profileFrom, endpoints)
+	if err != nil {
+		return err
+	}
+	userName, err := resolveUser(ctx, clients.STS, *awsUser)
+	if err != nil {
+		return err
+	}
+
+	maxAge := time.Duration(*maxKeyAge) * 24 * time.Hour
+	report, err := aws.GetAccessKeyReport(ctx, clients.IAM, userName, maxAge, a.Now())
+	if err != nil {
+		return err
+	}
+	return writeAccessKeyReport(a.Stdout, report, *output)
+}
+
+// profileStatus is the state of one credentials profile as reported by "status".
+type profileStatus struct {
+	Profile          string     `json:"profile"`
+	Type             string     `json:"type"`
+	Expiration       *time.Time `json:"expiration,omitempty"`
+	RemainingSeconds int64      `json:"remaining_seconds,omitempty"`
+	Expired          bool       `json:"expired"`
+	Account          string     `json:"account,omitempty"`
+	Arn              string     `json:"arn,omitempty"`
+	Error            string     `json:"error,omitempty"`
+}
+
+// credentialsSTSClient returns an STS client that signs requests with the given credentials.
+func credentialsSTSClient(ctx context.Context, creds aws.Credentials, endpoints *endpointOptions) (aws.STSClient, error) {
+	secrets.Add(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken)
+	cfg, err := endpoints.load(ctx,
+		awsConfig.WithCredentialsProvider(awsCredentials.NewStaticCredentialsProvider(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return endpoints.newSTSClient(cfg), nil
+}
+
+// collectProfileStatuses derives each profile's state at now. If check is set, it is called
+// for every profile with usable credentials to look up the account and ARN.
+func collectProfileStatuses(profiles []aws.ProfileCredentials, now time.Time, check func(aws.ProfileCredentials) (*aws.CallerIdentity, error)) []profileStatus {
+	statuses := make([]profileStatus, 0, len(profiles))
+	for _, p := range profiles {
+		st := profileStatus{Profile: p.Profile, Type: p.Type}
+		if !p.Expiration.IsZero() {
+			exp := p.Expiration
+			st.Expiration = &exp
+			st.Expired = !now.Before(exp)
+			if !st.Expired {
+				st.RemainingSeconds = int64(exp.Sub(now) / time.Second)
+			}
+		}
+		if check != nil && p.Type != aws.ProfileTypeIncomplete && !st.Expired {
+			if identity, err := check(p); err != nil {
+				st.Error = err.Error()
+			} else {
+				st.Account = identity.Account
+				st.Arn = identity.Arn
+			}
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// formatRemaining renders a remaining lifetime compactly, e.g. "2h13m" or "45s".
+func formatRemaining(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	}
+	d = d.Truncate(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	}
+	return fmt.Sprintf("%dh%02dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
+
+// writeProfileStatuses renders the profile states in the requested format.
+func writeProfileStatuses(out io.Writer, statuses []profileStatus, format string) error {
+	if format == "json" {
+		return writeJSON(out, statuses)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROFILE\tTYPE\tEXPIRES\tREMAINING\tIDENTITY")
+	for _, st := range statuses {
+		expires, remaining := "-", "-"
+		if st.Expiration != nil {
+			expires = st.Expiration.Local().Format(time.RFC3339)
+			remaining = "expired"
+			if !st.Expired {
+				remaining = formatRemaining(time.Duration(st.RemainingSeconds) * time.Second)
+			}
+		}
+		identity := st.Arn
+		if st.Error != "" {
+			identity = "error: " + st.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", st.Profile, st.Type, expires, remaining, identity)
+	}
+	return tw.Flush()
+}
+
+// writeAccessKeyReport renders the access key report in the requested format.
+func writeAccessKeyReport(out io.Writer, report *aws.AccessKeyReport, format string) error {
+	if format == "json" {
+		return writeJSON(out, report)
+	}
+
+	fmt.Fprintf(out, "Access keys for user %s:\n", report.UserName)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCESS KEY\tSTATUS\tAGE\tLAST USED\tSERVICE\tREGION")
+	for _, key := range report.Keys {
+		lastUsed := "never"
+		if key.LastUsedDate != nil {
+			lastUsed = key.LastUsedDate.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%dd\t%s\t%s\t%s\n", key.AccessKeyID, key.Status, key.AgeDays, lastUsed, key.LastUsedService, key.LastUsedRegion)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(out, "Warning: %s\n", warning)
+	}
+	return nil
+}
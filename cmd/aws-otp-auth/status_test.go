@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+)
+
+func TestWriteAccessKeyReport(t *testing.T) {
+	report := &aws.AccessKeyReport{
+		UserName: "alice",
+		Keys: []aws.AccessKeyInfo{
+			{AccessKeyID: "AKIAOLD", Status: "Active", CreateDate: time.Now().AddDate(0, 0, -120), AgeDays: 120},
+		},
+		Warnings: []string{"access key AKIAOLD is 120 days old (maximum 90 days)"},
+	}
+
+	var text bytes.Buffer
+	if err := writeAccessKeyReport(&text, report, "text"); err != nil {
+		t.Fatalf("writeAccessKeyReport returned error: %v", err)
+	}
+	if !strings.Contains(text.String(), "AKIAOLD") || !strings.Contains(text.String(), "never") {
+		t.Errorf("Text output missing key details:\n%s", text.String())
+	}
+	if !strings.Contains(text.String(), "Warning: access key AKIAOLD") {
+		t.Errorf("Text output missing warning:\n%s", text.String())
+	}
+
+	var js bytes.Buffer
+	if err := writeAccessKeyReport(&js, report, "json"); err != nil {
+		t.Fatalf("writeAccessKeyReport returned error: %v", err)
+	}
+	var decoded aws.AccessKeyReport
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode JSON output: %v", err)
+	}
+	if decoded.UserName != "alice" || len(decoded.Keys) != 1 || decoded.Keys[0].AgeDays != 120 {
+		t.Errorf("Unexpected JSON report: %+v", decoded)
+	}
+}
+
+func TestCollectProfileStatuses(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	profiles := []aws.ProfileCredentials{
+		{Profile: "long-term", Type: aws.ProfileTypeLongTerm, Credentials: aws.Credentials{AccessKeyID: "A", SecretAccessKey: "B"}},
+		{Profile: "dev", Type: aws.ProfileTypeSession, Credentials: aws.Credentials{AccessKeyID: "A", SecretAccessKey: "B", SessionToken: "C", Expiration: now.Add(2*time.Hour + 13*time.Minute)}},
+		{Profile: "old", Type: aws.ProfileTypeSession, Credentials: aws.Credentials{AccessKeyID: "A", SecretAccessKey: "B", SessionToken: "C", Expiration: now.Add(-time.Minute)}},
+		{Profile: "revoked", Type: aws.ProfileTypeSession, Credentials: aws.Credentials{AccessKeyID: "REVOKED", SecretAccessKey: "B", SessionToken: "C", Expiration: now.Add(time.Hour)}},
+	}
+	var checked []string
+	check := func(p aws.ProfileCredentials) (*aws.CallerIdentity, error) {
+		checked = append(checked, p.Profile)
+		if p.AccessKeyID == "REVOKED" {
+			return nil, errors.New("ExpiredToken")
+		}
+		return &aws.CallerIdentity{Account: "123456789012", Arn: "arn:aws:iam::123456789012:user/alice"}, nil
+	}
+
+	statuses := collectProfileStatuses(profiles, now, check)
+	if len(statuses) != 4 {
+		t.Fatalf("Expected 4 statuses, got %d", len(statuses))
+	}
+	if statuses[0].Expiration != nil || statuses[0].Arn == "" {
+		t.Errorf("Unexpected long-term status: %+v", statuses[0])
+	}
+	if statuses[1].RemainingSeconds != int64((2*time.Hour+13*time.Minute)/time.Second) || statuses[1].Expired {
+		t.Errorf("Unexpected session status: %+v", statuses[1])
+	}
+	if !statuses[2].Expired || statuses[2].Arn != "" {
+		t.Errorf("Expected expired session not to be checked: %+v", statuses[2])
+	}
+	if statuses[3].Error != "ExpiredToken" {
+		t.Errorf("Expected check error to be reported, got %+v", statuses[3])
+	}
+	if len(checked) != 3 {
+		t.Errorf("Expected 3 live checks, got %v", checked)
+	}
+
+	var text bytes.Buffer
+	if err := writeProfileStatuses(&text, statuses, "text"); err != nil {
+		t.Fatalf("writeProfileStatuses returned error: %v", err)
+	}
+	for _, want := range []string{"2h13m", "expired", "error: ExpiredToken", "long-term"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("Text output missing %q:\n%s", want, text.String())
+		}
+	}
+
+	var js bytes.Buffer
+	if err := writeProfileStatuses(&js, statuses, "json"); err != nil {
+		t.Fatalf("writeProfileStatuses returned error: %v", err)
+	}
+	var decoded []profileStatus
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode JSON output: %v", err)
+	}
+	if len(decoded) != 4 || decoded[1].Profile != "dev" || decoded[1].Type != "session" {
+		t.Errorf("Unexpected JSON statuses: %+v", decoded)
+	}
+}
+
+func TestFormatRemaining(t *testing.T) {
+	tests := map[time.Duration]string{
+		45 * time.Second:                 "45s",
+		5*time.Minute + 30*time.Second:   "5m",
+		2*time.Hour + 13*time.Minute:     "2h13m",
+		36*time.Hour + 5*time.Minute + 1: "36h05m",
+	}
+	for d, want := range tests {
+		if got := formatRemaining(d); got != want {
+			t.Errorf("formatRemaining(%v): expected %s, got %s", d, want, got)
+		}
+	}
+}
+
+func TestAppRun_StatusKeysMaxKeyAge(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	config := filepath.Join(home, "config.toml")
+	if err := os.WriteFile(config, []byte("default = \"dev\"\n\n[profiles.dev]\nsource = \"dev-long-term\"\nmax_key_age = 30\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	app := newTestApp(&out)
+	app.Now = func() time.Time { return now }
+	iam := &fakeIAMClient{AccessKeys: []types.AccessKeyMetadata{
+		{AccessKeyId: awsSdk.String("AKIAOLD"), Status: types.StatusTypeActive, CreateDate: awsSdk.Time(now.AddDate(0, 0, -45))},
+	}}
+	var sources []string
+	app.Clients = func(ctx context.Context, profile string, endpoints *endpointOptions) (*AWSClients, error) {
+		sources = append(sources, profile)
+		return &AWSClients{IAM: iam}, nil
+	}
+	run := func(args ...string) aws.AccessKeyReport {
+		t.Helper()
+		out.Reset()
+		if code := app.Run(context.Background(), append([]string{"status", "--keys", "--user", "alice", "--config", config, "--output", "json"}, args...)); code != exitOK {
+			t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+		}
+		var report aws.AccessKeyReport
+		if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+			t.Fatalf("Failed to decode JSON output: %v", err)
+		}
+		return report
+	}
+
+	// The config file sets the threshold and the source profile.
+	if report := run(); len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "maximum 30 days") {
+		t.Errorf("Expected a warning against the configured age, got %v", report.Warnings)
+	}
+	if len(sources) != 1 || sources[0] != "dev-long-term" {
+		t.Errorf("Expected the config profile's source, got %v", sources)
+	}
+	// The flag beats the config file.
+	if report := run("--max-key-age", "60"); len(report.Warnings) != 0 {
+		t.Errorf("Expected no warning with --max-key-age 60, got %v", report.Warnings)
+	}
+	// So does the environment, and 0 disables the warning.
+	app.Environ = func() []string { return []string{"AWS_OTP_AUTH_MAX_KEY_AGE=0"} }
+	if report := run(); len(report.Warnings) != 0 {
+		t.Errorf("Expected no warning with AWS_OTP_AUTH_MAX_KEY_AGE=0, got %v", report.Warnings)
+	}
+}
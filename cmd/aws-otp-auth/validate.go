@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/auth"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+)
+
+const (
+	// sessionChecksFile caches recent validation results, keyed by a hash of the credentials.
+	sessionChecksFile = "session-checks.json"
+	// sessionCheckTTL is how long a validation result is reused.
+	sessionCheckTTL = time.Minute
+)
+
+// sessionCheck is a cached validation result.
+type sessionCheck struct {
+	State     aws.SessionState `json:"state"`
+	CheckedAt time.Time        `json:"checked_at"`
+}
+
+// sessionCheckKey identifies credentials in the cache without storing them.
+func sessionCheckKey(creds *aws.Credentials) string {
+	sum := sha256.Sum256([]byte(creds.AccessKeyID + "\x00" + creds.SessionToken))
+	return hex.EncodeToString(sum[:16])
+}
+
+// cachedValidator wraps check with a short-lived cache of valid and revoked results.
+// Unreachable results are never cached, so the next run tries again.
+func cachedValidator(check auth.Validator, now func() time.Time) auth.Validator {
+	return func(ctx context.Context, creds *aws.Credentials) (aws.SessionState, error) {
+		key := sessionCheckKey(creds)
+		checks := map[string]sessionCheck{}
+		_ = loadState(sessionChecksFile, &checks)
+		if c, ok := checks[key]; ok && now().Sub(c.CheckedAt) < sessionCheckTTL {
+			slog.Debug("Using cached session check", "state", c.State, "checked_at", c.CheckedAt)
+			return c.State, nil
+		}
+
+		state, err := check(ctx, creds)
+		if state == aws.SessionUnreachable {
+			return state, err
+		}
+		for k, c := range checks {
+			if now().Sub(c.CheckedAt) >= sessionCheckTTL {
+				delete(checks, k)
+			}
+		}
+		checks[key] = sessionCheck{State: state, CheckedAt: now()}
+		_ = saveState(sessionChecksFile, checks)
+		return state, err
+	}
+}
+
+// newSessionValidator returns a cached validator that calls GetCallerIdentity with the
+// stored credentials, giving up after timeout.
+func newSessionValidator(newClient credentialsClientFunc, timeout time.Duration, now func() time.Time) auth.Validator {
+	return cachedValidator(func(ctx context.Context, creds *aws.Credentials) (aws.SessionState, error) {
+		client, err := newClient(ctx, *creds)
+		if err != nil {
+			return aws.SessionUnreachable, err
+		}
+		return aws.ValidateSession(ctx, client, timeout)
+	}, now)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+)
+
+func TestCachedValidator(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	calls := 0
+	state := aws.SessionValid
+	validator := cachedValidator(func(ctx context.Context, creds *aws.Credentials) (aws.SessionState, error) {
+		calls++
+		if state == aws.SessionUnreachable {
+			return state, errors.New("dial tcp: i/o timeout")
+		}
+		return state, nil
+	}, clock)
+	creds := &aws.Credentials{AccessKeyID: "ASIAEXAMPLE", SessionToken: "TOKEN"}
+
+	if got, _ := validator(context.Background(), creds); got != aws.SessionValid || calls != 1 {
+		t.Fatalf("Expected first check to call STS, got %s after %d calls", got, calls)
+	}
+	if got, _ := validator(context.Background(), creds); got != aws.SessionValid || calls != 1 {
+		t.Errorf("Expected cached result, got %s after %d calls", got, calls)
+	}
+
+	// A different session is not served from the cache.
+	state = aws.SessionRevoked
+	if got, _ := validator(context.Background(), &aws.Credentials{AccessKeyID: "ASIAOTHER", SessionToken: "OTHER"}); got != aws.SessionRevoked || calls != 2 {
+		t.Errorf("Expected new check for other credentials, got %s after %d calls", got, calls)
+	}
+
+	// Once the TTL passes the session is checked again, and unreachable results are not cached.
+	now = now.Add(sessionCheckTTL)
+	state = aws.SessionUnreachable
+	if got, err := validator(context.Background(), creds); got != aws.SessionUnreachable || err == nil || calls != 3 {
+		t.Errorf("Expected unreachable after TTL, got %s (err %v) after %d calls", got, err, calls)
+	}
+	state = aws.SessionValid
+	if got, _ := validator(context.Background(), creds); got != aws.SessionValid || calls != 4 {
+		t.Errorf("Expected unreachable result not to be cached, got %s after %d calls", got, calls)
+	}
+}
@@ -0,0 +1,387 @@
+// Package fakeaws is a fake AWS STS and IAM endpoint for tests. It speaks the Query
+// protocol used by the AWS SDKs, so clients only need their endpoint pointed at it.
+package fakeaws
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the identity behind long-term access keys.
+const (
+	DefaultAccount         = "123456789012"
+	DefaultUserName        = "alice"
+	DefaultMaxRoleDuration = 3600
+)
+
+const (
+	stsNamespace = "https://sts.amazonaws.com/doc/2011-06-15/"
+	iamNamespace = "https://iam.amazonaws.com/doc/2010-05-08/"
+)
+
+// Error is an AWS error response.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+// Errors returned by AWS for common failures.
+var (
+	ErrInvalidMFACode = Error{http.StatusForbidden, "AccessDenied", "MultiFactorAuthentication failed with invalid MFA one time pass code. "}
+	ErrMFANotAssigned = Error{http.StatusForbidden, "AccessDenied", "MultiFactorAuthentication failed, unable to validate MFA code.  Please verify your MFA serial number is valid and associated with this user."}
+	ErrThrottling     = Error{http.StatusBadRequest, "Throttling", "Rate exceeded"}
+	ErrInvalidToken   = Error{http.StatusForbidden, "InvalidClientTokenId", "The security token included in the request is invalid."}
+	ErrExpiredToken   = Error{http.StatusForbidden, "ExpiredToken", "The security token included in the request is expired"}
+)
+
+// Config describes the account the server pretends to be.
+type Config struct {
+	// Account and UserName identify the IAM user behind any access key the server did not issue.
+	Account  string
+	UserName string
+	// MFADevices are the serial numbers assigned to the user. Nil means one virtual
+	// device named after the user; use an empty slice for none.
+	MFADevices []string
+	// OTP is the only MFA code accepted. If empty, any code is accepted.
+	OTP string
+	// MaxRoleDuration is the longest session AssumeRole grants, in seconds.
+	MaxRoleDuration int32
+	// Now returns the current time; nil uses time.Now.
+	Now func() time.Time
+}
+
+// Call is a request received by the server.
+type Call struct {
+	Action string
+	// Params holds the request parameters, such as TokenCode or DurationSeconds.
+	Params map[string]string
+	// AccessKeyID and Region are taken from the request signature.
+	AccessKeyID string
+	Region      string
+}
+
+// Server is a running fake STS and IAM endpoint.
+type Server struct {
+	// URL is the endpoint to point clients at.
+	URL string
+
+	cfg Config
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	calls    []Call
+	failures map[string][]Error
+	sessions map[string]*session
+	nextID   int
+}
+
+// session is a set of temporary credentials issued by the server.
+type session struct {
+	arn        string
+	account    string
+	expiration time.Time
+	revoked    bool
+}
+
+// New starts a server. Close it when done.
+func New(cfg Config) *Server {
+	if cfg.Account == "" {
+		cfg.Account = DefaultAccount
+	}
+	if cfg.UserName == "" {
+		cfg.UserName = DefaultUserName
+	}
+	if cfg.MFADevices == nil {
+		cfg.MFADevices = []string{fmt.Sprintf("arn:aws:iam::%s:mfa/%s", cfg.Account, cfg.UserName)}
+	}
+	if cfg.MaxRoleDuration == 0 {
+		cfg.MaxRoleDuration = DefaultMaxRoleDuration
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	s := &Server{cfg: cfg, failures: map[string][]Error{}, sessions: map[string]*session{}}
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// FailNext makes the next calls to action return errs, one per call, before
+// the action behaves normally again.
+func (s *Server) FailNext(action string, errs ...Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[action] = append(s.failures[action], errs...)
+}
+
+// Revoke makes every later request signed with the issued access key fail.
+func (s *Server) Revoke(accessKeyID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[accessKeyID]; ok {
+		sess.revoked = true
+	}
+}
+
+// Calls returns the requests received for action, or all requests if action is empty.
+func (s *Server) Calls(action string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []Call
+	for _, c := range s.calls {
+		if action == "" || c.Action == action {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// credentialScope matches the access key and region in a SigV4 Authorization header.
+var credentialScope = regexp.MustCompile(`Credential=([^/]+)/[^/]+/([^/]+)/`)
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	call := Call{Action: r.PostForm.Get("Action"), Params: map[string]string{}}
+	for key := range r.PostForm {
+		call.Params[key] = r.PostForm.Get(key)
+	}
+	if m := credentialScope.FindStringSubmatch(r.Header.Get("Authorization")); m != nil {
+		call.AccessKeyID, call.Region = m[1], m[2]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+	s.nextID++
+	requestID := fmt.Sprintf("fake-request-%d", s.nextID)
+
+	namespace := stsNamespace
+	if call.Action == "ListMFADevices" {
+		namespace = iamNamespace
+	}
+	result, apiErr := s.handle(call)
+	if apiErr != nil {
+		writeXML(w, apiErr.Status, errorResponse{
+			Xmlns:     namespace,
+			Error:     errorDetail{Type: "Sender", Code: apiErr.Code, Message: apiErr.Message},
+			RequestID: requestID,
+		})
+		return
+	}
+	writeXML(w, http.StatusOK, response{
+		XMLName:   xml.Name{Local: call.Action + "Response"},
+		Xmlns:     namespace,
+		Result:    result,
+		RequestID: requestID,
+	})
+}
+
+// handle returns the result for call. It is called with s.mu held.
+func (s *Server) handle(call Call) (any, *Error) {
+	if queued := s.failures[call.Action]; len(queued) > 0 {
+		s.failures[call.Action] = queued[1:]
+		return nil, &queued[0]
+	}
+	caller, apiErr := s.authenticate(call.AccessKeyID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	switch call.Action {
+	case "GetCallerIdentity":
+		return getCallerIdentityResult{Arn: caller.arn, UserID: "AIDAFAKEUSERID", Account: caller.account}, nil
+	case "GetSessionToken":
+		duration, apiErr := durationParam(call, 43200, 900, 129600)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		if apiErr := s.checkMFA(call); apiErr != nil {
+			return nil, apiErr
+		}
+		return getSessionTokenResult{Credentials: s.issue(caller.arn, caller.account, duration)}, nil
+	case "AssumeRole":
+		duration, apiErr := durationParam(call, 3600, 900, s.cfg.MaxRoleDuration)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		if apiErr := s.checkMFA(call); apiErr != nil {
+			return nil, apiErr
+		}
+		roleArn := call.Params["RoleArn"]
+		parts := strings.SplitN(roleArn, ":", 6)
+		if len(parts) != 6 || !strings.HasPrefix(parts[5], "role/") {
+			return nil, &Error{http.StatusBadRequest, "ValidationError", fmt.Sprintf("%s is invalid", roleArn)}
+		}
+		roleName := parts[5][strings.LastIndex(parts[5], "/")+1:]
+		arn := fmt.Sprintf("arn:aws:sts::%s:assumed-role/%s/%s", parts[4], roleName, call.Params["RoleSessionName"])
+		return assumeRoleResult{
+			Credentials:     s.issue(arn, parts[4], duration),
+			AssumedRoleUser: assumedRoleUser{Arn: arn, AssumedRoleID: "AROAFAKEROLEID:" + call.Params["RoleSessionName"]},
+		}, nil
+	case "ListMFADevices":
+		result := listMFADevicesResult{}
+		for _, serial := range s.cfg.MFADevices {
+			result.MFADevices = append(result.MFADevices, mfaDevice{
+				UserName:     s.cfg.UserName,
+				SerialNumber: serial,
+				EnableDate:   s.cfg.Now().UTC().Format(time.RFC3339),
+			})
+		}
+		return result, nil
+	}
+	return nil, &Error{http.StatusBadRequest, "InvalidAction", fmt.Sprintf("Could not find operation %s", call.Action)}
+}
+
+// authenticate returns the principal behind accessKeyID. Keys the server did not
+// issue belong to the configured IAM user.
+func (s *Server) authenticate(accessKeyID string) (*session, *Error) {
+	sess, ok := s.sessions[accessKeyID]
+	switch {
+	case !ok && strings.HasPrefix(accessKeyID, "ASIA"):
+		return nil, &ErrInvalidToken
+	case !ok:
+		return &session{arn: fmt.Sprintf("arn:aws:iam::%s:user/%s", s.cfg.Account, s.cfg.UserName), account: s.cfg.Account}, nil
+	case sess.revoked:
+		return nil, &ErrInvalidToken
+	case !s.cfg.Now().Before(sess.expiration):
+		return nil, &ErrExpiredToken
+	}
+	return sess, nil
+}
+
+// checkMFA validates the SerialNumber and TokenCode parameters, if given.
+func (s *Server) checkMFA(call Call) *Error {
+	serial, ok := call.Params["SerialNumber"]
+	if !ok {
+		return nil
+	}
+	assigned := false
+	for _, device := range s.cfg.MFADevices {
+		assigned = assigned || device == serial
+	}
+	if !assigned {
+		return &ErrMFANotAssigned
+	}
+	if s.cfg.OTP != "" && call.Params["TokenCode"] != s.cfg.OTP {
+		return &ErrInvalidMFACode
+	}
+	return nil
+}
+
+// issue creates temporary credentials for the principal.
+func (s *Server) issue(arn, account string, durationSeconds int32) credentials {
+	n := len(s.sessions) + 1
+	expiration := s.cfg.Now().Add(time.Duration(durationSeconds) * time.Second).UTC()
+	accessKeyID := fmt.Sprintf("ASIAFAKE%012d", n)
+	s.sessions[accessKeyID] = &session{arn: arn, account: account, expiration: expiration}
+	return credentials{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: fmt.Sprintf("fake-secret-access-key-%d", n),
+		SessionToken:    fmt.Sprintf("fake-session-token-%d", n),
+		Expiration:      expiration.Format(time.RFC3339),
+	}
+}
+
+// durationParam returns the DurationSeconds parameter, or def if absent, checking it lies within [min, max].
+func durationParam(call Call, def, min, max int32) (int32, *Error) {
+	value, ok := call.Params["DurationSeconds"]
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || int32(n) < min || int32(n) > max {
+		return 0, &Error{http.StatusBadRequest, "ValidationError", fmt.Sprintf("The requested DurationSeconds %s exceeds the allowed range of %d to %d seconds.", value, min, max)}
+	}
+	return int32(n), nil
+}
+
+// writeXML writes v as the response body.
+func writeXML(w http.ResponseWriter, status int, v any) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+type response struct {
+	XMLName   xml.Name
+	Xmlns     string `xml:"xmlns,attr"`
+	Result    any
+	RequestID string `xml:"ResponseMetadata>RequestId"`
+}
+
+type errorResponse struct {
+	XMLName   xml.Name    `xml:"ErrorResponse"`
+	Xmlns     string      `xml:"xmlns,attr"`
+	Error     errorDetail `xml:"Error"`
+	RequestID string      `xml:"RequestId"`
+}
+
+type errorDetail struct {
+	Type    string
+	Code    string
+	Message string
+}
+
+type credentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      string
+}
+
+type getCallerIdentityResult struct {
+	XMLName xml.Name `xml:"GetCallerIdentityResult"`
+	Arn     string
+	UserID  string `xml:"UserId"`
+	Account string
+}
+
+type getSessionTokenResult struct {
+	XMLName     xml.Name `xml:"GetSessionTokenResult"`
+	Credentials credentials
+}
+
+type assumedRoleUser struct {
+	Arn           string
+	AssumedRoleID string `xml:"AssumedRoleId"`
+}
+
+type assumeRoleResult struct {
+	XMLName         xml.Name `xml:"AssumeRoleResult"`
+	Credentials     credentials
+	AssumedRoleUser assumedRoleUser
+}
+
+type mfaDevice struct {
+	UserName     string
+	SerialNumber string
+	EnableDate   string
+}
+
+type listMFADevicesResult struct {
+	XMLName     xml.Name    `xml:"ListMFADevicesResult"`
+	IsTruncated bool        `xml:"IsTruncated"`
+	MFADevices  []mfaDevice `xml:"MFADevices>member"`
+}
@@ -0,0 +1,131 @@
+package fakeaws
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+
+	awsPkg "github.com/aws/aws-sdk-go-v2/aws"
+	awsCredentials "github.com/aws/aws-sdk-go-v2/credentials"
+	awsIam "github.com/aws/aws-sdk-go-v2/service/iam"
+	awsSts "github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const mfaArn = "arn:aws:iam::123456789012:mfa/alice"
+
+// clientConfig returns an SDK config that signs with the given keys and sends requests to s.
+func clientConfig(s *Server, accessKeyID, secretAccessKey, sessionToken string) awsPkg.Config {
+	return awsPkg.Config{
+		Region:           "eu-west-1",
+		BaseEndpoint:     awsPkg.String(s.URL),
+		Credentials:      awsCredentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, sessionToken),
+		RetryMaxAttempts: 1,
+	}
+}
+
+// noRetries makes the wrapped clients give up after the first attempt, so the tests see each failure.
+type noRetries struct{}
+
+func (noRetries) RetryPolicy() aws.RetryPolicy { return aws.RetryPolicy{MaxAttempts: 1} }
+
+type stsClient struct {
+	*awsSts.Client
+	noRetries
+}
+
+type iamClient struct {
+	*awsIam.Client
+	noRetries
+}
+
+func newSTSClient(cfg awsPkg.Config) stsClient { return stsClient{Client: awsSts.NewFromConfig(cfg)} }
+
+func newIAMClient(cfg awsPkg.Config) iamClient { return iamClient{Client: awsIam.NewFromConfig(cfg)} }
+
+func TestServer_SessionToken(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := New(Config{OTP: "123456", Now: func() time.Time { return now }})
+	defer s.Close()
+	ctx := context.Background()
+	client := newSTSClient(clientConfig(s, "AKIAFAKELONGTERM", "secret", ""))
+
+	identity, err := aws.GetCallerIdentity(ctx, client)
+	if err != nil {
+		t.Fatalf("GetCallerIdentity returned error: %v", err)
+	}
+	if identity.Arn != "arn:aws:iam::123456789012:user/alice" {
+		t.Errorf("Expected user ARN, got %s", identity.Arn)
+	}
+
+	if _, err := aws.GetSessionToken(ctx, client, mfaArn, "654321", 3600); !aws.IsInvalidMFACode(err) {
+		t.Errorf("Expected invalid MFA code error, got %v", err)
+	}
+	session, err := aws.GetSessionToken(ctx, client, mfaArn, "123456", 3600)
+	if err != nil {
+		t.Fatalf("GetSessionToken returned error: %v", err)
+	}
+	if !session.Expiration.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected expiration %v, got %v", now.Add(time.Hour), session.Expiration)
+	}
+
+	calls := s.Calls("GetSessionToken")
+	if len(calls) != 2 || calls[1].Params["TokenCode"] != "123456" || calls[1].Params["SerialNumber"] != mfaArn || calls[1].Region != "eu-west-1" {
+		t.Errorf("Unexpected GetSessionToken calls: %+v", calls)
+	}
+
+	sessionClient := newSTSClient(clientConfig(s, session.AccessKeyID, session.SecretAccessKey, session.SessionToken))
+	if err := aws.CheckAuthentication(ctx, sessionClient); err != nil {
+		t.Errorf("Expected issued session to be valid, got %v", err)
+	}
+	s.Revoke(session.AccessKeyID)
+	if _, err := aws.ValidateSession(ctx, sessionClient, time.Second); aws.ClassifySessionError(err) != aws.SessionRevoked {
+		t.Errorf("Expected revoked session, got %v", err)
+	}
+}
+
+func TestServer_AssumeRole(t *testing.T) {
+	s := New(Config{})
+	defer s.Close()
+	ctx := context.Background()
+	client := newSTSClient(clientConfig(s, "AKIAFAKELONGTERM", "secret", ""))
+
+	if _, err := aws.AssumeRole(ctx, client, "arn:aws:iam::210987654321:role/Admin", mfaArn, "000000", 7200); err == nil {
+		t.Errorf("Expected error for duration above the role maximum, got nil")
+	}
+	creds, err := aws.AssumeRole(ctx, client, "arn:aws:iam::210987654321:role/Admin", mfaArn, "000000", 900)
+	if err != nil {
+		t.Fatalf("AssumeRole returned error: %v", err)
+	}
+	identity, err := aws.GetCallerIdentity(ctx, newSTSClient(clientConfig(s, creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken)))
+	if err != nil {
+		t.Fatalf("GetCallerIdentity returned error: %v", err)
+	}
+	if identity.Account != "210987654321" || !strings.HasPrefix(identity.Arn, "arn:aws:sts::210987654321:assumed-role/Admin/") {
+		t.Errorf("Unexpected role identity: %+v", identity)
+	}
+}
+
+func TestServer_ListMFADevicesAndFailures(t *testing.T) {
+	s := New(Config{MFADevices: []string{mfaArn, "GAHT12345678"}})
+	defer s.Close()
+	ctx := context.Background()
+	client := newIAMClient(clientConfig(s, "AKIAFAKELONGTERM", "secret", ""))
+
+	s.FailNext("ListMFADevices", ErrThrottling)
+	if _, err := aws.ListMFADevices(ctx, client, "alice"); !aws.IsRetryable(err) {
+		t.Errorf("Expected throttling error, got %v", err)
+	}
+	devices, err := aws.ListMFADevices(ctx, client, "alice")
+	if err != nil {
+		t.Fatalf("ListMFADevices returned error: %v", err)
+	}
+	if len(devices) != 2 || devices[0].SerialNumber != mfaArn || devices[1].Type != aws.MFADeviceTypeHardware {
+		t.Errorf("Unexpected devices: %+v", devices)
+	}
+	if calls := s.Calls(""); len(calls) != 2 {
+		t.Errorf("Expected 2 calls, got %d", len(calls))
+	}
+}
@@ -0,0 +1,162 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DefaultMaxBytes is the size at which the audit log is rotated.
+	DefaultMaxBytes = 1 << 20
+	// DefaultMaxFiles is the number of rotated audit logs kept.
+	DefaultMaxFiles = 5
+)
+
+// Entry records one authentication attempt. It never holds credentials or OTPs.
+type Entry struct {
+	Time            time.Time `json:"time"`
+	SourceProfile   string    `json:"source_profile"`
+	TargetProfile   string    `json:"target_profile"`
+	MFAArn          string    `json:"mfa_arn,omitempty"`
+	RoleArn         string    `json:"role_arn,omitempty"`
+	ARNAccount      string    `json:"arn_account,omitempty"` // from RoleArn, else MFAArn; not checked against the identity
+	DurationSeconds int32     `json:"duration_seconds,omitempty"`
+	Outcome         string    `json:"outcome"`
+	ErrorCode       string    `json:"error_code,omitempty"`
+	Version         string    `json:"version"`
+	Hostname        string    `json:"hostname"`
+}
+
+// Filter selects entries. Zero fields match everything.
+type Filter struct {
+	// Profile matches either the source or the target profile.
+	Profile string
+	Since   time.Time
+	Until   time.Time
+}
+
+// Match reports whether e is selected by f.
+func (f Filter) Match(e Entry) bool {
+	if f.Profile != "" && e.SourceProfile != f.Profile && e.TargetProfile != f.Profile {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Log is an append-only JSON Lines file, rotated to Path.1 … Path.MaxFiles by size.
+type Log struct {
+	Path     string
+	MaxBytes int64
+	MaxFiles int
+}
+
+// DefaultPath returns the location of the audit log, ~/.config/aws-otp-auth/audit.jsonl.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine user home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "aws-otp-auth", "audit.jsonl"), nil
+}
+
+// Append writes e as one line, rotating the log first if it would grow past MaxBytes.
+func (l *Log) Append(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	if info, err := os.Stat(l.Path); err == nil && l.MaxBytes > 0 && info.Size()+int64(len(line)) > l.MaxBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	// A single write with O_APPEND keeps lines from concurrent runs intact.
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// rotate shifts Path.i to Path.i+1, dropping the oldest, and moves Path to Path.1.
+func (l *Log) rotate() error {
+	if l.MaxFiles < 1 {
+		return os.Remove(l.Path)
+	}
+	if err := os.Remove(l.rotated(l.MaxFiles)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	for i := l.MaxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(l.rotated(i), l.rotated(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+	if err := os.Rename(l.Path, l.rotated(1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	return nil
+}
+
+func (l *Log) rotated(i int) string {
+	return fmt.Sprintf("%s.%d", l.Path, i)
+}
+
+// Read returns the entries selected by f, oldest first, across the current and rotated files.
+func (l *Log) Read(f Filter) ([]Entry, error) {
+	paths := []string{}
+	for i := l.MaxFiles; i >= 1; i-- {
+		paths = append(paths, l.rotated(i))
+	}
+	paths = append(paths, l.Path)
+
+	var entries []Entry
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		scanner := bufio.NewScanner(file)
+		for n := 1; scanner.Scan(); n++ {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var e Entry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("failed to parse %s line %d: %w", path, n, err)
+			}
+			if f.Match(e) {
+				entries = append(entries, e)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+	return entries, nil
+}
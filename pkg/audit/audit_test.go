@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	log := &Log{Path: filepath.Join(t.TempDir(), "audit", "audit.jsonl"), MaxBytes: DefaultMaxBytes, MaxFiles: DefaultMaxFiles}
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: base, SourceProfile: "default-long-term", TargetProfile: "default", Outcome: "refreshed"},
+		{Time: base.Add(24 * time.Hour), SourceProfile: "default-long-term", TargetProfile: "prod-admin", Outcome: "failed", ErrorCode: "bad_otp"},
+		{Time: base.Add(48 * time.Hour), SourceProfile: "default-long-term", TargetProfile: "default", Outcome: "valid"},
+	}
+	for _, e := range entries {
+		if err := log.Append(e); err != nil {
+			t.Fatalf("Append returned error: %v", err)
+		}
+	}
+
+	info, err := os.Stat(log.Path)
+	if err != nil {
+		t.Fatalf("Audit log not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected audit log mode 0600, got %v", info.Mode().Perm())
+	}
+
+	all, err := log.Read(Filter{})
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if len(all) != 3 || all[1].ErrorCode != "bad_otp" {
+		t.Errorf("Unexpected entries: %+v", all)
+	}
+
+	got, err := log.Read(Filter{Profile: "default", Since: base.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if len(got) != 1 || got[0].Outcome != "valid" {
+		t.Errorf("Expected one filtered entry, got %+v", got)
+	}
+
+	got, _ = log.Read(Filter{Until: base.Add(24 * time.Hour)})
+	if len(got) != 1 || got[0].Outcome != "refreshed" {
+		t.Errorf("Expected Until to be exclusive, got %+v", got)
+	}
+}
+
+func TestAppend_Rotates(t *testing.T) {
+	dir := t.TempDir()
+	log := &Log{Path: filepath.Join(dir, "audit.jsonl"), MaxBytes: 400, MaxFiles: 2}
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		e := Entry{Time: base.Add(time.Duration(i) * time.Minute), SourceProfile: "src", TargetProfile: "dst", Outcome: "refreshed", Hostname: strings.Repeat("h", 20)}
+		if err := log.Append(e); err != nil {
+			t.Fatalf("Append returned error: %v", err)
+		}
+	}
+
+	for _, name := range []string{"audit.jsonl", "audit.jsonl.1", "audit.jsonl.2"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Expected %s to exist: %v", name, err)
+		}
+		if info.Size() > log.MaxBytes {
+			t.Errorf("%s is %d bytes, over the %d byte limit", name, info.Size(), log.MaxBytes)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "audit.jsonl.3")); err == nil {
+		t.Errorf("Expected at most %d rotated files", log.MaxFiles)
+	}
+
+	got, err := log.Read(Filter{})
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if len(got) == 0 || len(got) >= 12 {
+		t.Fatalf("Expected the oldest entries to be dropped, got %d", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if !got[i].Time.After(got[i-1].Time) {
+			t.Errorf("Expected entries oldest first, got %v before %v", got[i-1].Time, got[i].Time)
+		}
+	}
+	if !got[len(got)-1].Time.Equal(base.Add(11 * time.Minute)) {
+		t.Errorf("Expected the newest entry last, got %v", got[len(got)-1].Time)
+	}
+}
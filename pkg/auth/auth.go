@@ -0,0 +1,205 @@
+// Package auth implements the authentication flow of aws-otp-auth: a profile's
+// stored session is kept while it is valid, and otherwise an OTP is exchanged for
+// new session or role credentials, which are then stored.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/crbanman/aws-otp-auth/pkg/logging"
+)
+
+// Actions reported in a Result.
+const (
+	ActionValid     = "valid"     // the stored session is still valid
+	ActionKept      = "kept"      // the stored session could not be verified and was kept
+	ActionRefreshed = "refreshed" // new credentials were written
+	ActionFailed    = "failed"    // no credentials were written
+)
+
+// STSClient combines the STS methods used by the flow.
+type STSClient interface {
+	GetCallerIdentity(ctx context.Context, input *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+	GetSessionToken(ctx context.Context, input *sts.GetSessionTokenInput, optFns ...func(*sts.Options)) (*sts.GetSessionTokenOutput, error)
+	AssumeRole(ctx context.Context, input *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error)
+}
+
+// Validator checks stored credentials with STS before they are trusted.
+type Validator func(ctx context.Context, creds *aws.Credentials) (aws.SessionState, error)
+
+// Hooks are called around a refresh. Either may be nil.
+type Hooks struct {
+	// BeforeRefresh is called when the stored session needs renewal, before the
+	// OTP is obtained. An error aborts the refresh.
+	BeforeRefresh func(ctx context.Context, req Request) error
+	// AfterRefresh is called once the new credentials have been stored.
+	AfterRefresh func(ctx context.Context, req Request, res *Result, creds *aws.SessionCredentials)
+}
+
+// Request describes the credentials to obtain.
+type Request struct {
+	// Profile is the profile the credentials are stored under.
+	Profile string
+	// MFAArn is the serial number or ARN of the MFA device.
+	MFAArn string
+	// RoleArn, if set, is assumed with MFA instead of requesting a session token.
+	RoleArn string
+	// DurationSeconds is the requested session duration.
+	DurationSeconds int32
+	// Force requests new credentials even if the stored session is valid.
+	Force bool
+}
+
+// Result is the outcome of Authenticate.
+type Result struct {
+	Profile string
+	// Action is one of ActionValid, ActionKept, ActionRefreshed or ActionFailed.
+	Action string
+	// Expiration is when the profile's session expires; zero if it is unknown.
+	Expiration time.Time
+	// Err is why the profile failed, in the results of AuthenticateChain.
+	Err error
+}
+
+// Authenticator runs the authentication flow. Create one with New.
+type Authenticator struct {
+	sts      STSClient
+	otp      OTPProvider
+	store    Store
+	policy   ExpiryPolicy
+	validate Validator
+	hooks    Hooks
+	redactor *logging.Redactor
+	roles    RoleClientFunc
+}
+
+// Option configures an Authenticator.
+type Option func(*Authenticator)
+
+// WithOTPProvider sets how the OTP is obtained. The default prompts on stdin.
+func WithOTPProvider(p OTPProvider) Option { return func(a *Authenticator) { a.otp = p } }
+
+// WithStore sets where credentials are read and written. The default is FileStore.
+func WithStore(s Store) Option { return func(a *Authenticator) { a.store = s } }
+
+// WithClock sets the clock used for expiry decisions. The default is time.Now.
+func WithClock(now func() time.Time) Option { return func(a *Authenticator) { a.policy.Now = now } }
+
+// WithMinRemaining renews stored sessions that expire within d.
+func WithMinRemaining(d time.Duration) Option {
+	return func(a *Authenticator) { a.policy.MinRemaining = d }
+}
+
+// WithValidator checks unexpired sessions with v before keeping them. A session
+// that v reports as unreachable is kept.
+func WithValidator(v Validator) Option { return func(a *Authenticator) { a.validate = v } }
+
+// WithHooks sets the functions called around a refresh.
+func WithHooks(h Hooks) Option { return func(a *Authenticator) { a.hooks = h } }
+
+// WithRoleClient sets how AuthenticateChain obtains an STS client that signs with
+// the MFA session, to assume the requested roles with it.
+func WithRoleClient(f RoleClientFunc) Option { return func(a *Authenticator) { a.roles = f } }
+
+// WithRedactor registers the credentials and OTPs handled by the flow with r, so
+// that they are scrubbed from logs.
+func WithRedactor(r *logging.Redactor) Option { return func(a *Authenticator) { a.redactor = r } }
+
+// New returns an Authenticator that calls STS with client.
+func New(client STSClient, opts ...Option) *Authenticator {
+	a := &Authenticator{sts: client, otp: PromptOTP(nil), store: FileStore{}}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// keepStored reports whether the profile's stored session can be kept, setting the
+// action and expiration of res if so.
+func (a *Authenticator) keepStored(ctx context.Context, req Request, res *Result) bool {
+	creds, err := a.store.Read(req.Profile)
+	if err != nil {
+		slog.Debug("No usable credentials in target profile", "profile", req.Profile, "err", err)
+		return false
+	}
+	a.addSecrets(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken)
+
+	// If force is not set and the stored session is still valid, keep it.
+	if req.Force || a.policy.NeedsRefresh(creds) {
+		return false
+	}
+	state := aws.SessionValid
+	var checkErr error
+	if a.validate != nil {
+		state, checkErr = a.validate(ctx, creds)
+	}
+	switch state {
+	case aws.SessionValid:
+		slog.Info("Existing credentials are valid; no update necessary", "profile", req.Profile, "expiration", creds.Expiration)
+		res.Action, res.Expiration = ActionValid, creds.Expiration
+		return true
+	case aws.SessionUnreachable:
+		slog.Warn("Unable to reach STS to verify credentials; keeping the existing session", "profile", req.Profile, "err", checkErr)
+		res.Action, res.Expiration = ActionKept, creds.Expiration
+		return true
+	}
+	slog.Info("Existing credentials are no longer accepted; requesting a new session", "profile", req.Profile, "state", state, "err", checkErr)
+	return false
+}
+
+// addSecrets registers secret values with the redactor, if any.
+func (a *Authenticator) addSecrets(secrets ...string) {
+	if a.redactor != nil {
+		a.redactor.Add(secrets...)
+	}
+}
+
+// Authenticate keeps the profile's stored session if it is valid and not due for
+// renewal, and otherwise obtains an OTP, requests new credentials and stores them.
+// The result is never nil; on error its action is ActionFailed.
+func (a *Authenticator) Authenticate(ctx context.Context, req Request) (*Result, error) {
+	res := &Result{Profile: req.Profile, Action: ActionFailed}
+	if a.keepStored(ctx, req, res) {
+		return res, nil
+	}
+
+	if a.hooks.BeforeRefresh != nil {
+		if err := a.hooks.BeforeRefresh(ctx, req); err != nil {
+			return res, fmt.Errorf("pre-refresh hook failed: %w", err)
+		}
+	}
+
+	code, err := a.otp(ctx)
+	if err != nil {
+		return res, fmt.Errorf("failed to obtain OTP: %w", err)
+	}
+	a.addSecrets(code)
+
+	slog.Debug("Requesting new credentials", "profile", req.Profile, "mfa_arn", req.MFAArn, "role_arn", req.RoleArn, "duration", req.DurationSeconds)
+	var newCreds *aws.SessionCredentials
+	if req.RoleArn != "" {
+		newCreds, err = aws.AssumeRole(ctx, a.sts, req.RoleArn, req.MFAArn, code, req.DurationSeconds)
+	} else {
+		newCreds, err = aws.GetSessionToken(ctx, a.sts, req.MFAArn, code, req.DurationSeconds)
+	}
+	if err != nil {
+		return res, fmt.Errorf("failed to get new session token: %w", err)
+	}
+	a.addSecrets(newCreds.AccessKeyID, newCreds.SecretAccessKey, newCreds.SessionToken)
+
+	if err := a.store.Write(req.Profile, newCreds); err != nil {
+		return res, fmt.Errorf("failed to store credentials: %w", err)
+	}
+	slog.Info("AWS credentials successfully updated", "profile", req.Profile, "expiration", newCreds.Expiration)
+
+	res.Action, res.Expiration = ActionRefreshed, newCreds.Expiration
+	if a.hooks.AfterRefresh != nil {
+		a.hooks.AfterRefresh(ctx, req, res, newCreds)
+	}
+	return res, nil
+}
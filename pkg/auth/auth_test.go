@@ -0,0 +1,344 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+	"github.com/crbanman/aws-otp-auth/pkg/logging"
+	"gopkg.in/ini.v1"
+)
+
+// mockSTSClient implements STSClient.
+type mockSTSClient struct {
+	SessionTokenValid bool
+	SessionTokenCalls int
+	Expiration        time.Time
+}
+
+func (m *mockSTSClient) GetCallerIdentity(ctx context.Context, input *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Account: awsSdk.String("123456789012"), Arn: awsSdk.String("arn:aws:iam::123456789012:user/test")}, nil
+}
+
+func (m *mockSTSClient) GetSessionToken(ctx context.Context, input *sts.GetSessionTokenInput, optFns ...func(*sts.Options)) (*sts.GetSessionTokenOutput, error) {
+	m.SessionTokenCalls++
+	if !m.SessionTokenValid {
+		return nil, fmt.Errorf("failed to get session token")
+	}
+	return &sts.GetSessionTokenOutput{
+		Credentials: &types.Credentials{
+			AccessKeyId:     awsSdk.String("newAccessKey"),
+			SecretAccessKey: awsSdk.String("newSecretKey"),
+			SessionToken:    awsSdk.String("newSessionToken"),
+			Expiration:      awsSdk.Time(m.expiration()),
+		},
+	}, nil
+}
+
+func (m *mockSTSClient) AssumeRole(ctx context.Context, input *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	return &sts.AssumeRoleOutput{
+		Credentials: &types.Credentials{
+			AccessKeyId:     awsSdk.String("roleAccessKey"),
+			SecretAccessKey: awsSdk.String("roleSecretKey"),
+			SessionToken:    awsSdk.String("roleSessionToken"),
+			Expiration:      awsSdk.Time(m.expiration()),
+		},
+	}, nil
+}
+
+func (m *mockSTSClient) expiration() time.Time {
+	if m.Expiration.IsZero() {
+		return time.Now().Add(time.Hour)
+	}
+	return m.Expiration
+}
+
+// writeCredentials sets HOME to a temporary directory holding a credentials file with content.
+func writeCredentials(t *testing.T, content string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".aws"), 0755); err != nil {
+		t.Fatalf("Failed to create .aws directory: %v", err)
+	}
+	credsPath := filepath.Join(home, ".aws", "credentials")
+	if err := os.WriteFile(credsPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write credentials file: %v", err)
+	}
+	return credsPath
+}
+
+// sessionProfile returns a credentials file section holding a session that expires at expiration.
+func sessionProfile(name string, expiration time.Time) string {
+	return "[" + name + "]\naws_access_key_id = DUMMY\naws_secret_access_key = DUMMYSECRET\naws_session_token = DUMMYTOKEN\naws_session_token_expiration = " + expiration.Format(time.RFC3339) + "\n"
+}
+
+func TestAuthenticate_Refresh(t *testing.T) {
+	credsPath := writeCredentials(t, "[default]\naws_access_key_id = INVALID\naws_secret_access_key = INVALID\n")
+
+	client := &mockSTSClient{SessionTokenValid: true}
+	res, err := New(client, WithOTPProvider(PromptOTP(strings.NewReader("654321\n")))).Authenticate(context.Background(), Request{Profile: "default", MFAArn: "dummy-mfa-arn", DurationSeconds: 28800})
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if res.Action != ActionRefreshed || res.Profile != "default" || res.Expiration.IsZero() {
+		t.Errorf("Unexpected result: %+v", res)
+	}
+
+	cfg, err := ini.Load(credsPath)
+	if err != nil {
+		t.Fatalf("Failed to load updated credentials file: %v", err)
+	}
+	section := cfg.Section("default")
+	if section.Key("aws_access_key_id").String() != "newAccessKey" {
+		t.Errorf("Expected aws_access_key_id to be 'newAccessKey', got %s", section.Key("aws_access_key_id").String())
+	}
+	if section.Key("aws_secret_access_key").String() != "newSecretKey" {
+		t.Errorf("Expected aws_secret_access_key to be 'newSecretKey', got %s", section.Key("aws_secret_access_key").String())
+	}
+	if section.Key("aws_session_token").String() != "newSessionToken" {
+		t.Errorf("Expected aws_session_token to be 'newSessionToken', got %s", section.Key("aws_session_token").String())
+	}
+}
+
+func TestAuthenticate_ValidTargetCredentials(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second)
+	writeCredentials(t, sessionProfile("default", expiration))
+
+	client := &mockSTSClient{SessionTokenValid: true}
+	otpErr := errors.New("OTP requested")
+	res, err := New(client, WithOTPProvider(func(ctx context.Context) (string, error) { return "", otpErr })).Authenticate(context.Background(), Request{Profile: "default", MFAArn: "dummy-mfa-arn", DurationSeconds: 28800})
+	if err != nil {
+		t.Fatalf("Authenticate failed when token was valid: %v", err)
+	}
+	if res.Action != ActionValid || !res.Expiration.Equal(expiration) || client.SessionTokenCalls != 0 {
+		t.Errorf("Expected the valid session to be kept, got %+v after %d calls", res, client.SessionTokenCalls)
+	}
+}
+
+func TestAuthenticate_AssumeRole(t *testing.T) {
+	credsPath := writeCredentials(t, "[default-long-term]\naws_access_key_id = LONG\naws_secret_access_key = LONGSECRET\n")
+
+	res, err := New(&mockSTSClient{}, WithOTPProvider(StaticOTP("123456"))).Authenticate(context.Background(), Request{Profile: "admin", MFAArn: "dummy-mfa-arn", RoleArn: "arn:aws:iam::123456789012:role/Admin", DurationSeconds: 3600})
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if res.Action != ActionRefreshed {
+		t.Errorf("Expected refresh, got %+v", res)
+	}
+
+	cfg, err := ini.Load(credsPath)
+	if err != nil {
+		t.Fatalf("Failed to load updated credentials file: %v", err)
+	}
+	if got := cfg.Section("admin").Key("aws_session_token").String(); got != "roleSessionToken" {
+		t.Errorf("Expected role session token, got %s", got)
+	}
+}
+
+func TestAuthenticate_Failure(t *testing.T) {
+	store := &MemoryStore{}
+	res, err := New(&mockSTSClient{}, WithStore(store), WithOTPProvider(StaticOTP("123456"))).Authenticate(context.Background(), Request{Profile: "default", MFAArn: "dummy-mfa-arn", DurationSeconds: 3600})
+	if err == nil || res == nil || res.Action != ActionFailed {
+		t.Fatalf("Expected a failed result, got %+v (err %v)", res, err)
+	}
+	if _, err := store.Read("default"); err == nil {
+		t.Errorf("Expected no credentials to be stored")
+	}
+}
+
+func TestAuthenticate_Validation(t *testing.T) {
+	tests := []struct {
+		name       string
+		state      aws.SessionState
+		wantAction string
+	}{
+		{"valid", aws.SessionValid, ActionValid},
+		{"revoked", aws.SessionRevoked, ActionRefreshed},
+		{"expired early", aws.SessionExpired, ActionRefreshed},
+		{"unreachable", aws.SessionUnreachable, ActionKept},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credsPath := writeCredentials(t, sessionProfile("default", time.Now().Add(time.Hour)))
+
+			var checked *aws.Credentials
+			validator := func(ctx context.Context, creds *aws.Credentials) (aws.SessionState, error) {
+				checked = creds
+				return tt.state, nil
+			}
+			a := New(&mockSTSClient{SessionTokenValid: true}, WithOTPProvider(PromptOTP(strings.NewReader("123456\n"))), WithValidator(validator))
+			res, err := a.Authenticate(context.Background(), Request{Profile: "default", MFAArn: "dummy-mfa-arn", DurationSeconds: 28800})
+			if err != nil {
+				t.Fatalf("Authenticate failed: %v", err)
+			}
+			if res.Action != tt.wantAction {
+				t.Errorf("Expected action %s, got %s", tt.wantAction, res.Action)
+			}
+			if checked == nil || checked.SessionToken != "DUMMYTOKEN" {
+				t.Errorf("Expected the stored session to be validated, got %+v", checked)
+			}
+
+			cfg, err := ini.Load(credsPath)
+			if err != nil {
+				t.Fatalf("Failed to load credentials file: %v", err)
+			}
+			refreshed := cfg.Section("default").Key("aws_session_token").String() == "newSessionToken"
+			if refreshed != (tt.wantAction == ActionRefreshed) {
+				t.Errorf("Expected action %s, but refreshed=%v", tt.wantAction, refreshed)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_MinRemaining(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	expiration := now.Add(5 * time.Minute)
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		minRemaining time.Duration
+		want         string
+	}{
+		{"outside window", now.Add(-time.Second), 5 * time.Minute, ActionValid},
+		{"at window boundary", now, 5 * time.Minute, ActionRefreshed},
+		{"no window", now.Add(5*time.Minute - time.Second), 0, ActionValid},
+		{"expired", expiration, 0, ActionRefreshed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MemoryStore{}
+			store.Write("default", &aws.SessionCredentials{AccessKeyID: "DUMMY", SecretAccessKey: "DUMMYSECRET", SessionToken: "DUMMYTOKEN", Expiration: expiration})
+			a := New(&mockSTSClient{SessionTokenValid: true}, WithStore(store), WithOTPProvider(StaticOTP("123456")), WithClock(func() time.Time { return tt.now }), WithMinRemaining(tt.minRemaining))
+			res, err := a.Authenticate(context.Background(), Request{Profile: "default", MFAArn: "dummy-mfa-arn", DurationSeconds: 28800})
+			if err != nil {
+				t.Fatalf("Authenticate returned error: %v", err)
+			}
+			if res.Action != tt.want {
+				t.Errorf("Expected action %s, got %s", tt.want, res.Action)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_Hooks(t *testing.T) {
+	store := &MemoryStore{}
+	var events []string
+	hooks := Hooks{
+		BeforeRefresh: func(ctx context.Context, req Request) error {
+			events = append(events, "before "+req.Profile)
+			return nil
+		},
+		AfterRefresh: func(ctx context.Context, req Request, res *Result, creds *aws.SessionCredentials) {
+			events = append(events, "after "+res.Action+" "+creds.AccessKeyID)
+		},
+	}
+	a := New(&mockSTSClient{SessionTokenValid: true}, WithStore(store), WithOTPProvider(StaticOTP("123456")), WithHooks(hooks))
+	if _, err := a.Authenticate(context.Background(), Request{Profile: "default", MFAArn: "dummy-mfa-arn", DurationSeconds: 3600}); err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	// The stored session is now valid, so no hooks run.
+	if _, err := a.Authenticate(context.Background(), Request{Profile: "default", MFAArn: "dummy-mfa-arn", DurationSeconds: 3600}); err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	if strings.Join(events, ", ") != "before default, after refreshed newAccessKey" {
+		t.Errorf("Unexpected hook calls: %v", events)
+	}
+
+	hooks.BeforeRefresh = func(ctx context.Context, req Request) error { return errors.New("not now") }
+	res, err := New(&mockSTSClient{SessionTokenValid: true}, WithStore(&MemoryStore{}), WithOTPProvider(StaticOTP("123456")), WithHooks(hooks)).Authenticate(context.Background(), Request{Profile: "default"})
+	if err == nil || res.Action != ActionFailed {
+		t.Errorf("Expected a failing pre-refresh hook to abort, got %+v (err %v)", res, err)
+	}
+}
+
+func TestAuthenticate_Redactor(t *testing.T) {
+	redactor := &logging.Redactor{}
+	a := New(&mockSTSClient{SessionTokenValid: true}, WithStore(&MemoryStore{}), WithOTPProvider(StaticOTP("654321")), WithRedactor(redactor))
+	if _, err := a.Authenticate(context.Background(), Request{Profile: "default", MFAArn: "dummy-mfa-arn", DurationSeconds: 3600}); err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	out := redactor.String("otp 654321 secret newSecretKey token newSessionToken")
+	for _, secret := range []string{"654321", "newSecretKey", "newSessionToken"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Expected %s to be registered with the redactor, got %q", secret, out)
+		}
+	}
+}
+
+func TestAuthenticateChain(t *testing.T) {
+	store := &MemoryStore{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := store.Write("current", &aws.SessionCredentials{AccessKeyID: "CUR", SessionToken: "CURTOKEN", Expiration: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Failed to seed store: %v", err)
+	}
+	client := &mockSTSClient{SessionTokenValid: true, Expiration: now.Add(8 * time.Hour)}
+	otpCalls := 0
+	var roleSessions []string
+	a := New(client,
+		WithStore(store),
+		WithClock(func() time.Time { return now }),
+		WithOTPProvider(func(ctx context.Context) (string, error) { otpCalls++; return "123456", nil }),
+		WithRoleClient(func(session *aws.SessionCredentials) aws.STSAssumeRoleClient {
+			roleSessions = append(roleSessions, session.SessionToken)
+			return client
+		}),
+		WithHooks(Hooks{BeforeRefresh: func(ctx context.Context, req Request) error {
+			if req.Profile == "frozen" {
+				return errors.New("change freeze")
+			}
+			return nil
+		}}),
+	)
+
+	results, err := a.AuthenticateChain(context.Background(), ChainRequest{
+		MFAArn:          "arn:aws:iam::123456789012:mfa/test",
+		DurationSeconds: 28800,
+		Targets: []Request{
+			{Profile: "default"},
+			{Profile: "admin", RoleArn: "arn:aws:iam::210987654321:role/Admin", DurationSeconds: 3600},
+			{Profile: "current"},
+			{Profile: "frozen"},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "1 of 4 profiles failed") {
+		t.Errorf("Expected the frozen profile to be reported, got %v", err)
+	}
+	if otpCalls != 1 || client.SessionTokenCalls != 1 {
+		t.Errorf("Expected one OTP and one GetSessionToken call, got %d and %d", otpCalls, client.SessionTokenCalls)
+	}
+	want := []string{ActionRefreshed, ActionRefreshed, ActionValid, ActionFailed}
+	for i, res := range results {
+		if res.Action != want[i] {
+			t.Errorf("Profile %s: expected %s, got %s (err %v)", res.Profile, want[i], res.Action, res.Err)
+		}
+	}
+	if results[3].Err == nil || !strings.Contains(results[3].Err.Error(), "change freeze") {
+		t.Errorf("Expected the hook error on the frozen profile, got %v", results[3].Err)
+	}
+	if len(roleSessions) != 1 || roleSessions[0] != "newSessionToken" {
+		t.Errorf("Expected the role to be assumed with the MFA session, got %v", roleSessions)
+	}
+	if creds, err := store.Read("admin"); err != nil || creds.SessionToken != "roleSessionToken" {
+		t.Errorf("Expected the role credentials to be stored, got %+v (err %v)", creds, err)
+	}
+
+	// When every session is still valid no OTP is requested.
+	otpCalls = 0
+	if _, err := a.AuthenticateChain(context.Background(), ChainRequest{Targets: []Request{{Profile: "default"}, {Profile: "admin"}}}); err != nil {
+		t.Fatalf("AuthenticateChain returned error: %v", err)
+	}
+	if otpCalls != 0 {
+		t.Errorf("Expected no OTP request, got %d", otpCalls)
+	}
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+)
+
+// RoleClientFunc returns an STS client that signs with session.
+type RoleClientFunc func(session *aws.SessionCredentials) aws.STSAssumeRoleClient
+
+// ChainRequest describes profiles that are all filled from one MFA session, so that
+// a single OTP refreshes them together.
+type ChainRequest struct {
+	// MFAArn is the serial number or ARN of the MFA device.
+	MFAArn string
+	// DurationSeconds is the requested duration of the MFA session.
+	DurationSeconds int32
+	// Targets are the profiles to fill. A target without a RoleArn receives the MFA
+	// session itself; one with a RoleArn receives the role, assumed with the session
+	// for the target's DurationSeconds. The targets' MFAArn is ignored.
+	Targets []Request
+}
+
+// MultiStore is implemented by stores that can write several profiles at once.
+// AuthenticateChain uses it, when available, so that either every refreshed
+// profile is stored or none is.
+type MultiStore interface {
+	WriteAll(updates map[string]*aws.SessionCredentials) error
+}
+
+// AuthenticateChain keeps the stored session of every target that is valid and not
+// due for renewal. For the others it obtains one OTP, requests an MFA session with
+// it, derives each target's credentials from that session and stores them. The
+// hooks are called for each target that is refreshed; a target whose BeforeRefresh
+// fails is skipped.
+//
+// There is one result per target, in order, whose Err is set if the target failed.
+// The returned error is set if any target failed.
+func (a *Authenticator) AuthenticateChain(ctx context.Context, chain ChainRequest) ([]*Result, error) {
+	targets := make([]Request, len(chain.Targets))
+	results := make([]*Result, len(chain.Targets))
+	var pending []int
+	var failed int
+	for i, req := range chain.Targets {
+		req.MFAArn = chain.MFAArn
+		targets[i] = req
+		results[i] = &Result{Profile: req.Profile, Action: ActionFailed}
+		if a.keepStored(ctx, req, results[i]) {
+			continue
+		}
+		if a.hooks.BeforeRefresh != nil {
+			if err := a.hooks.BeforeRefresh(ctx, req); err != nil {
+				results[i].Err = fmt.Errorf("pre-refresh hook failed: %w", err)
+				failed++
+				continue
+			}
+		}
+		if req.RoleArn != "" && a.roles == nil {
+			results[i].Err = errors.New("no role client configured")
+			failed++
+			continue
+		}
+		pending = append(pending, i)
+	}
+	summary := func() error {
+		if failed > 0 {
+			return fmt.Errorf("%d of %d profiles failed to refresh", failed, len(targets))
+		}
+		return nil
+	}
+	if len(pending) == 0 {
+		return results, summary()
+	}
+
+	fail := func(err error) ([]*Result, error) {
+		for _, i := range pending {
+			if results[i].Err == nil {
+				results[i].Action = ActionFailed
+				results[i].Err = err
+			}
+		}
+		return results, err
+	}
+
+	code, err := a.otp(ctx)
+	if err != nil {
+		return fail(fmt.Errorf("failed to obtain OTP: %w", err))
+	}
+	a.addSecrets(code)
+	slog.Debug("Requesting MFA session", "mfa_arn", chain.MFAArn, "duration", chain.DurationSeconds, "targets", len(pending))
+	session, err := aws.GetSessionToken(ctx, a.sts, chain.MFAArn, code, chain.DurationSeconds)
+	if err != nil {
+		return fail(fmt.Errorf("failed to get new session token: %w", err))
+	}
+	a.addSecrets(session.AccessKeyID, session.SecretAccessKey, session.SessionToken)
+
+	updates := map[string]*aws.SessionCredentials{}
+	for _, i := range pending {
+		req := targets[i]
+		creds := session
+		if req.RoleArn != "" {
+			creds, err = aws.AssumeRole(ctx, a.roles(session), req.RoleArn, "", "", req.DurationSeconds)
+			if err != nil {
+				slog.Warn("Failed to assume role", "profile", req.Profile, "role_arn", req.RoleArn, "err", err)
+				results[i].Err = err
+				failed++
+				continue
+			}
+			a.addSecrets(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken)
+		}
+		updates[req.Profile] = creds
+	}
+
+	if len(updates) > 0 {
+		if err := a.writeAll(updates); err != nil {
+			return fail(fmt.Errorf("failed to store credentials: %w", err))
+		}
+	}
+	for _, i := range pending {
+		if results[i].Err != nil {
+			continue
+		}
+		req := targets[i]
+		creds := updates[req.Profile]
+		slog.Info("AWS credentials successfully updated", "profile", req.Profile, "expiration", creds.Expiration)
+		results[i].Action, results[i].Expiration = ActionRefreshed, creds.Expiration
+		if a.hooks.AfterRefresh != nil {
+			a.hooks.AfterRefresh(ctx, req, results[i], creds)
+		}
+	}
+	return results, summary()
+}
+
+// writeAll stores updates in one write if the store supports it, else one profile at a time.
+func (a *Authenticator) writeAll(updates map[string]*aws.SessionCredentials) error {
+	if s, ok := a.store.(MultiStore); ok {
+		return s.WriteAll(updates)
+	}
+	for profile, creds := range updates {
+		if err := a.store.Write(profile, creds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
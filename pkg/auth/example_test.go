@@ -0,0 +1,73 @@
+package auth_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/crbanman/aws-otp-auth/pkg/auth"
+)
+
+// exampleSTS stands in for an *sts.Client created with sts.NewFromConfig.
+type exampleSTS struct{ now func() time.Time }
+
+func (e exampleSTS) GetCallerIdentity(ctx context.Context, input *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Arn: awsSdk.String("arn:aws:iam::123456789012:user/alice")}, nil
+}
+
+func (e exampleSTS) GetSessionToken(ctx context.Context, input *sts.GetSessionTokenInput, optFns ...func(*sts.Options)) (*sts.GetSessionTokenOutput, error) {
+	return &sts.GetSessionTokenOutput{Credentials: &types.Credentials{
+		AccessKeyId:     awsSdk.String("ASIAEXAMPLE"),
+		SecretAccessKey: awsSdk.String("secret"),
+		SessionToken:    awsSdk.String("token"),
+		Expiration:      awsSdk.Time(e.now().Add(time.Duration(*input.DurationSeconds) * time.Second)),
+	}}, nil
+}
+
+func (e exampleSTS) AssumeRole(ctx context.Context, input *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	return nil, fmt.Errorf("not used")
+}
+
+func ExampleAuthenticator() {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	// An in-memory store keeps the credentials out of ~/.aws/credentials; the
+	// default, FileStore, writes them there like the CLI does.
+	store := &auth.MemoryStore{}
+	a := auth.New(exampleSTS{now: clock},
+		auth.WithStore(store),
+		auth.WithOTPProvider(auth.StaticOTP("123456")),
+		auth.WithClock(clock),
+		auth.WithMinRemaining(5*time.Minute),
+	)
+	req := auth.Request{
+		Profile:         "default",
+		MFAArn:          "arn:aws:iam::123456789012:mfa/alice",
+		DurationSeconds: 3600,
+	}
+
+	res, err := a.Authenticate(context.Background(), req)
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	fmt.Println(res.Action, res.Expiration.Format(time.Kitchen))
+
+	// Within the hour the stored session is reused without asking for an OTP.
+	now = now.Add(30 * time.Minute)
+	res, _ = a.Authenticate(context.Background(), req)
+	fmt.Println(res.Action)
+
+	// Five minutes before it expires, it is renewed.
+	now = now.Add(26 * time.Minute)
+	res, _ = a.Authenticate(context.Background(), req)
+	fmt.Println(res.Action, res.Expiration.Format(time.Kitchen))
+	// Output:
+	// refreshed 10:00AM
+	// valid
+	// refreshed 10:56AM
+}
@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+)
+
+// ExpiryPolicy decides whether a stored session is due for renewal.
+type ExpiryPolicy struct {
+	// Now returns the current time; nil means time.Now.
+	Now func() time.Time
+	// MinRemaining renews sessions that expire within this window, so that they
+	// do not run out in the middle of a command.
+	MinRemaining time.Duration
+}
+
+// now returns the current time according to the policy's clock.
+func (p ExpiryPolicy) now() time.Time {
+	if p.Now == nil {
+		return time.Now()
+	}
+	return p.Now()
+}
+
+// NeedsRefresh reports whether creds lack an expiration or expire within MinRemaining.
+func (p ExpiryPolicy) NeedsRefresh(creds *aws.Credentials) bool {
+	return creds.Expiration.IsZero() || creds.ExpiresWithin(p.now(), p.MinRemaining)
+}
@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/crbanman/aws-otp-auth/pkg/otp"
+)
+
+// OTPProvider returns the current one-time code of the MFA device. It is only
+// called when new credentials are needed.
+type OTPProvider func(ctx context.Context) (string, error)
+
+// StaticOTP returns a provider that always returns code.
+func StaticOTP(code string) OTPProvider {
+	return func(ctx context.Context) (string, error) { return code, nil }
+}
+
+// PromptOTP returns a provider that prompts on stderr and reads a line from in,
+// or from stdin if in is nil.
+func PromptOTP(in io.Reader) OTPProvider {
+	return func(ctx context.Context) (string, error) { return otp.GetOTP("", in) }
+}
+
+// TOTP returns a provider that generates codes from a base32 TOTP seed at the time given by now.
+func TOTP(secret string, now func() time.Time) OTPProvider {
+	return func(ctx context.Context) (string, error) { return otp.GenerateTOTP(secret, now()) }
+}
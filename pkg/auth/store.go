@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/crbanman/aws-otp-auth/pkg/aws"
+)
+
+// Store reads and writes the credentials of named profiles.
+type Store interface {
+	// Read returns the profile's credentials, or an error if there are none.
+	Read(profile string) (*aws.Credentials, error)
+	// Write stores session credentials under the profile.
+	Write(profile string, creds *aws.SessionCredentials) error
+}
+
+// FileStore keeps credentials in the shared credentials file, ~/.aws/credentials.
+type FileStore struct{}
+
+func (FileStore) Read(profile string) (*aws.Credentials, error) {
+	return aws.ReadAWSCredentials(profile)
+}
+
+func (FileStore) Write(profile string, creds *aws.SessionCredentials) error {
+	return aws.UpdateCredentials(profile, creds)
+}
+
+// WriteAll updates every profile in one atomic write of the credentials file.
+func (FileStore) WriteAll(updates map[string]*aws.SessionCredentials) error {
+	return aws.UpdateCredentialsProfiles(updates)
+}
+
+// MemoryStore keeps credentials in memory, for callers that hand them to the SDK
+// directly rather than through the credentials file. The zero value is ready to use.
+type MemoryStore struct {
+	mu       sync.Mutex
+	profiles map[string]aws.Credentials
+}
+
+func (s *MemoryStore) Read(profile string) (*aws.Credentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	creds, ok := s.profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("profile %s not found", profile)
+	}
+	return &creds, nil
+}
+
+func (s *MemoryStore) Write(profile string, creds *aws.SessionCredentials) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.profiles == nil {
+		s.profiles = map[string]aws.Credentials{}
+	}
+	s.profiles[profile] = aws.Credentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Expiration:      creds.Expiration,
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// IAMAccessKeysClient defines the subset of the AWS IAM client's methods needed to report on access keys.
+type IAMAccessKeysClient interface {
+	ListAccessKeys(ctx context.Context, params *iam.ListAccessKeysInput, optFns ...func(*iam.Options)) (*iam.ListAccessKeysOutput, error)
+	GetAccessKeyLastUsed(ctx context.Context, params *iam.GetAccessKeyLastUsedInput, optFns ...func(*iam.Options)) (*iam.GetAccessKeyLastUsedOutput, error)
+}
+
+// AccessKeyInfo describes a single access key of an IAM user.
+type AccessKeyInfo struct {
+	AccessKeyID     string     `json:"access_key_id"`
+	Status          string     `json:"status"`
+	CreateDate      time.Time  `json:"create_date"`
+	AgeDays         int        `json:"age_days"`
+	LastUsedDate    *time.Time `json:"last_used_date,omitempty"`
+	LastUsedService string     `json:"last_used_service,omitempty"`
+	LastUsedRegion  string     `json:"last_used_region,omitempty"`
+}
+
+// AccessKeyReport holds the access keys of an IAM user along with any hygiene warnings.
+type AccessKeyReport struct {
+	UserName string          `json:"user_name"`
+	Keys     []AccessKeyInfo `json:"keys"`
+	Warnings []string        `json:"warnings"`
+}
+
+// GetAccessKeyReport lists the access keys of the given IAM user and when each was last used.
+// A warning is added for every active key older than maxAge (if maxAge is positive) and
+// when more than one key is active.
+func GetAccessKeyReport(ctx context.Context, client IAMAccessKeysClient, userName string, maxAge time.Duration, now time.Time) (*AccessKeyReport, error) {
+	report := &AccessKeyReport{UserName: userName, Keys: []AccessKeyInfo{}, Warnings: []string{}}
+
+	paginator := iam.NewListAccessKeysPaginator(client, &iam.ListAccessKeysInput{
+		UserName: aws.String(userName),
+	})
+	var active int
+	for paginator.HasMorePages() {
+		page, err := retry(ctx, client, func(ctx context.Context) (*iam.ListAccessKeysOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list access keys: %w", ClassifyError(err))
+		}
+		for _, key := range page.AccessKeyMetadata {
+			info := AccessKeyInfo{
+				AccessKeyID: aws.ToString(key.AccessKeyId),
+				Status:      string(key.Status),
+				CreateDate:  aws.ToTime(key.CreateDate),
+			}
+			age := now.Sub(info.CreateDate)
+			info.AgeDays = int(age.Hours() / 24)
+
+			lastUsed, err := retry(ctx, client, func(ctx context.Context) (*iam.GetAccessKeyLastUsedOutput, error) {
+				return client.GetAccessKeyLastUsed(ctx, &iam.GetAccessKeyLastUsedInput{
+					AccessKeyId: key.AccessKeyId,
+				})
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get last use of access key %s: %w", info.AccessKeyID, ClassifyError(err))
+			}
+			if lu := lastUsed.AccessKeyLastUsed; lu != nil {
+				info.LastUsedDate = lu.LastUsedDate
+				info.LastUsedService = aws.ToString(lu.ServiceName)
+				info.LastUsedRegion = aws.ToString(lu.Region)
+			}
+
+			if key.Status == types.StatusTypeActive {
+				active++
+				if maxAge > 0 && age > maxAge {
+					report.Warnings = append(report.Warnings, fmt.Sprintf("access key %s is %d days old (maximum %d days)", info.AccessKeyID, info.AgeDays, int(maxAge.Hours()/24)))
+				}
+			}
+			report.Keys = append(report.Keys, info)
+		}
+	}
+
+	if active > 1 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("user %s has %d active access keys", userName, active))
+	}
+	return report, nil
+}
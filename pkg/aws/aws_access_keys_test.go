@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+type mockIAMAccessKeysClient struct {
+	Keys     []types.AccessKeyMetadata
+	LastUsed map[string]*types.AccessKeyLastUsed
+	Err      error
+}
+
+func (m *mockIAMAccessKeysClient) ListAccessKeys(ctx context.Context, input *iam.ListAccessKeysInput, optFns ...func(*iam.Options)) (*iam.ListAccessKeysOutput, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return &iam.ListAccessKeysOutput{AccessKeyMetadata: m.Keys}, nil
+}
+
+func (m *mockIAMAccessKeysClient) GetAccessKeyLastUsed(ctx context.Context, input *iam.GetAccessKeyLastUsedInput, optFns ...func(*iam.Options)) (*iam.GetAccessKeyLastUsedOutput, error) {
+	return &iam.GetAccessKeyLastUsedOutput{AccessKeyLastUsed: m.LastUsed[aws.ToString(input.AccessKeyId)]}, nil
+}
+
+func TestGetAccessKeyReport(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	used := now.Add(-2 * time.Hour)
+	mockClient := &mockIAMAccessKeysClient{
+		Keys: []types.AccessKeyMetadata{
+			{AccessKeyId: aws.String("AKIAOLD"), Status: types.StatusTypeActive, CreateDate: aws.Time(now.AddDate(0, 0, -120))},
+			{AccessKeyId: aws.String("AKIANEW"), Status: types.StatusTypeActive, CreateDate: aws.Time(now.AddDate(0, 0, -10))},
+			{AccessKeyId: aws.String("AKIADISABLED"), Status: types.StatusTypeInactive, CreateDate: aws.Time(now.AddDate(0, 0, -400))},
+		},
+		LastUsed: map[string]*types.AccessKeyLastUsed{
+			"AKIAOLD": {LastUsedDate: aws.Time(used), ServiceName: aws.String("sts"), Region: aws.String("us-east-1")},
+		},
+	}
+
+	report, err := GetAccessKeyReport(context.Background(), mockClient, "alice", 90*24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("GetAccessKeyReport returned error: %v", err)
+	}
+	if len(report.Keys) != 3 {
+		t.Fatalf("Expected 3 keys, got %d", len(report.Keys))
+	}
+	if report.Keys[0].AgeDays != 120 {
+		t.Errorf("Expected age of 120 days, got %d", report.Keys[0].AgeDays)
+	}
+	if report.Keys[0].LastUsedService != "sts" || report.Keys[0].LastUsedRegion != "us-east-1" {
+		t.Errorf("Unexpected last used info: %+v", report.Keys[0])
+	}
+	if report.Keys[1].LastUsedDate != nil {
+		t.Errorf("Expected no last used date for unused key, got %v", report.Keys[1].LastUsedDate)
+	}
+	if len(report.Warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %d: %v", len(report.Warnings), report.Warnings)
+	}
+	if !strings.Contains(report.Warnings[0], "AKIAOLD") {
+		t.Errorf("Expected age warning for AKIAOLD, got %q", report.Warnings[0])
+	}
+	if !strings.Contains(report.Warnings[1], "2 active access keys") {
+		t.Errorf("Expected active key count warning, got %q", report.Warnings[1])
+	}
+}
+
+func TestGetAccessKeyReport_Error(t *testing.T) {
+	mockClient := &mockIAMAccessKeysClient{Err: errors.New("access denied")}
+	_, err := GetAccessKeyReport(context.Background(), mockClient, "alice", 0, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("Expected access denied error, got %v", err)
+	}
+}
@@ -2,9 +2,14 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
 )
 
 // STSClient defines the subset of the AWS STS client's methods used by CheckAuthentication.
@@ -15,9 +20,118 @@ type STSClient interface {
 // CheckAuthentication calls AWS STS's GetCallerIdentity API to validate the current credentials.
 // It returns nil if the credentials are valid or an error if the call fails.
 func CheckAuthentication(ctx context.Context, client STSClient) error {
-	_, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	_, err := retry(ctx, client, func(ctx context.Context) (*sts.GetCallerIdentityOutput, error) {
+		return client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	})
 	if err != nil {
-		return fmt.Errorf("authentication check failed: %w", err)
+		return fmt.Errorf("authentication check failed: %w", ClassifyError(err))
 	}
 	return nil
 }
+
+// SessionState is the outcome of validating stored credentials with STS.
+type SessionState string
+
+const (
+	// SessionValid means STS accepted the credentials.
+	SessionValid SessionState = "valid"
+	// SessionExpired means the credentials have passed their expiration.
+	SessionExpired SessionState = "expired"
+	// SessionRevoked means STS rejected credentials that had not expired.
+	SessionRevoked SessionState = "revoked"
+	// SessionUnreachable means STS could not be reached, or did not answer, to check the credentials.
+	SessionUnreachable SessionState = "unreachable"
+)
+
+// ValidateSession checks the credentials behind client with GetCallerIdentity, giving up
+// after timeout, and classifies the result.
+func ValidateSession(ctx context.Context, client STSClient, timeout time.Duration) (SessionState, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := CheckAuthentication(ctx, client)
+	return ClassifySessionError(err), err
+}
+
+// ClassifySessionError maps the error from CheckAuthentication to a SessionState.
+// Only credential errors mark the session revoked; throttling, network failures and
+// errors that are not recognised leave it unreachable, since they say nothing about
+// the credentials.
+func ClassifySessionError(err error) SessionState {
+	if err == nil {
+		return SessionValid
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ExpiredToken", "ExpiredTokenException", "RequestExpired":
+			return SessionExpired
+		}
+	}
+	err = ClassifyError(err)
+	var credsErr *InvalidCredentialsError
+	var deniedErr *AccessDeniedError
+	if errors.As(err, &credsErr) || errors.As(err, &deniedErr) {
+		return SessionRevoked
+	}
+	return SessionUnreachable
+}
+
+// CallerIdentity identifies the principal behind a set of credentials.
+type CallerIdentity struct {
+	Account string `json:"account"`
+	Arn     string `json:"arn"`
+	UserID  string `json:"user_id"`
+}
+
+// GetCallerIdentity calls AWS STS's GetCallerIdentity API and returns the principal the credentials belong to.
+func GetCallerIdentity(ctx context.Context, client STSClient) (*CallerIdentity, error) {
+	out, err := retry(ctx, client, func(ctx context.Context) (*sts.GetCallerIdentityOutput, error) {
+		return client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %w", ClassifyError(err))
+	}
+	return &CallerIdentity{
+		Account: aws.ToString(out.Account),
+		Arn:     aws.ToString(out.Arn),
+		UserID:  aws.ToString(out.UserId),
+	}, nil
+}
+
+// GetCallerUserName calls AWS STS's GetCallerIdentity API and returns the IAM user name
+// the credentials belong to.
+func GetCallerUserName(ctx context.Context, client STSClient) (string, error) {
+	identity, err := GetCallerIdentity(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	return UserNameFromARN(identity.Arn)
+}
+
+// UserNameFromARN extracts the user name from an IAM user ARN such as
+// arn:aws:iam::123456789012:user/team/alice. Any path before the name is dropped.
+func UserNameFromARN(arn string) (string, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "iam" {
+		return "", fmt.Errorf("%q is not an IAM ARN", arn)
+	}
+	resource := parts[5]
+	if !strings.HasPrefix(resource, "user/") {
+		return "", fmt.Errorf("%q is not an IAM user ARN", arn)
+	}
+	name := resource[strings.LastIndex(resource, "/")+1:]
+	if name == "" {
+		return "", fmt.Errorf("%q has no user name", arn)
+	}
+	return name, nil
+}
+
+// AccountFromARN returns the account ID in an ARN, or "" if arn is not an ARN
+// or has no account (for example a hardware MFA serial number).
+func AccountFromARN(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[4]
+}
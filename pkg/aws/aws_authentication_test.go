@@ -3,11 +3,16 @@ package aws
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 )
 
 type mockSTSClient struct {
@@ -63,3 +68,125 @@ func TestCheckAuthentication_Error(t *testing.T) {
 		t.Fatalf("Expected error message to contain 'network error', got: %v", err)
 	}
 }
+
+func TestGetCallerIdentity(t *testing.T) {
+	identity, err := GetCallerIdentity(context.Background(), &mockSTSClient{Valid: true})
+	if err != nil {
+		t.Fatalf("GetCallerIdentity returned error: %v", err)
+	}
+	if identity.Account != "123456789012" || identity.Arn != "arn:aws:iam::123456789012:user/test" || identity.UserID != "AIDEXAMPLE" {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+}
+
+func TestGetCallerUserName(t *testing.T) {
+	name, err := GetCallerUserName(context.Background(), &mockSTSClient{Valid: true})
+	if err != nil {
+		t.Fatalf("GetCallerUserName returned error: %v", err)
+	}
+	if name != "test" {
+		t.Errorf("Expected user name 'test', got '%s'", name)
+	}
+
+	if _, err := GetCallerUserName(context.Background(), &mockSTSClient{Err: errors.New("network error")}); err == nil {
+		t.Errorf("Expected error when GetCallerIdentity fails, got nil")
+	}
+}
+
+func TestUserNameFromARN(t *testing.T) {
+	tests := []struct {
+		arn     string
+		want    string
+		wantErr bool
+	}{
+		{"arn:aws:iam::123456789012:user/alice", "alice", false},
+		{"arn:aws:iam::123456789012:user/team/alice", "alice", false},
+		{"arn:aws:iam::123456789012:user/division/team/alice.smith@example.com", "alice.smith@example.com", false},
+		{"arn:aws-us-gov:iam::123456789012:user/bob", "bob", false},
+		{"arn:aws-cn:iam::123456789012:user/carol", "carol", false},
+		{"arn:aws:iam::123456789012:root", "", true},
+		{"arn:aws:sts::123456789012:assumed-role/Admin/session", "", true},
+		{"arn:aws:iam::123456789012:user/", "", true},
+		{"not-an-arn", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := UserNameFromARN(tt.arn)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("UserNameFromARN(%q): expected error, got %q", tt.arn, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("UserNameFromARN(%q): unexpected error: %v", tt.arn, err)
+		} else if got != tt.want {
+			t.Errorf("UserNameFromARN(%q): expected %q, got %q", tt.arn, tt.want, got)
+		}
+	}
+}
+
+// slowSTSClient blocks until the context is cancelled.
+type slowSTSClient struct{}
+
+func (slowSTSClient) GetCallerIdentity(ctx context.Context, input *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestValidateSession(t *testing.T) {
+	ctx := context.Background()
+	if state, err := ValidateSession(ctx, &mockSTSClient{Valid: true}, time.Second); state != SessionValid || err != nil {
+		t.Errorf("Expected valid session, got %s (err %v)", state, err)
+	}
+
+	start := time.Now()
+	state, err := ValidateSession(ctx, slowSTSClient{}, 50*time.Millisecond)
+	if state != SessionUnreachable || err == nil {
+		t.Errorf("Expected unreachable after timeout, got %s (err %v)", state, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("ValidateSession did not honour its timeout")
+	}
+}
+
+func TestClassifySessionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want SessionState
+	}{
+		{"nil", nil, SessionValid},
+		{"expired token", &smithy.GenericAPIError{Code: "ExpiredToken"}, SessionExpired},
+		{"invalid token", fmt.Errorf("authentication check failed: %w", &smithy.GenericAPIError{Code: "InvalidClientTokenId"}), SessionRevoked},
+		{"access denied", &smithy.GenericAPIError{Code: "AccessDenied"}, SessionRevoked},
+		{"send error", &smithyhttp.RequestSendError{Err: errors.New("dial tcp: no such host")}, SessionUnreachable},
+		{"net error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, SessionUnreachable},
+		{"deadline", fmt.Errorf("authentication check failed: %w", context.DeadlineExceeded), SessionUnreachable},
+		{"throttling", fmt.Errorf("authentication check failed: %w", &ThrottlingError{Err: &smithy.GenericAPIError{Code: "Throttling"}}), SessionUnreachable},
+		{"unclassified throttling", &smithy.GenericAPIError{Code: "Throttling"}, SessionUnreachable},
+		{"server error", &smithy.GenericAPIError{Code: "InternalFailure"}, SessionUnreachable},
+		{"unknown", errors.New("something odd"), SessionUnreachable},
+	}
+	for _, tt := range tests {
+		if got := ClassifySessionError(tt.err); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestAccountFromARN(t *testing.T) {
+	tests := map[string]string{
+		"arn:aws:iam::123456789012:mfa/alice":           "123456789012",
+		"arn:aws:iam::210987654321:role/Admin":          "210987654321",
+		"arn:aws-us-gov:iam::123456789012:user/bob":     "123456789012",
+		"arn:aws:iam::123456789012:u2f/user/alice/key1": "123456789012",
+		"GAHT12345678": "",
+		"":             "",
+	}
+	for arn, want := range tests {
+		if got := AccountFromARN(arn); got != want {
+			t.Errorf("AccountFromARN(%q): expected %q, got %q", arn, want, got)
+		}
+	}
+}
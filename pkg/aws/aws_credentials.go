@@ -17,6 +17,11 @@ type Credentials struct {
 	Expiration      time.Time
 }
 
+// ExpiresWithin reports whether the credentials have an expiration that is less than d after now.
+func (c *Credentials) ExpiresWithin(now time.Time, d time.Duration) bool {
+	return !c.Expiration.IsZero() && !now.Add(d).Before(c.Expiration)
+}
+
 // ReadAWSCredentials reads the AWS credentials file from ~/.aws/credentials
 // and returns the credentials for the specified profile.
 func ReadAWSCredentials(profile string) (*Credentials, error) {
@@ -58,36 +63,66 @@ func readAWSCredentialsFromFile(filePath, profile string) (*Credentials, error)
 	return cred, nil
 }
 
-// CleanExpiredTokenFromCredentials checks the specified profile in the credentials file.
-// If a session token and its expiration exist and the token is expired, they are removed.
-func CleanExpiredTokenFromCredentials(profile string) error {
+// Credential profile types reported by ListAWSCredentials.
+const (
+	ProfileTypeLongTerm   = "long-term"
+	ProfileTypeSession    = "session"
+	ProfileTypeIncomplete = "incomplete"
+)
+
+// ProfileCredentials holds the credentials of one named profile.
+type ProfileCredentials struct {
+	Profile string
+	Type    string
+	// Managed reports whether the profile's session was written by this tool.
+	Managed bool
+	Credentials
+}
+
+// ListAWSCredentials returns every profile in ~/.aws/credentials, in file order.
+// Unlike ReadAWSCredentials, profiles with incomplete credentials are included.
+func ListAWSCredentials() ([]ProfileCredentials, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
-		return fmt.Errorf("unable to determine home directory: %w", err)
-	}
-	credsPath := filepath.Join(home, ".aws", "credentials")
-	cfg, err := ini.Load(credsPath)
-	if err != nil {
-		return fmt.Errorf("failed to load credentials file: %w", err)
+		return nil, fmt.Errorf("unable to determine user home directory: %w", err)
 	}
-	section, err := cfg.GetSection(profile)
+	return listAWSCredentialsFromFile(filepath.Join(home, ".aws", "credentials"))
+}
+
+// listAWSCredentialsFromFile reads every profile from the given file path.
+func listAWSCredentialsFromFile(filePath string) ([]ProfileCredentials, error) {
+	cfg, err := ini.Load(filePath)
 	if err != nil {
-		// Profile not found, nothing to clean.
-		return nil
+		return nil, fmt.Errorf("failed to load credentials file: %w", err)
 	}
-
-	token := section.Key("aws_session_token").String()
-	expStr := section.Key("aws_session_token_expiration").String()
-	if token != "" && expStr != "" {
-		expTime, err := time.Parse(time.RFC3339, expStr)
-		if err == nil && time.Now().After(expTime) {
-			// Remove expired session token and expiration keys.
-			section.DeleteKey("aws_session_token")
-			section.DeleteKey("aws_session_token_expiration")
-			if err := cfg.SaveTo(credsPath); err != nil {
-				return fmt.Errorf("failed to save cleaned credentials: %w", err)
+	var profiles []ProfileCredentials
+	for _, section := range cfg.Sections() {
+		if section.Name() == ini.DefaultSection && len(section.Keys()) == 0 {
+			continue
+		}
+		p := ProfileCredentials{
+			Profile: section.Name(),
+			Credentials: Credentials{
+				AccessKeyID:     section.Key("aws_access_key_id").String(),
+				SecretAccessKey: section.Key("aws_secret_access_key").String(),
+				SessionToken:    section.Key("aws_session_token").String(),
+			},
+		}
+		if expStr := section.Key("aws_session_token_expiration").String(); expStr != "" {
+			if expTime, err := time.Parse(time.RFC3339, expStr); err == nil {
+				p.Expiration = expTime
 			}
 		}
+		switch {
+		case p.AccessKeyID == "" || p.SecretAccessKey == "":
+			p.Type = ProfileTypeIncomplete
+		case p.SessionToken != "":
+			p.Type = ProfileTypeSession
+			p.Managed = isManagedSession(section)
+		default:
+			p.Type = ProfileTypeLongTerm
+		}
+		profiles = append(profiles, p)
 	}
-	return nil
+	return profiles, nil
 }
@@ -0,0 +1,158 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/ini.v1"
+)
+
+// sessionMarkerKey marks a session written by this tool. It holds the session's access key
+// ID, so a session another tool wrote over it later no longer matches.
+const sessionMarkerKey = "aws_otp_auth_session"
+
+// sessionKeys are the keys of a profile that hold its session credentials.
+var sessionKeys = []string{"aws_access_key_id", "aws_secret_access_key", "aws_session_token", "aws_session_token_expiration", sessionMarkerKey}
+
+// ClearedSession describes a profile whose session was removed by ClearSessions.
+type ClearedSession struct {
+	Profile string
+	// Restored reports whether the profile's contents from before the session were put back.
+	Restored bool
+}
+
+// ClearSessions removes the session credentials from every profile in profiles that holds
+// a session, leaving its other keys in place. With restore, a profile whose contents were
+// saved when its first session was written gets those contents back instead. The file is
+// locked and replaced atomically, as by UpdateCredentialsProfiles. Profiles without a
+// session are skipped; the cleared ones are returned in the order given.
+func ClearSessions(profiles []string, restore bool) ([]ClearedSession, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine user home directory: %w", err)
+	}
+	credsPath := filepath.Join(home, ".aws", "credentials")
+	backupPath := filepath.Join(home, ".aws", "credentials.bak")
+
+	unlock, err := lockCredentialsFile(credsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, err := ini.Load(credsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credentials file: %w", err)
+	}
+	saved, err := loadPreSessionFile(credsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var cleared []ClearedSession
+	for _, profile := range profiles {
+		section, err := cfg.GetSection(profile)
+		if err != nil || !hasSession(section) {
+			continue
+		}
+		c := ClearedSession{Profile: profile}
+		if before, err := saved.GetSection(profile); err == nil && restore {
+			for _, key := range section.KeyStrings() {
+				section.DeleteKey(key)
+			}
+			for _, key := range before.Keys() {
+				section.Key(key.Name()).SetValue(key.Value())
+			}
+			if len(section.Keys()) == 0 {
+				cfg.DeleteSection(profile)
+			}
+			c.Restored = true
+		} else {
+			for _, key := range sessionKeys {
+				section.DeleteKey(key)
+			}
+		}
+		// The saved contents are only kept for the session they preceded.
+		saved.DeleteSection(profile)
+		cleared = append(cleared, c)
+	}
+	if len(cleared) == 0 {
+		return nil, nil
+	}
+
+	if err := copyFile(credsPath, backupPath); err != nil {
+		return nil, fmt.Errorf("failed to backup credentials file: %w", err)
+	}
+	if err := saveCredentialsFile(cfg, credsPath); err != nil {
+		return nil, fmt.Errorf("failed to save cleared credentials file: %w", err)
+	}
+	if err := saveCredentialsFile(saved, preSessionPath(credsPath)); err != nil {
+		return cleared, fmt.Errorf("failed to save pre-session credentials: %w", err)
+	}
+	return cleared, nil
+}
+
+// hasSession reports whether section holds a session token. Unlike Key, it does not add
+// the key to a section that lacks it.
+func hasSession(section *ini.Section) bool {
+	return section.HasKey("aws_session_token") && section.Key("aws_session_token").String() != ""
+}
+
+// isManagedSession reports whether section holds a session written by this tool.
+func isManagedSession(section *ini.Section) bool {
+	return hasSession(section) && section.HasKey(sessionMarkerKey) &&
+		section.Key(sessionMarkerKey).String() == section.Key("aws_access_key_id").String()
+}
+
+// preSessionPath returns the file that holds the contents profiles had before their session.
+func preSessionPath(credsPath string) string {
+	return credsPath + ".presession"
+}
+
+// loadPreSessionFile loads the saved pre-session contents, or an empty file if there are none.
+func loadPreSessionFile(credsPath string) (*ini.File, error) {
+	saved, err := ini.Load(preSessionPath(credsPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return ini.Empty(), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load pre-session credentials: %w", err)
+	}
+	return saved, nil
+}
+
+// savePreSessionProfiles saves the current contents of each profile in updates that does
+// not hold a session, including profiles that do not exist yet, so that they can be
+// restored once the session is cleared. It is called with the credentials file locked.
+func savePreSessionProfiles(cfg *ini.File, credsPath string, updates map[string]*SessionCredentials) error {
+	saved, err := loadPreSessionFile(credsPath)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for profile := range updates {
+		section, err := cfg.GetSection(profile)
+		if err == nil && hasSession(section) {
+			continue
+		}
+		saved.DeleteSection(profile)
+		before, err := saved.NewSection(profile)
+		if err != nil {
+			return fmt.Errorf("failed to save pre-session credentials: %w", err)
+		}
+		if section != nil {
+			for _, key := range section.Keys() {
+				before.Key(key.Name()).SetValue(key.Value())
+			}
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	if err := saveCredentialsFile(saved, preSessionPath(credsPath)); err != nil {
+		return fmt.Errorf("failed to save pre-session credentials: %w", err)
+	}
+	return nil
+}
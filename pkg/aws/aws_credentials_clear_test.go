@@ -0,0 +1,88 @@
+package aws
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestClearSessions(t *testing.T) {
+	tempDir := t.TempDir()
+	awsDir := filepath.Join(tempDir, ".aws")
+	if err := os.MkdirAll(awsDir, 0700); err != nil {
+		t.Fatalf("Failed to create .aws directory: %v", err)
+	}
+	credsPath := filepath.Join(awsDir, "credentials")
+	initialContent := `[long-term]
+aws_access_key_id = LONGKEY
+aws_secret_access_key = LONGSECRET
+
+[dev]
+region = eu-west-1
+aws_access_key_id = DEVLONGKEY
+aws_secret_access_key = DEVLONGSECRET
+`
+	if err := os.WriteFile(credsPath, []byte(initialContent), 0600); err != nil {
+		t.Fatalf("Failed to write test credentials file: %v", err)
+	}
+	t.Setenv("HOME", tempDir)
+
+	session := &SessionCredentials{AccessKeyID: "ASIAKEY", SecretAccessKey: "SESSIONSECRET", SessionToken: "TOKEN", Expiration: time.Now().Add(time.Hour)}
+	if err := UpdateCredentialsProfiles(map[string]*SessionCredentials{"dev": session, "new": session, "prod": session}); err != nil {
+		t.Fatalf("UpdateCredentialsProfiles returned error: %v", err)
+	}
+	// A second session does not replace the saved contents.
+	if err := UpdateCredentials("dev", session); err != nil {
+		t.Fatalf("UpdateCredentials returned error: %v", err)
+	}
+
+	cleared, err := ClearSessions([]string{"long-term", "dev", "new", "prod", "missing"}, true)
+	if err != nil {
+		t.Fatalf("ClearSessions returned error: %v", err)
+	}
+	want := []ClearedSession{{"dev", true}, {"new", true}, {"prod", true}}
+	if !slices.Equal(cleared, want) {
+		t.Errorf("Expected cleared sessions %+v, got %+v", want, cleared)
+	}
+
+	cfg, err := ini.Load(credsPath)
+	if err != nil {
+		t.Fatalf("Failed to load cleared credentials file: %v", err)
+	}
+	dev := cfg.Section("dev")
+	if dev.Key("aws_access_key_id").String() != "DEVLONGKEY" || dev.Key("region").String() != "eu-west-1" || dev.HasKey("aws_session_token") {
+		t.Errorf("Expected dev to be restored, got %v", dev.KeysHash())
+	}
+	if _, err := cfg.GetSection("new"); err == nil {
+		t.Errorf("Expected the profile created by the session to be removed")
+	}
+	if cfg.Section("long-term").Key("aws_access_key_id").String() != "LONGKEY" {
+		t.Errorf("Long-term profile was modified")
+	}
+
+	// Without saved contents, only the session keys are removed.
+	if err := UpdateCredentials("dev", session); err != nil {
+		t.Fatalf("UpdateCredentials returned error: %v", err)
+	}
+	cfg, _ = ini.Load(credsPath)
+	cfg.Section("dev").Key("output").SetValue("json")
+	if err := cfg.SaveTo(credsPath); err != nil {
+		t.Fatalf("Failed to save credentials file: %v", err)
+	}
+	cleared, err = ClearSessions([]string{"dev"}, false)
+	if err != nil || !slices.Equal(cleared, []ClearedSession{{"dev", false}}) {
+		t.Fatalf("Expected dev to be cleared, got %+v (err %v)", cleared, err)
+	}
+	cfg, _ = ini.Load(credsPath)
+	if got := cfg.Section("dev").KeyStrings(); !slices.Equal(got, []string{"region", "output"}) {
+		t.Errorf("Expected only the session keys to be removed, got %v", got)
+	}
+	if cleared, err := ClearSessions([]string{"dev"}, true); err != nil || cleared != nil {
+		t.Errorf("Expected nothing to clear, got %+v (err %v)", cleared, err)
+	}
+	assertUnlocked(t, credsPath)
+}
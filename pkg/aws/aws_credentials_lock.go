@@ -0,0 +1,43 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// lockTimeout is how long to wait for another process to release the credentials lock.
+const lockTimeout = 10 * time.Second
+
+// lockCredentialsFile takes an exclusive lock on the credentials file by flocking a
+// sibling ".lock" file. The returned function releases the lock. The lock file is left
+// in place: the kernel drops the lock when its holder exits, so a crashed process
+// cannot leave it held, and removing the file could let two writers lock different files.
+func lockCredentialsFile(credsPath string) (func(), error) {
+	lockPath := credsPath + ".lock"
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock credentials file: %w", err)
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return func() {
+				syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+				f.Close()
+			}, nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock credentials file: %w", err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out waiting for credentials file lock %s", lockPath)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// assertUnlocked fails the test if the credentials lock at credsPath is still held.
+func assertUnlocked(t *testing.T, credsPath string) {
+	t.Helper()
+	f, err := os.OpenFile(credsPath+".lock", os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		t.Fatalf("Failed to open lock file: %v", err)
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		t.Errorf("Lock was not released: %v", err)
+	}
+}
+
+func TestLockCredentialsFile(t *testing.T) {
+	credsPath := filepath.Join(t.TempDir(), "credentials")
+
+	unlock, err := lockCredentialsFile(credsPath)
+	if err != nil {
+		t.Fatalf("lockCredentialsFile returned error: %v", err)
+	}
+
+	// A second lock must wait until the first is released.
+	acquired := make(chan struct{})
+	go func() {
+		unlock2, err := lockCredentialsFile(credsPath)
+		if err == nil {
+			unlock2()
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatalf("Second lock acquired while the first was held")
+	case <-time.After(200 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Second lock not acquired after release")
+	}
+	assertUnlocked(t, credsPath)
+}
+
+func TestLockCredentialsFile_Leftover(t *testing.T) {
+	// A lock file left behind by a process that exited does not hold the lock.
+	credsPath := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(credsPath+".lock", []byte("1\n"), 0600); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	unlock, err := lockCredentialsFile(credsPath)
+	if err != nil {
+		t.Fatalf("Expected the leftover lock file to be locked, got %v", err)
+	}
+	unlock()
+
+	// After a release, the next writer takes the lock at once.
+	start := time.Now()
+	unlock, err = lockCredentialsFile(credsPath)
+	if err != nil {
+		t.Fatalf("lockCredentialsFile returned error: %v", err)
+	}
+	unlock()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the released lock to be taken at once, took %s", elapsed)
+	}
+}
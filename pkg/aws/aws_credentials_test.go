@@ -5,8 +5,6 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
-
-	"gopkg.in/ini.v1"
 )
 
 func TestReadAWSCredentialsFromFile(t *testing.T) {
@@ -81,50 +79,83 @@ aws_access_key_id = SOMEKEY
 	}
 }
 
-func TestCleanExpiredToken(t *testing.T) {
-	// Create a temporary HOME directory.
-	tempDir := t.TempDir()
-	awsDir := filepath.Join(tempDir, ".aws")
-	if err := os.MkdirAll(awsDir, 0755); err != nil {
-		t.Fatalf("Failed to create .aws directory: %v", err)
-	}
-	credsPath := filepath.Join(awsDir, "credentials")
+func TestListAWSCredentialsFromFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "credentials")
+	content := `[long-term]
+aws_access_key_id = LONGKEY
+aws_secret_access_key = LONGSECRET
 
-	// Write a credentials file with an expired token.
-	expiredTime := time.Now().Add(-1 * time.Hour).Format(time.RFC3339)
-	content := `[default]
-aws_access_key_id = ABCD
-aws_secret_access_key = SECRET
-aws_session_token = EXPIREDTOKEN
-aws_session_token_expiration = ` + expiredTime + `
-`
-	if err := os.WriteFile(credsPath, []byte(content), 0644); err != nil {
-		t.Fatalf("Failed to write credentials file: %v", err)
-	}
+[dev]
+aws_access_key_id = SESSIONKEY
+aws_secret_access_key = SESSIONSECRET
+aws_session_token = SESSIONTOKEN
+aws_session_token_expiration = 2025-02-24T15:04:05Z
 
-	// Override HOME so os.UserHomeDir() returns tempDir.
-	origHome := os.Getenv("HOME")
-	defer os.Setenv("HOME", origHome)
-	os.Setenv("HOME", tempDir)
+[broken]
+aws_access_key_id = ONLYKEY
 
-	// Call the cleaning function.
-	if err := CleanExpiredTokenFromCredentials("default"); err != nil {
-		t.Fatalf("CleanExpiredTokenFromCredentials failed: %v", err)
+[ours]
+aws_access_key_id = OURKEY
+aws_secret_access_key = OURSECRET
+aws_session_token = OURTOKEN
+aws_otp_auth_session = OURKEY
+
+[overwritten]
+aws_access_key_id = SSOKEY
+aws_secret_access_key = SSOSECRET
+aws_session_token = SSOTOKEN
+aws_otp_auth_session = OURKEY
+`
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write temp credentials file: %v", err)
 	}
 
-	// Reload the credentials file and verify the keys are removed.
-	cfg, err := ini.Load(credsPath)
+	profiles, err := listAWSCredentialsFromFile(filePath)
 	if err != nil {
-		t.Fatalf("Failed to load cleaned credentials file: %v", err)
+		t.Fatalf("listAWSCredentialsFromFile returned error: %v", err)
 	}
-	section, err := cfg.GetSection("default")
-	if err != nil {
-		t.Fatalf("Default section not found")
+	if len(profiles) != 5 {
+		t.Fatalf("Expected 5 profiles, got %d", len(profiles))
+	}
+	wantTypes := map[string]string{"long-term": ProfileTypeLongTerm, "dev": ProfileTypeSession, "broken": ProfileTypeIncomplete, "ours": ProfileTypeSession, "overwritten": ProfileTypeSession}
+	for _, p := range profiles {
+		if p.Type != wantTypes[p.Profile] {
+			t.Errorf("Profile %s: expected type %s, got %s", p.Profile, wantTypes[p.Profile], p.Type)
+		}
+		// Only a session whose marker matches its access key was written by this tool.
+		if p.Managed != (p.Profile == "ours") {
+			t.Errorf("Profile %s: expected managed %v, got %v", p.Profile, p.Profile == "ours", p.Managed)
+		}
 	}
-	if section.HasKey("aws_session_token") {
-		t.Errorf("aws_session_token was not removed")
+	if profiles[0].Profile != "long-term" {
+		t.Errorf("Expected file order to be kept, got %s first", profiles[0].Profile)
 	}
-	if section.HasKey("aws_session_token_expiration") {
-		t.Errorf("aws_session_token_expiration was not removed")
+	if want := time.Date(2025, 2, 24, 15, 4, 5, 0, time.UTC); !profiles[1].Expiration.Equal(want) {
+		t.Errorf("Expected expiration %v, got %v", want, profiles[1].Expiration)
+	}
+}
+
+func TestCredentialsExpiresWithin(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	creds := &Credentials{Expiration: now.Add(5 * time.Minute)}
+	tests := []struct {
+		window time.Duration
+		want   bool
+	}{
+		{0, false},
+		{5*time.Minute - time.Second, false},
+		{5 * time.Minute, true},
+		{10 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		if got := creds.ExpiresWithin(now, tt.window); got != tt.want {
+			t.Errorf("ExpiresWithin(%v) = %v, expected %v", tt.window, got, tt.want)
+		}
+	}
+	if (&Credentials{}).ExpiresWithin(now, time.Hour) {
+		t.Errorf("Expected credentials without an expiration not to expire")
+	}
+	if !creds.ExpiresWithin(now.Add(6*time.Minute), 0) {
+		t.Errorf("Expected expired credentials to expire within any window")
 	}
 }
@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -13,6 +14,14 @@ import (
 // UpdateCredentials backs up the current credentials file and updates the specified profile
 // with the new session credentials.
 func UpdateCredentials(profile string, newCreds *SessionCredentials) error {
+	return UpdateCredentialsProfiles(map[string]*SessionCredentials{profile: newCreds})
+}
+
+// UpdateCredentialsProfiles backs up the current credentials file and updates every profile in
+// updates with its new session credentials. The file is locked for the whole update and
+// replaced atomically, so either all profiles are written or none are. The contents of a
+// profile that does not yet hold a session are saved so that ClearSessions can restore them.
+func UpdateCredentialsProfiles(updates map[string]*SessionCredentials) error {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return fmt.Errorf("unable to determine user home directory: %w", err)
@@ -20,6 +29,12 @@ func UpdateCredentials(profile string, newCreds *SessionCredentials) error {
 	credsPath := filepath.Join(home, ".aws", "credentials")
 	backupPath := filepath.Join(home, ".aws", "credentials.bak")
 
+	unlock, err := lockCredentialsFile(credsPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	if err := copyFile(credsPath, backupPath); err != nil {
 		return fmt.Errorf("failed to backup credentials file: %w", err)
 	}
@@ -29,26 +44,69 @@ func UpdateCredentials(profile string, newCreds *SessionCredentials) error {
 		return fmt.Errorf("failed to load credentials file: %w", err)
 	}
 
-	section, err := cfg.GetSection(profile)
-	if err != nil {
-		section, err = cfg.NewSection(profile)
+	if err := savePreSessionProfiles(cfg, credsPath, updates); err != nil {
+		return err
+	}
+
+	for profile, newCreds := range updates {
+		section, err := cfg.GetSection(profile)
 		if err != nil {
-			return fmt.Errorf("failed to create profile section: %w", err)
+			section, err = cfg.NewSection(profile)
+			if err != nil {
+				return fmt.Errorf("failed to create profile section: %w", err)
+			}
 		}
-	}
 
-	section.Key("aws_access_key_id").SetValue(newCreds.AccessKeyID)
-	section.Key("aws_secret_access_key").SetValue(newCreds.SecretAccessKey)
-	section.Key("aws_session_token").SetValue(newCreds.SessionToken)
-	section.Key("aws_session_token_expiration").SetValue(newCreds.Expiration.Format(time.RFC3339))
+		section.Key("aws_access_key_id").SetValue(newCreds.AccessKeyID)
+		section.Key("aws_secret_access_key").SetValue(newCreds.SecretAccessKey)
+		section.Key("aws_session_token").SetValue(newCreds.SessionToken)
+		section.Key("aws_session_token_expiration").SetValue(newCreds.Expiration.Format(time.RFC3339))
+		section.Key(sessionMarkerKey).SetValue(newCreds.AccessKeyID)
+	}
 
-	if err := cfg.SaveTo(credsPath); err != nil {
+	if err := saveCredentialsFile(cfg, credsPath); err != nil {
 		return fmt.Errorf("failed to save updated credentials file: %w", err)
 	}
 
 	return nil
 }
 
+// saveCredentialsFile writes cfg to a temporary file next to path and renames it into
+// place, so readers never observe a partially written credentials file. If path is a
+// symlink, as dotfile managers leave it, the file it points to is replaced instead.
+func saveCredentialsFile(cfg *ini.File, path string) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".credentials-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := cfg.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
 // copyFile copies a file from src to dst.
 func copyFile(src, dst string) error {
 	in, err := os.Open(src)
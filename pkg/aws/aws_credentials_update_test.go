@@ -92,4 +92,90 @@ aws_session_token = PROFILE1OLDTOKEN
 			t.Errorf("Expiration not updated correctly. Expected %v, got %v", newCreds.Expiration, parsedExp)
 		}
 	}
+	if !isManagedSession(section) {
+		t.Errorf("Expected the session to be marked as written by this tool, got %v", section.KeysHash())
+	}
+}
+
+func TestUpdateCredentialsProfiles(t *testing.T) {
+	tempDir := t.TempDir()
+	awsDir := filepath.Join(tempDir, ".aws")
+	if err := os.MkdirAll(awsDir, 0755); err != nil {
+		t.Fatalf("Failed to create .aws directory: %v", err)
+	}
+	credsPath := filepath.Join(awsDir, "credentials")
+	initialContent := `[long-term]
+aws_access_key_id = LONGKEY
+aws_secret_access_key = LONGSECRET
+`
+	if err := os.WriteFile(credsPath, []byte(initialContent), 0600); err != nil {
+		t.Fatalf("Failed to write test credentials file: %v", err)
+	}
+	t.Setenv("HOME", tempDir)
+
+	expiration := time.Now().Add(1 * time.Hour).Truncate(time.Second)
+	updates := map[string]*SessionCredentials{
+		"dev":  {AccessKeyID: "DEVKEY", SecretAccessKey: "DEVSECRET", SessionToken: "DEVTOKEN", Expiration: expiration},
+		"prod": {AccessKeyID: "PRODKEY", SecretAccessKey: "PRODSECRET", SessionToken: "PRODTOKEN", Expiration: expiration},
+	}
+	if err := UpdateCredentialsProfiles(updates); err != nil {
+		t.Fatalf("UpdateCredentialsProfiles returned error: %v", err)
+	}
+
+	cfg, err := ini.Load(credsPath)
+	if err != nil {
+		t.Fatalf("Failed to load updated credentials file: %v", err)
+	}
+	for profile, creds := range updates {
+		if got := cfg.Section(profile).Key("aws_session_token").String(); got != creds.SessionToken {
+			t.Errorf("Profile %s: expected session token %s, got %s", profile, creds.SessionToken, got)
+		}
+	}
+	if got := cfg.Section("long-term").Key("aws_access_key_id").String(); got != "LONGKEY" {
+		t.Errorf("Long-term profile was modified: %s", got)
+	}
+
+	info, err := os.Stat(credsPath)
+	if err != nil {
+		t.Fatalf("Failed to stat credentials file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected file mode 0600 to be preserved, got %v", info.Mode().Perm())
+	}
+	assertUnlocked(t, credsPath)
+}
+
+func TestUpdateCredentials_Symlink(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	awsDir := filepath.Join(tempDir, ".aws")
+	dotfiles := filepath.Join(tempDir, "dotfiles")
+	for _, dir := range []string{awsDir, dotfiles} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	target := filepath.Join(dotfiles, "credentials")
+	if err := os.WriteFile(target, []byte("[long-term]\naws_access_key_id = LONGKEY\n"), 0600); err != nil {
+		t.Fatalf("Failed to write credentials file: %v", err)
+	}
+	credsPath := filepath.Join(awsDir, "credentials")
+	if err := os.Symlink(target, credsPath); err != nil {
+		t.Fatalf("Failed to link credentials file: %v", err)
+	}
+
+	creds := &SessionCredentials{AccessKeyID: "DEVKEY", SecretAccessKey: "DEVSECRET", SessionToken: "DEVTOKEN", Expiration: time.Now().Add(time.Hour)}
+	if err := UpdateCredentials("dev", creds); err != nil {
+		t.Fatalf("UpdateCredentials returned error: %v", err)
+	}
+	if info, err := os.Lstat(credsPath); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("Expected the credentials file to stay a symlink (err %v)", err)
+	}
+	cfg, err := ini.Load(target)
+	if err != nil {
+		t.Fatalf("Failed to load credentials file: %v", err)
+	}
+	if got := cfg.Section("dev").Key("aws_session_token").String(); got != "DEVTOKEN" {
+		t.Errorf("Expected the linked file to be updated, got token %q", got)
+	}
 }
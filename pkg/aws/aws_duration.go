@@ -0,0 +1,55 @@
+package aws
+
+import "fmt"
+
+// Session duration limits of STS, in seconds.
+const (
+	MinSessionDuration      = 900
+	MaxSessionTokenDuration = 129600
+	MaxRoleDuration         = 43200
+	MaxChainedRoleDuration  = 3600
+)
+
+// DurationKind identifies the STS operation a session duration is requested for.
+type DurationKind int
+
+const (
+	// SessionTokenDuration is a GetSessionToken call.
+	SessionTokenDuration DurationKind = iota
+	// RoleDuration is an AssumeRole call with long-term credentials. STS also
+	// enforces the role's own maximum session duration, which defaults to one hour.
+	RoleDuration
+	// ChainedRoleDuration is an AssumeRole call with session credentials, which
+	// STS limits to one hour whatever the role allows.
+	ChainedRoleDuration
+)
+
+// Max returns the longest duration STS accepts for the operation, in seconds.
+func (k DurationKind) Max() int32 {
+	switch k {
+	case RoleDuration:
+		return MaxRoleDuration
+	case ChainedRoleDuration:
+		return MaxChainedRoleDuration
+	}
+	return MaxSessionTokenDuration
+}
+
+func (k DurationKind) String() string {
+	switch k {
+	case RoleDuration:
+		return "AssumeRole"
+	case ChainedRoleDuration:
+		return "chained AssumeRole"
+	}
+	return "GetSessionToken"
+}
+
+// CheckDuration returns an InvalidDurationError if seconds is outside the range STS
+// accepts for the operation, so the request is not sent only to be rejected.
+func CheckDuration(kind DurationKind, seconds int32) error {
+	if seconds < MinSessionDuration || seconds > kind.Max() {
+		return &InvalidDurationError{Err: fmt.Errorf("%s duration %d is outside %d-%d seconds", kind, seconds, MinSessionDuration, kind.Max())}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckDuration(t *testing.T) {
+	tests := []struct {
+		kind    DurationKind
+		seconds int32
+		valid   bool
+	}{
+		{SessionTokenDuration, 899, false},
+		{SessionTokenDuration, 900, true},
+		{SessionTokenDuration, 129600, true},
+		{SessionTokenDuration, 129601, false},
+		{RoleDuration, 43200, true},
+		{RoleDuration, 43201, false},
+		{ChainedRoleDuration, 3600, true},
+		{ChainedRoleDuration, 3601, false},
+	}
+	for _, tt := range tests {
+		err := CheckDuration(tt.kind, tt.seconds)
+		if (err == nil) != tt.valid {
+			t.Errorf("CheckDuration(%s, %d) = %v, expected valid %v", tt.kind, tt.seconds, err, tt.valid)
+		}
+		var durationErr *InvalidDurationError
+		if err != nil && !errors.As(err, &durationErr) {
+			t.Errorf("Expected InvalidDurationError, got %T", err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// The error types below classify STS and IAM failures so callers can use errors.As.
+// Each wraps the original error, which remains available through errors.Unwrap.
+
+// AccessDeniedError is returned when the caller is not allowed to perform the action.
+type AccessDeniedError struct{ Err error }
+
+// InvalidMFACodeError is returned when AWS rejects the one-time code.
+type InvalidMFACodeError struct{ Err error }
+
+// MFANotEnabledError is returned when the MFA device is not assigned to the user.
+type MFANotEnabledError struct{ Err error }
+
+// InvalidCredentialsError is returned when the signing access key is invalid, inactive, deleted or expired.
+type InvalidCredentialsError struct{ Err error }
+
+// InvalidDurationError is returned when the requested session duration is not allowed.
+type InvalidDurationError struct{ Err error }
+
+// ThrottlingError is returned when AWS rate-limits the request.
+type ThrottlingError struct{ Err error }
+
+// NetworkError is returned when AWS could not be reached.
+type NetworkError struct{ Err error }
+
+func (e *AccessDeniedError) Error() string       { return e.Err.Error() }
+func (e *AccessDeniedError) Unwrap() error       { return e.Err }
+func (e *InvalidMFACodeError) Error() string     { return e.Err.Error() }
+func (e *InvalidMFACodeError) Unwrap() error     { return e.Err }
+func (e *MFANotEnabledError) Error() string      { return e.Err.Error() }
+func (e *MFANotEnabledError) Unwrap() error      { return e.Err }
+func (e *InvalidCredentialsError) Error() string { return e.Err.Error() }
+func (e *InvalidCredentialsError) Unwrap() error { return e.Err }
+func (e *InvalidDurationError) Error() string    { return e.Err.Error() }
+func (e *InvalidDurationError) Unwrap() error    { return e.Err }
+func (e *ThrottlingError) Error() string         { return e.Err.Error() }
+func (e *ThrottlingError) Unwrap() error         { return e.Err }
+func (e *NetworkError) Error() string            { return e.Err.Error() }
+func (e *NetworkError) Unwrap() error            { return e.Err }
+
+// ClassifyError wraps err in the error type matching the failure. Errors that do not
+// match a known failure are returned unchanged.
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		msg := strings.ToLower(apiErr.ErrorMessage())
+		switch apiErr.ErrorCode() {
+		case "AccessDenied", "AccessDeniedException":
+			switch {
+			case strings.Contains(msg, "invalid mfa one time pass code"):
+				return &InvalidMFACodeError{Err: err}
+			case strings.Contains(msg, "mfa serial number"), strings.Contains(msg, "unable to validate mfa code"):
+				return &MFANotEnabledError{Err: err}
+			}
+			return &AccessDeniedError{Err: err}
+		case "InvalidClientTokenId", "SignatureDoesNotMatch", "ExpiredToken", "ExpiredTokenException", "UnrecognizedClientException":
+			return &InvalidCredentialsError{Err: err}
+		case "ValidationError", "InvalidParameterValue":
+			if strings.Contains(msg, "duration") {
+				return &InvalidDurationError{Err: err}
+			}
+		case "Throttling", "ThrottlingException", "RequestLimitExceeded", "TooManyRequestsException":
+			return &ThrottlingError{Err: err}
+		}
+		return err
+	}
+	var sendErr *smithyhttp.RequestSendError
+	var netErr net.Error
+	if errors.As(err, &sendErr) || errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return &NetworkError{Err: err}
+	}
+	return err
+}
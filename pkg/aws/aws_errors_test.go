@@ -0,0 +1,68 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func TestClassifyError(t *testing.T) {
+	apiErr := func(code, msg string) error {
+		return &smithy.GenericAPIError{Code: code, Message: msg}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want any
+	}{
+		{"bad code", apiErr("AccessDenied", "MultiFactorAuthentication failed with invalid MFA one time pass code."), new(*InvalidMFACodeError)},
+		{"wrong device", apiErr("AccessDenied", "MultiFactorAuthentication failed, unable to validate MFA code. Please verify your MFA serial number is valid and associated with this user."), new(*MFANotEnabledError)},
+		{"denied", apiErr("AccessDenied", "User: arn:aws:iam::123456789012:user/alice is not authorized to perform: sts:AssumeRole"), new(*AccessDeniedError)},
+		{"deleted key", apiErr("InvalidClientTokenId", "The security token included in the request is invalid."), new(*InvalidCredentialsError)},
+		{"wrong secret", apiErr("SignatureDoesNotMatch", "The request signature we calculated does not match"), new(*InvalidCredentialsError)},
+		{"expired", apiErr("ExpiredToken", "The security token included in the request is expired"), new(*InvalidCredentialsError)},
+		{"duration", apiErr("ValidationError", "1 validation error detected: Value '60' at 'durationSeconds' failed to satisfy constraint"), new(*InvalidDurationError)},
+		{"role duration", apiErr("ValidationError", "The requested DurationSeconds exceeds the MaxSessionDuration set for this role."), new(*InvalidDurationError)},
+		{"throttled", apiErr("Throttling", "Rate exceeded"), new(*ThrottlingError)},
+		{"network", &smithyhttp.RequestSendError{Err: errors.New("dial tcp: lookup sts.amazonaws.com: no such host")}, new(*NetworkError)},
+		{"timeout", fmt.Errorf("operation error: %w", context.DeadlineExceeded), new(*NetworkError)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("failed to get session token: %w", ClassifyError(tt.err))
+			if !errors.As(err, tt.want) {
+				t.Errorf("Expected %T, got %#v", tt.want, errors.Unwrap(err))
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Expected the original error to stay in the chain")
+			}
+			if err.Error() != "failed to get session token: "+tt.err.Error() {
+				t.Errorf("Expected the message to be unchanged, got %q", err.Error())
+			}
+		})
+	}
+
+	other := apiErr("ValidationError", "1 validation error detected: Value at 'roleArn' failed")
+	if got := ClassifyError(other); got != other {
+		t.Errorf("Expected unknown errors to be returned unchanged, got %#v", got)
+	}
+	if ClassifyError(nil) != nil {
+		t.Errorf("Expected nil for nil")
+	}
+}
+
+func TestGetSessionToken_TypedError(t *testing.T) {
+	client := &mockSTSGetSessionTokenClient{Err: &smithy.GenericAPIError{Code: "AccessDenied", Message: "MultiFactorAuthentication failed with invalid MFA one time pass code."}}
+	_, err := GetSessionToken(context.Background(), client, "arn:aws:iam::123456789012:mfa/user", "000000", 3600)
+	var codeErr *InvalidMFACodeError
+	if !errors.As(err, &codeErr) {
+		t.Fatalf("Expected InvalidMFACodeError, got %v", err)
+	}
+	if !IsInvalidMFACode(err) {
+		t.Errorf("Expected IsInvalidMFACode to recognize the typed error")
+	}
+}
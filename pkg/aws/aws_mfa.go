@@ -0,0 +1,170 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+// VirtualMFADevice holds the details of a newly created virtual MFA device.
+type VirtualMFADevice struct {
+	SerialNumber string
+	Seed         string
+	QRCodePNG    []byte
+}
+
+// IAMMFAEnrollClient defines the subset of the AWS IAM client's methods needed to enroll a virtual MFA device.
+type IAMMFAEnrollClient interface {
+	CreateVirtualMFADevice(ctx context.Context, params *iam.CreateVirtualMFADeviceInput, optFns ...func(*iam.Options)) (*iam.CreateVirtualMFADeviceOutput, error)
+	EnableMFADevice(ctx context.Context, params *iam.EnableMFADeviceInput, optFns ...func(*iam.Options)) (*iam.EnableMFADeviceOutput, error)
+	DeleteVirtualMFADevice(ctx context.Context, params *iam.DeleteVirtualMFADeviceInput, optFns ...func(*iam.Options)) (*iam.DeleteVirtualMFADeviceOutput, error)
+}
+
+// CreateVirtualMFADevice calls AWS IAM's CreateVirtualMFADevice API and returns the new device's serial number and seed.
+func CreateVirtualMFADevice(ctx context.Context, client IAMMFAEnrollClient, deviceName string) (*VirtualMFADevice, error) {
+	result, err := retryUnsent(ctx, client, func(ctx context.Context) (*iam.CreateVirtualMFADeviceOutput, error) {
+		return client.CreateVirtualMFADevice(ctx, &iam.CreateVirtualMFADeviceInput{
+			VirtualMFADeviceName: aws.String(deviceName),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create virtual MFA device: %w", ClassifyError(err))
+	}
+	if result.VirtualMFADevice == nil {
+		return nil, fmt.Errorf("no virtual MFA device returned")
+	}
+	device := result.VirtualMFADevice
+	return &VirtualMFADevice{
+		SerialNumber: aws.ToString(device.SerialNumber),
+		Seed:         string(device.Base32StringSeed),
+		QRCodePNG:    device.QRCodePNG,
+	}, nil
+}
+
+// EnableMFADevice associates the MFA device with the IAM user using two consecutive codes.
+func EnableMFADevice(ctx context.Context, client IAMMFAEnrollClient, userName, serialNumber, code1, code2 string) error {
+	_, err := retryUnsent(ctx, client, func(ctx context.Context) (*iam.EnableMFADeviceOutput, error) {
+		return client.EnableMFADevice(ctx, &iam.EnableMFADeviceInput{
+			UserName:            aws.String(userName),
+			SerialNumber:        aws.String(serialNumber),
+			AuthenticationCode1: aws.String(code1),
+			AuthenticationCode2: aws.String(code2),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable MFA device: %w", ClassifyError(err))
+	}
+	return nil
+}
+
+// DeleteVirtualMFADevice deletes an unassigned virtual MFA device, so that a device
+// with the same name can be created again.
+func DeleteVirtualMFADevice(ctx context.Context, client IAMMFAEnrollClient, serialNumber string) error {
+	_, err := retryUnsent(ctx, client, func(ctx context.Context) (*iam.DeleteVirtualMFADeviceOutput, error) {
+		return client.DeleteVirtualMFADevice(ctx, &iam.DeleteVirtualMFADeviceInput{
+			SerialNumber: aws.String(serialNumber),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete virtual MFA device %s: %w", serialNumber, ClassifyError(err))
+	}
+	return nil
+}
+
+// IAMListMFADevicesClient defines the subset of the AWS IAM client's methods needed to look up MFA devices.
+type IAMListMFADevicesClient interface {
+	ListMFADevices(ctx context.Context, params *iam.ListMFADevicesInput, optFns ...func(*iam.Options)) (*iam.ListMFADevicesOutput, error)
+}
+
+// MFA device types as reported by ListMFADevices. IAM gives virtual devices and
+// hardware TOTP tokens assigned through the console the same kind of ARN, so the
+// two share a type.
+const (
+	MFADeviceTypeTOTP     = "virtual/TOTP"
+	MFADeviceTypeHardware = "hardware"
+	MFADeviceTypePasskey  = "passkey"
+)
+
+// MFADevice describes an MFA device registered to an IAM user.
+type MFADevice struct {
+	SerialNumber string
+	Type         string
+	EnableDate   time.Time
+}
+
+// SupportsSessionToken reports whether the device can be used with GetSessionToken.
+// FIDO security keys and passkeys cannot produce the codes STS expects.
+func (d MFADevice) SupportsSessionToken() bool {
+	return d.Type != MFADeviceTypePasskey
+}
+
+// mfaDeviceType derives the device type from its serial number. FIDO devices have
+// a ":u2f/" ARN, other TOTP devices an ":mfa/" ARN, and legacy hardware tokens a
+// plain serial.
+func mfaDeviceType(serialNumber string) string {
+	switch {
+	case strings.HasPrefix(serialNumber, "arn:") && strings.Contains(serialNumber, ":u2f/"):
+		return MFADeviceTypePasskey
+	case strings.HasPrefix(serialNumber, "arn:"):
+		return MFADeviceTypeTOTP
+	default:
+		return MFADeviceTypeHardware
+	}
+}
+
+// ListMFADevices returns all MFA devices registered to the IAM user, following pagination.
+func ListMFADevices(ctx context.Context, client IAMListMFADevicesClient, userName string) ([]MFADevice, error) {
+	paginator := iam.NewListMFADevicesPaginator(client, &iam.ListMFADevicesInput{
+		UserName: aws.String(userName),
+	})
+	var devices []MFADevice
+	for paginator.HasMorePages() {
+		page, err := retry(ctx, client, func(ctx context.Context) (*iam.ListMFADevicesOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list MFA devices for user %s: %w", userName, ClassifyError(err))
+		}
+		for _, device := range page.MFADevices {
+			serial := aws.ToString(device.SerialNumber)
+			devices = append(devices, MFADevice{
+				SerialNumber: serial,
+				Type:         mfaDeviceType(serial),
+				EnableDate:   aws.ToTime(device.EnableDate),
+			})
+		}
+	}
+	return devices, nil
+}
+
+// IAMMFAResyncClient defines the subset of the AWS IAM client's methods needed to resynchronize an MFA device.
+type IAMMFAResyncClient interface {
+	ResyncMFADevice(ctx context.Context, params *iam.ResyncMFADeviceInput, optFns ...func(*iam.Options)) (*iam.ResyncMFADeviceOutput, error)
+}
+
+// ResyncMFADevice calls AWS IAM's ResyncMFADevice API with two consecutive codes from the device.
+func ResyncMFADevice(ctx context.Context, client IAMMFAResyncClient, userName, serialNumber, code1, code2 string) error {
+	_, err := retryUnsent(ctx, client, func(ctx context.Context) (*iam.ResyncMFADeviceOutput, error) {
+		return client.ResyncMFADevice(ctx, &iam.ResyncMFADeviceInput{
+			UserName:            aws.String(userName),
+			SerialNumber:        aws.String(serialNumber),
+			AuthenticationCode1: aws.String(code1),
+			AuthenticationCode2: aws.String(code2),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resync MFA device: %w", ClassifyError(err))
+	}
+	return nil
+}
+
+// IsInvalidMFACode reports whether err is STS rejecting the MFA one time pass code.
+func IsInvalidMFACode(err error) bool {
+	var codeErr *InvalidMFACodeError
+	return errors.As(ClassifyError(err), &codeErr)
+}
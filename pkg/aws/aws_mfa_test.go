@@ -0,0 +1,182 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/smithy-go"
+)
+
+// mockIAMMFAClient is a fake IAM client recording the MFA calls made against it.
+type mockIAMMFAClient struct {
+	EnableErr error
+	Enabled   *iam.EnableMFADeviceInput
+	Deleted   []string
+	Resynced  *iam.ResyncMFADeviceInput
+	Devices   []string
+}
+
+func (m *mockIAMMFAClient) CreateVirtualMFADevice(ctx context.Context, input *iam.CreateVirtualMFADeviceInput, optFns ...func(*iam.Options)) (*iam.CreateVirtualMFADeviceOutput, error) {
+	return &iam.CreateVirtualMFADeviceOutput{
+		VirtualMFADevice: &types.VirtualMFADevice{
+			SerialNumber:     aws.String("arn:aws:iam::123456789012:mfa/" + aws.ToString(input.VirtualMFADeviceName)),
+			Base32StringSeed: []byte("JBSWY3DPEHPK3PXP"),
+			QRCodePNG:        []byte("png"),
+		},
+	}, nil
+}
+
+func (m *mockIAMMFAClient) EnableMFADevice(ctx context.Context, input *iam.EnableMFADeviceInput, optFns ...func(*iam.Options)) (*iam.EnableMFADeviceOutput, error) {
+	if m.EnableErr != nil {
+		return nil, m.EnableErr
+	}
+	m.Enabled = input
+	return &iam.EnableMFADeviceOutput{}, nil
+}
+
+func (m *mockIAMMFAClient) DeleteVirtualMFADevice(ctx context.Context, input *iam.DeleteVirtualMFADeviceInput, optFns ...func(*iam.Options)) (*iam.DeleteVirtualMFADeviceOutput, error) {
+	m.Deleted = append(m.Deleted, aws.ToString(input.SerialNumber))
+	return &iam.DeleteVirtualMFADeviceOutput{}, nil
+}
+
+func TestCreateAndEnableVirtualMFADevice(t *testing.T) {
+	ctx := context.Background()
+	mockClient := &mockIAMMFAClient{}
+
+	device, err := CreateVirtualMFADevice(ctx, mockClient, "alice")
+	if err != nil {
+		t.Fatalf("CreateVirtualMFADevice returned error: %v", err)
+	}
+	if device.SerialNumber != "arn:aws:iam::123456789012:mfa/alice" {
+		t.Errorf("Unexpected serial number %s", device.SerialNumber)
+	}
+	if device.Seed != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Unexpected seed %s", device.Seed)
+	}
+
+	if err := EnableMFADevice(ctx, mockClient, "alice", device.SerialNumber, "123456", "654321"); err != nil {
+		t.Fatalf("EnableMFADevice returned error: %v", err)
+	}
+	if mockClient.Enabled == nil || aws.ToString(mockClient.Enabled.AuthenticationCode2) != "654321" {
+		t.Errorf("EnableMFADevice was not called with the expected codes: %+v", mockClient.Enabled)
+	}
+	if len(mockClient.Deleted) != 0 {
+		t.Errorf("Expected no devices to be deleted, got %v", mockClient.Deleted)
+	}
+}
+
+func TestEnableMFADevice_Failure(t *testing.T) {
+	mockClient := &mockIAMMFAClient{EnableErr: errors.New("InvalidAuthenticationCode")}
+	serial := "arn:aws:iam::123456789012:mfa/alice"
+
+	err := EnableMFADevice(context.Background(), mockClient, "alice", serial, "111111", "222222")
+	if err == nil || !strings.Contains(err.Error(), "InvalidAuthenticationCode") {
+		t.Fatalf("Expected enable failure, got %v", err)
+	}
+	if len(mockClient.Deleted) != 0 {
+		t.Errorf("Expected the device to be left for the caller to clean up, got deletions %v", mockClient.Deleted)
+	}
+}
+
+func TestDeleteVirtualMFADevice(t *testing.T) {
+	mockClient := &mockIAMMFAClient{}
+	serial := "arn:aws:iam::123456789012:mfa/alice"
+
+	if err := DeleteVirtualMFADevice(context.Background(), mockClient, serial); err != nil {
+		t.Fatalf("DeleteVirtualMFADevice returned error: %v", err)
+	}
+	if len(mockClient.Deleted) != 1 || mockClient.Deleted[0] != serial {
+		t.Errorf("Expected device %s to be deleted, got %v", serial, mockClient.Deleted)
+	}
+}
+
+func (m *mockIAMMFAClient) ResyncMFADevice(ctx context.Context, input *iam.ResyncMFADeviceInput, optFns ...func(*iam.Options)) (*iam.ResyncMFADeviceOutput, error) {
+	m.Resynced = input
+	return &iam.ResyncMFADeviceOutput{}, nil
+}
+
+// ListMFADevices returns the devices two per page to exercise pagination.
+func (m *mockIAMMFAClient) ListMFADevices(ctx context.Context, input *iam.ListMFADevicesInput, optFns ...func(*iam.Options)) (*iam.ListMFADevicesOutput, error) {
+	start := 0
+	if input.Marker != nil {
+		start, _ = strconv.Atoi(*input.Marker)
+	}
+	end := min(start+2, len(m.Devices))
+	out := &iam.ListMFADevicesOutput{}
+	for _, serial := range m.Devices[start:end] {
+		out.MFADevices = append(out.MFADevices, types.MFADevice{
+			SerialNumber: aws.String(serial),
+			UserName:     input.UserName,
+			EnableDate:   aws.Time(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		})
+	}
+	if end < len(m.Devices) {
+		out.IsTruncated = true
+		out.Marker = aws.String(strconv.Itoa(end))
+	}
+	return out, nil
+}
+
+func TestListMFADevices(t *testing.T) {
+	mockClient := &mockIAMMFAClient{Devices: []string{
+		"arn:aws:iam::123456789012:mfa/phone",
+		"GAHT12345678",
+		"arn:aws:iam::123456789012:u2f/user/alice/yubikey-ABCD",
+	}}
+	devices, err := ListMFADevices(context.Background(), mockClient, "alice")
+	if err != nil {
+		t.Fatalf("ListMFADevices returned error: %v", err)
+	}
+	if len(devices) != 3 {
+		t.Fatalf("Expected 3 devices across pages, got %d", len(devices))
+	}
+	wantTypes := []string{MFADeviceTypeTOTP, MFADeviceTypeHardware, MFADeviceTypePasskey}
+	for i, device := range devices {
+		if device.Type != wantTypes[i] {
+			t.Errorf("Device %s: expected type %s, got %s", device.SerialNumber, wantTypes[i], device.Type)
+		}
+		if device.EnableDate.IsZero() {
+			t.Errorf("Device %s: expected enable date", device.SerialNumber)
+		}
+	}
+	if devices[2].SupportsSessionToken() {
+		t.Errorf("Expected passkey device not to support GetSessionToken")
+	}
+}
+
+func TestResyncMFADevice(t *testing.T) {
+	mockClient := &mockIAMMFAClient{}
+	serial := "arn:aws:iam::123456789012:mfa/alice"
+	if err := ResyncMFADevice(context.Background(), mockClient, "alice", serial, "111111", "222222"); err != nil {
+		t.Fatalf("ResyncMFADevice returned error: %v", err)
+	}
+	if mockClient.Resynced == nil || aws.ToString(mockClient.Resynced.SerialNumber) != serial {
+		t.Errorf("Unexpected ResyncMFADevice input: %+v", mockClient.Resynced)
+	}
+}
+
+func TestIsInvalidMFACode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"code mismatch", &smithy.GenericAPIError{Code: "AccessDenied", Message: "MultiFactorAuthentication failed with invalid MFA one time pass code."}, true},
+		{"wrapped", fmt.Errorf("failed to get session token: %w", &smithy.GenericAPIError{Code: "AccessDenied", Message: "MultiFactorAuthentication failed with invalid MFA one time pass code."}), true},
+		{"other access denied", &smithy.GenericAPIError{Code: "AccessDenied", Message: "User is not authorized"}, false},
+		{"plain error", errors.New("network error"), false},
+	}
+	for _, tt := range tests {
+		if got := IsInvalidMFACode(tt.err); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
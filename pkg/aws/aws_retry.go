@@ -0,0 +1,159 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// RetryPolicy controls how STS and IAM calls are retried after throttling and
+// network errors. Other failures, in particular a rejected MFA code, are never retried.
+// Calls that are not idempotent are retried only as described for IsRetryableUnsent.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// BaseDelay is the backoff before the second attempt; it doubles with each attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between attempts.
+	MaxDelay time.Duration
+	// Deadline bounds the total time spent across all attempts. Zero means no limit.
+	Deadline time.Duration
+	// Sleep waits between attempts; nil waits on a timer. Tests replace it.
+	Sleep func(ctx context.Context, d time.Duration) error
+}
+
+// DefaultRetryPolicy returns the policy applied to STS and IAM calls on clients that
+// do not carry their own.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Deadline:    30 * time.Second,
+	}
+}
+
+// RetryPolicyClient is implemented by clients that carry their own retry policy, such
+// as an SDK client wrapped in a struct with a RetryPolicy method.
+type RetryPolicyClient interface {
+	RetryPolicy() RetryPolicy
+}
+
+// retryPolicyFor returns the retry policy of client, or DefaultRetryPolicy.
+func retryPolicyFor(client any) RetryPolicy {
+	if c, ok := client.(RetryPolicyClient); ok {
+		return c.RetryPolicy()
+	}
+	return DefaultRetryPolicy()
+}
+
+// IsRetryable reports whether err is a throttling or network failure worth retrying.
+func IsRetryable(err error) bool {
+	var throttleErr *ThrottlingError
+	var netErr *NetworkError
+	err = ClassifyError(err)
+	return errors.As(err, &throttleErr) || errors.As(err, &netErr)
+}
+
+// IsRetryableUnsent reports whether err is throttling, or a network failure that
+// happened before the request left the machine (a DNS lookup or dial error). Calls that
+// are not idempotent, or that use up an MFA code, are only retried after these: any
+// other failure may have reached AWS, and a retry would hide the real outcome.
+func IsRetryableUnsent(err error) bool {
+	var throttleErr *ThrottlingError
+	if errors.As(ClassifyError(err), &throttleErr) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	return errors.As(err, &dnsErr) || (errors.As(err, &opErr) && opErr.Op == "dial")
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable, or the
+// attempts or deadline run out, sleeping with jittered exponential backoff in between.
+// It returns the last error from fn.
+func (p RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return p.do(ctx, IsRetryable, fn)
+}
+
+// DoUnsent is like Do, but only retries the errors accepted by IsRetryableUnsent.
+func (p RetryPolicy) DoUnsent(ctx context.Context, fn func(ctx context.Context) error) error {
+	return p.do(ctx, IsRetryableUnsent, fn)
+}
+
+// do calls fn as described for Do, retrying the errors for which retryable returns true.
+func (p RetryPolicy) do(ctx context.Context, retryable func(error) bool, fn func(ctx context.Context) error) error {
+	if p.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Deadline)
+		defer cancel()
+	}
+	sleep := p.Sleep
+	if sleep == nil {
+		sleep = sleepContext
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.MaxAttempts || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		delay := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+		slog.Debug("Retrying AWS call after transient failure", "attempt", attempt, "delay", delay, "err", err)
+		if sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns a random delay of up to BaseDelay*2^(attempt-1), capped at MaxDelay.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	limit := p.BaseDelay << (attempt - 1)
+	if limit <= 0 || (p.MaxDelay > 0 && limit > p.MaxDelay) {
+		limit = p.MaxDelay
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit) + 1
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retry calls fn under the retry policy of client and returns the result of the last attempt.
+func retry[T any](ctx context.Context, client any, fn func(ctx context.Context) (T, error)) (T, error) {
+	var out T
+	err := retryPolicyFor(client).Do(ctx, func(ctx context.Context) error {
+		var err error
+		out, err = fn(ctx)
+		return err
+	})
+	return out, err
+}
+
+// retryUnsent is like retry for calls that must not reach AWS twice; see IsRetryableUnsent.
+func retryUnsent[T any](ctx context.Context, client any, fn func(ctx context.Context) (T, error)) (T, error) {
+	var out T
+	err := retryPolicyFor(client).DoUnsent(ctx, func(ctx context.Context) error {
+		var err error
+		out, err = fn(ctx)
+		return err
+	})
+	return out, err
+}
@@ -0,0 +1,270 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// flakyClient fails its first Failures calls with Err, then succeeds. Its calls are
+// retried under Policy.
+type flakyClient struct {
+	Failures int
+	Err      error
+	Calls    int
+	Policy   RetryPolicy
+}
+
+func (f *flakyClient) RetryPolicy() RetryPolicy { return f.Policy }
+
+func (f *flakyClient) fail() error {
+	f.Calls++
+	if f.Calls <= f.Failures {
+		return f.Err
+	}
+	return nil
+}
+
+func (f *flakyClient) GetSessionToken(ctx context.Context, input *sts.GetSessionTokenInput, optFns ...func(*sts.Options)) (*sts.GetSessionTokenOutput, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return &sts.GetSessionTokenOutput{
+		Credentials: &types.Credentials{
+			AccessKeyId:     aws.String("newAccessKey"),
+			SecretAccessKey: aws.String("newSecretKey"),
+			SessionToken:    aws.String("newSessionToken"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		},
+	}, nil
+}
+
+func (f *flakyClient) ListMFADevices(ctx context.Context, input *iam.ListMFADevicesInput, optFns ...func(*iam.Options)) (*iam.ListMFADevicesOutput, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	// Two pages: the first without a marker, the second after it.
+	if input.Marker == nil {
+		return &iam.ListMFADevicesOutput{
+			MFADevices:  []iamTypes.MFADevice{{SerialNumber: aws.String("arn:aws:iam::123456789012:mfa/phone")}},
+			IsTruncated: true,
+			Marker:      aws.String("page2"),
+		}, nil
+	}
+	return &iam.ListMFADevicesOutput{
+		MFADevices: []iamTypes.MFADevice{{SerialNumber: aws.String("GAHT12345678")}},
+	}, nil
+}
+
+// recordSleeps makes p record its backoffs instead of waiting.
+func recordSleeps(p RetryPolicy) (RetryPolicy, *[]time.Duration) {
+	var slept []time.Duration
+	p.Sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	return p, &slept
+}
+
+var (
+	dialErr     = &smithyhttp.RequestSendError{Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("i/o timeout")}}
+	networkErr  = &smithyhttp.RequestSendError{Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}
+	throttleErr = &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}
+	badCodeErr  = &smithy.GenericAPIError{Code: "AccessDenied", Message: "MultiFactorAuthentication failed with invalid MFA one time pass code."}
+)
+
+func TestGetSessionToken_RetriesTransientFailures(t *testing.T) {
+	policy, slept := recordSleeps(RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Deadline: time.Minute})
+	client := &flakyClient{Failures: 2, Err: dialErr, Policy: policy}
+
+	creds, err := GetSessionToken(context.Background(), client, "arn:aws:iam::123456789012:mfa/user", "123456", 3600)
+	if err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if creds.SessionToken != "newSessionToken" || client.Calls != 3 {
+		t.Errorf("Expected 3 calls and new credentials, got %d calls, %+v", client.Calls, creds)
+	}
+	if len(*slept) != 2 {
+		t.Fatalf("Expected 2 backoffs, got %v", *slept)
+	}
+	for i, d := range *slept {
+		if limit := 100 * time.Millisecond << i; d <= 0 || d > limit {
+			t.Errorf("Backoff %d: expected a jittered delay in (0, %v], got %v", i, limit, d)
+		}
+	}
+}
+
+func TestGetSessionToken_NeverRetriesRejectedCode(t *testing.T) {
+	policy, slept := recordSleeps(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	client := &flakyClient{Failures: 1, Err: badCodeErr, Policy: policy}
+
+	_, err := GetSessionToken(context.Background(), client, "arn:aws:iam::123456789012:mfa/user", "000000", 3600)
+	if !IsInvalidMFACode(err) {
+		t.Fatalf("Expected the rejected code error, got %v", err)
+	}
+	if client.Calls != 1 || len(*slept) != 0 {
+		t.Errorf("Expected a single attempt, got %d calls and %d sleeps", client.Calls, len(*slept))
+	}
+}
+
+func TestGetSessionToken_NeverRetriesSentRequest(t *testing.T) {
+	// The connection broke after the request was written: AWS may have used the code.
+	policy, slept := recordSleeps(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	client := &flakyClient{Failures: 1, Err: networkErr, Policy: policy}
+
+	_, err := GetSessionToken(context.Background(), client, "arn:aws:iam::123456789012:mfa/user", "123456", 3600)
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) {
+		t.Fatalf("Expected the network error, got %v", err)
+	}
+	if client.Calls != 1 || len(*slept) != 0 {
+		t.Errorf("Expected a single attempt, got %d calls and %d sleeps", client.Calls, len(*slept))
+	}
+}
+
+func TestCreateVirtualMFADevice_NeverRetriesSentRequest(t *testing.T) {
+	client := &flakyMFAClient{flakyClient: flakyClient{Failures: 1, Err: networkErr, Policy: RetryPolicy{MaxAttempts: 3}}}
+
+	if _, err := CreateVirtualMFADevice(context.Background(), client, "alice"); err == nil {
+		t.Fatalf("Expected the network error to be returned")
+	}
+	if client.Calls != 1 {
+		t.Errorf("Expected a single attempt, got %d calls", client.Calls)
+	}
+
+	// A request that never left the machine is safe to send again.
+	client = &flakyMFAClient{flakyClient: flakyClient{Failures: 1, Err: dialErr, Policy: RetryPolicy{MaxAttempts: 3}}}
+	if _, err := CreateVirtualMFADevice(context.Background(), client, "alice"); err != nil {
+		t.Fatalf("Expected success after retrying a dial error, got %v", err)
+	}
+	if client.Calls != 2 {
+		t.Errorf("Expected 2 attempts, got %d calls", client.Calls)
+	}
+}
+
+func TestGetSessionToken_GivesUpAfterMaxAttempts(t *testing.T) {
+	client := &flakyClient{Failures: 10, Err: throttleErr, Policy: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}}
+
+	_, err := GetSessionToken(context.Background(), client, "arn:aws:iam::123456789012:mfa/user", "123456", 3600)
+	var throttled *ThrottlingError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("Expected the last throttling error, got %v", err)
+	}
+	if client.Calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", client.Calls)
+	}
+}
+
+func TestRetryPolicy_Deadline(t *testing.T) {
+	client := &flakyClient{Failures: 10, Err: dialErr, Policy: RetryPolicy{MaxAttempts: 10, BaseDelay: time.Hour, MaxDelay: time.Hour, Deadline: 50 * time.Millisecond}}
+
+	start := time.Now()
+	_, err := GetSessionToken(context.Background(), client, "arn:aws:iam::123456789012:mfa/user", "123456", 3600)
+	if err == nil {
+		t.Fatalf("Expected failure once the deadline leaves no room for a backoff")
+	}
+	if client.Calls != 1 {
+		t.Errorf("Expected no retry when the backoff would pass the deadline, got %d calls", client.Calls)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Retry ran past its deadline")
+	}
+}
+
+func TestRetryPolicy_RealSleepHonorsContext(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	calls := 0
+	err := p.Do(ctx, func(ctx context.Context) error {
+		calls++
+		return networkErr
+	})
+	if !errors.Is(err, networkErr) || calls != 1 {
+		t.Errorf("Expected the cancelled wait to end the retries, got %d calls and %v", calls, err)
+	}
+}
+
+func TestListMFADevices_RetriesPage(t *testing.T) {
+	client := &flakyClient{Failures: 2, Err: networkErr, Policy: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}}
+
+	devices, err := ListMFADevices(context.Background(), client, "alice")
+	if err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if len(devices) != 2 || devices[1].SerialNumber != "GAHT12345678" {
+		t.Errorf("Expected both pages, got %+v", devices)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{networkErr, true},
+		{dialErr, true},
+		{throttleErr, true},
+		{context.DeadlineExceeded, true},
+		{badCodeErr, false},
+		{&smithy.GenericAPIError{Code: "InvalidClientTokenId", Message: "invalid"}, false},
+		{errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v): expected %v, got %v", tt.err, tt.want, got)
+		}
+	}
+}
+
+// flakyMFAClient is a flakyClient for the virtual MFA device calls.
+type flakyMFAClient struct {
+	flakyClient
+}
+
+func (f *flakyMFAClient) CreateVirtualMFADevice(ctx context.Context, input *iam.CreateVirtualMFADeviceInput, optFns ...func(*iam.Options)) (*iam.CreateVirtualMFADeviceOutput, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return &iam.CreateVirtualMFADeviceOutput{VirtualMFADevice: &iamTypes.VirtualMFADevice{
+		SerialNumber:     aws.String("arn:aws:iam::123456789012:mfa/alice"),
+		Base32StringSeed: []byte("SEED"),
+	}}, nil
+}
+
+func (f *flakyMFAClient) EnableMFADevice(ctx context.Context, input *iam.EnableMFADeviceInput, optFns ...func(*iam.Options)) (*iam.EnableMFADeviceOutput, error) {
+	return &iam.EnableMFADeviceOutput{}, f.fail()
+}
+
+func (f *flakyMFAClient) DeleteVirtualMFADevice(ctx context.Context, input *iam.DeleteVirtualMFADeviceInput, optFns ...func(*iam.Options)) (*iam.DeleteVirtualMFADeviceOutput, error) {
+	return &iam.DeleteVirtualMFADeviceOutput{}, f.fail()
+}
+
+func TestIsRetryableUnsent(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{dialErr, true},
+		{&smithyhttp.RequestSendError{Err: &net.DNSError{Err: "no such host", Name: "sts.amazonaws.com"}}, true},
+		{throttleErr, true},
+		{networkErr, false},
+		{context.DeadlineExceeded, false},
+		{badCodeErr, false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryableUnsent(tt.err); got != tt.want {
+			t.Errorf("IsRetryableUnsent(%v): expected %v, got %v", tt.err, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// DefaultRoleSessionName is the role session name used for assumed roles.
+const DefaultRoleSessionName = "aws-otp-auth"
+
+// STSAssumeRoleClient defines the subset of the AWS STS client's methods needed to call AssumeRole.
+type STSAssumeRoleClient interface {
+	AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error)
+}
+
+// AssumeRole calls AWS STS's AssumeRole API for the given role. If mfaArn is set, the MFA
+// device and OTP code are passed along; otherwise the client's own credentials must
+// already satisfy any MFA condition (e.g. a session from GetSessionToken).
+func AssumeRole(ctx context.Context, client STSAssumeRoleClient, roleArn, mfaArn, tokenCode string, durationSeconds int32) (*SessionCredentials, error) {
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(roleArn),
+		RoleSessionName: aws.String(DefaultRoleSessionName),
+		DurationSeconds: aws.Int32(durationSeconds),
+	}
+	// A call with an OTP code is not resent once it may have reached AWS, since the code is used up.
+	send := retry[*sts.AssumeRoleOutput]
+	if mfaArn != "" {
+		input.SerialNumber = aws.String(mfaArn)
+		input.TokenCode = aws.String(tokenCode)
+		send = retryUnsent[*sts.AssumeRoleOutput]
+	}
+	result, err := send(ctx, client, func(ctx context.Context) (*sts.AssumeRoleOutput, error) {
+		return client.AssumeRole(ctx, input)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to assume role %s: %w", roleArn, ClassifyError(err))
+	}
+	if result.Credentials == nil {
+		return nil, fmt.Errorf("no credentials returned")
+	}
+	creds := result.Credentials
+	return &SessionCredentials{
+		AccessKeyID:     aws.ToString(creds.AccessKeyId),
+		SecretAccessKey: aws.ToString(creds.SecretAccessKey),
+		SessionToken:    aws.ToString(creds.SessionToken),
+		Expiration:      aws.ToTime(creds.Expiration),
+	}, nil
+}
+
+// RevokeSessionsPolicyName is the inline role policy that revokes older sessions, named as by the IAM console.
+const RevokeSessionsPolicyName = "AWSRevokeOlderSessions"
+
+// IAMRoleRevokeClient defines the subset of the AWS IAM client's methods needed to revoke role sessions.
+type IAMRoleRevokeClient interface {
+	PutRolePolicy(ctx context.Context, params *iam.PutRolePolicyInput, optFns ...func(*iam.Options)) (*iam.PutRolePolicyOutput, error)
+}
+
+// RevokeRoleSessions attaches an inline policy to the role that denies every action to
+// sessions of the role issued before the given time, whoever holds them. Sessions issued
+// later are unaffected. A previous revocation policy on the role is replaced.
+func RevokeRoleSessions(ctx context.Context, client IAMRoleRevokeClient, roleArn string, before time.Time) error {
+	roleName, err := roleNameFromArn(roleArn)
+	if err != nil {
+		return err
+	}
+	policy, err := json.Marshal(map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []map[string]any{{
+			"Effect":    "Deny",
+			"Action":    "*",
+			"Resource":  "*",
+			"Condition": map[string]any{"DateLessThan": map[string]string{"aws:TokenIssueTime": before.UTC().Format(time.RFC3339)}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = retry(ctx, client, func(ctx context.Context) (*iam.PutRolePolicyOutput, error) {
+		return client.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+			RoleName:       aws.String(roleName),
+			PolicyName:     aws.String(RevokeSessionsPolicyName),
+			PolicyDocument: aws.String(string(policy)),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions of role %s: %w", roleArn, ClassifyError(err))
+	}
+	return nil
+}
+
+// roleNameFromArn returns the name of the role in an IAM role ARN such as
+// arn:aws:iam::123456789012:role/path/Name.
+func roleNameFromArn(roleArn string) (string, error) {
+	parts := strings.SplitN(roleArn, ":", 6)
+	if len(parts) != 6 || parts[2] != "iam" || !strings.HasPrefix(parts[5], "role/") {
+		return "", fmt.Errorf("invalid role ARN %q", roleArn)
+	}
+	return parts[5][strings.LastIndex(parts[5], "/")+1:], nil
+}
@@ -0,0 +1,108 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+type mockSTSAssumeRoleClient struct {
+	Input *sts.AssumeRoleInput
+	Err   error
+}
+
+func (m *mockSTSAssumeRoleClient) AssumeRole(ctx context.Context, input *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	m.Input = input
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return &sts.AssumeRoleOutput{
+		Credentials: &types.Credentials{
+			AccessKeyId:     aws.String("roleAccessKey"),
+			SecretAccessKey: aws.String("roleSecretKey"),
+			SessionToken:    aws.String("roleSessionToken"),
+			Expiration:      aws.Time(time.Now().Add(1 * time.Hour)),
+		},
+	}, nil
+}
+
+func TestAssumeRole_WithMFA(t *testing.T) {
+	mockClient := &mockSTSAssumeRoleClient{}
+	creds, err := AssumeRole(context.Background(), mockClient, "arn:aws:iam::123456789012:role/Admin", "arn:aws:iam::123456789012:mfa/user", "123456", 3600)
+	if err != nil {
+		t.Fatalf("Expected success, got error: %v", err)
+	}
+	if creds.SessionToken != "roleSessionToken" {
+		t.Errorf("Expected SessionToken 'roleSessionToken', got '%s'", creds.SessionToken)
+	}
+	if aws.ToString(mockClient.Input.SerialNumber) != "arn:aws:iam::123456789012:mfa/user" || aws.ToString(mockClient.Input.TokenCode) != "123456" {
+		t.Errorf("Expected MFA details to be passed, got %+v", mockClient.Input)
+	}
+	if aws.ToString(mockClient.Input.RoleSessionName) != DefaultRoleSessionName {
+		t.Errorf("Unexpected role session name %s", aws.ToString(mockClient.Input.RoleSessionName))
+	}
+}
+
+func TestAssumeRole_WithoutMFA(t *testing.T) {
+	mockClient := &mockSTSAssumeRoleClient{}
+	if _, err := AssumeRole(context.Background(), mockClient, "arn:aws:iam::123456789012:role/Admin", "", "", 3600); err != nil {
+		t.Fatalf("Expected success, got error: %v", err)
+	}
+	if mockClient.Input.SerialNumber != nil || mockClient.Input.TokenCode != nil {
+		t.Errorf("Expected no MFA details, got %+v", mockClient.Input)
+	}
+}
+
+func TestAssumeRole_Failure(t *testing.T) {
+	mockClient := &mockSTSAssumeRoleClient{Err: errors.New("access denied")}
+	_, err := AssumeRole(context.Background(), mockClient, "arn:aws:iam::123456789012:role/Admin", "", "", 3600)
+	if err == nil || !strings.Contains(err.Error(), "failed to assume role") {
+		t.Errorf("Expected assume role error, got %v", err)
+	}
+}
+
+type mockIAMPutRolePolicyClient struct {
+	Input *iam.PutRolePolicyInput
+	Err   error
+}
+
+func (m *mockIAMPutRolePolicyClient) PutRolePolicy(ctx context.Context, input *iam.PutRolePolicyInput, optFns ...func(*iam.Options)) (*iam.PutRolePolicyOutput, error) {
+	m.Input = input
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func TestRevokeRoleSessions(t *testing.T) {
+	mockClient := &mockIAMPutRolePolicyClient{}
+	before := time.Date(2025, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+	if err := RevokeRoleSessions(context.Background(), mockClient, "arn:aws:iam::123456789012:role/team/Admin", before); err != nil {
+		t.Fatalf("Expected success, got error: %v", err)
+	}
+	if got := aws.ToString(mockClient.Input.RoleName); got != "Admin" {
+		t.Errorf("Expected role name Admin, got %s", got)
+	}
+	if got := aws.ToString(mockClient.Input.PolicyName); got != RevokeSessionsPolicyName {
+		t.Errorf("Expected policy name %s, got %s", RevokeSessionsPolicyName, got)
+	}
+	want := `{"Statement":[{"Action":"*","Condition":{"DateLessThan":{"aws:TokenIssueTime":"2025-03-01T11:30:00Z"}},"Effect":"Deny","Resource":"*"}],"Version":"2012-10-17"}`
+	if got := aws.ToString(mockClient.Input.PolicyDocument); got != want {
+		t.Errorf("Expected policy %s, got %s", want, got)
+	}
+
+	if err := RevokeRoleSessions(context.Background(), mockClient, "arn:aws:iam::123456789012:user/alice", before); err == nil {
+		t.Errorf("Expected an error for a non-role ARN")
+	}
+	mockClient.Err = errors.New("not authorized")
+	if err := RevokeRoleSessions(context.Background(), mockClient, "arn:aws:iam::123456789012:role/Admin", before); err == nil || !strings.Contains(err.Error(), "failed to revoke sessions") {
+		t.Errorf("Expected revoke error, got %v", err)
+	}
+}
@@ -30,9 +30,11 @@ func GetSessionToken(ctx context.Context, client STSGetSessionTokenClient, mfaAr
 		SerialNumber:    aws.String(mfaArn),
 		TokenCode:       aws.String(tokenCode),
 	}
-	result, err := client.GetSessionToken(ctx, input)
+	result, err := retryUnsent(ctx, client, func(ctx context.Context) (*sts.GetSessionTokenOutput, error) {
+		return client.GetSessionToken(ctx, input)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get session token: %w", err)
+		return nil, fmt.Errorf("failed to get session token: %w", ClassifyError(err))
 	}
 	if result.Credentials == nil {
 		return nil, fmt.Errorf("no credentials returned")
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Profile describes a named authentication setup.
+type Profile struct {
+	Source               string   `toml:"source"`
+	Target               string   `toml:"target"`
+	MFAArn               string   `toml:"mfa_arn"`
+	RoleArn              string   `toml:"role_arn"`
+	User                 string   `toml:"user"`
+	Duration             Duration `toml:"duration"`
+	OTPProvider          string   `toml:"otp_provider"`
+	Region               string   `toml:"region"`
+	Validate             bool     `toml:"validate"`
+	STSEndpoint          string   `toml:"sts_endpoint"`
+	UseFIPS              bool     `toml:"use_fips"`
+	STSRegionalEndpoints string   `toml:"sts_regional_endpoints"`
+	MinRemaining         string   `toml:"min_remaining"`
+	EndOfDay             string   `toml:"end_of_day"`
+	PreRefresh           string   `toml:"pre_refresh"`
+	PostRefresh          string   `toml:"post_refresh"`
+	HookTimeout          string   `toml:"hook_timeout"`
+	HookSecrets          bool     `toml:"hook_secrets"`
+	NotifyBefore         []string `toml:"notify_before"`
+	NotifyCommand        string   `toml:"notify_command"`
+	RefreshCommand       string   `toml:"refresh_command"`
+	MaxKeyAge            *int     `toml:"max_key_age"` // days; nil leaves the default, 0 disables the warning
+}
+
+// Config is the contents of the tool's configuration file.
+type Config struct {
+	// Default names the profile used when none is given on the command line.
+	Default  string             `toml:"default"`
+	Profiles map[string]Profile `toml:"profiles"`
+
+	// undecoded lists keys present in the file that are not part of the schema.
+	undecoded []string
+}
+
+// DefaultPath returns the location of the configuration file, ~/.config/aws-otp-auth/config.toml.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine user home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "aws-otp-auth", "config.toml"), nil
+}
+
+// Load reads the configuration file at path. A missing file yields an empty configuration.
+func Load(path string) (*Config, error) {
+	cfg := &Config{Profiles: map[string]Profile{}}
+	meta, err := toml.DecodeFile(path, cfg)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load config file: %w", err)
+	}
+	for _, key := range meta.Undecoded() {
+		cfg.undecoded = append(cfg.undecoded, key.String())
+	}
+	return cfg, nil
+}
+
+// Lookup returns the named profile, or the default profile if name is empty.
+// It returns false if no profile applies.
+func (c *Config) Lookup(name string) (Profile, bool, error) {
+	if name == "" {
+		if c.Default == "" {
+			return Profile{}, false, nil
+		}
+		name = c.Default
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, false, fmt.Errorf("profile %q not found in config file", name)
+	}
+	return profile, true, nil
+}
+
+// Validate checks the configuration and returns every problem found.
+// Names in reserved cannot be used for profiles, as they would be shadowed by subcommands.
+func (c *Config) Validate(reserved ...string) []error {
+	var errs []error
+	for _, key := range c.undecoded {
+		errs = append(errs, fmt.Errorf("unknown key %q", key))
+	}
+	if c.Default != "" {
+		if _, ok := c.Profiles[c.Default]; !ok {
+			errs = append(errs, fmt.Errorf("default profile %q is not defined", c.Default))
+		}
+	}
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := c.Profiles[name]
+		for _, r := range reserved {
+			if name == r {
+				errs = append(errs, fmt.Errorf("profile %q: name clashes with the %q subcommand", name, r))
+			}
+		}
+		if p.Source != "" && p.Source == p.Target {
+			errs = append(errs, fmt.Errorf("profile %q: source and target must differ", name))
+		}
+		if p.MFAArn != "" && strings.HasPrefix(p.MFAArn, "arn:") && !strings.Contains(p.MFAArn, ":mfa/") {
+			errs = append(errs, fmt.Errorf("profile %q: mfa_arn %q is not an MFA device ARN", name, p.MFAArn))
+		}
+		if p.RoleArn != "" && (!strings.HasPrefix(p.RoleArn, "arn:") || !strings.Contains(p.RoleArn, ":role/")) {
+			errs = append(errs, fmt.Errorf("profile %q: role_arn %q is not an IAM role ARN", name, p.RoleArn))
+		}
+		maxDuration := 129600
+		if p.RoleArn != "" {
+			maxDuration = 43200
+		}
+		if p.Duration != 0 && (p.Duration < 900 || int(p.Duration) > maxDuration) {
+			errs = append(errs, fmt.Errorf("profile %q: duration %d is outside 900-%d seconds", name, p.Duration, maxDuration))
+		}
+		switch p.OTPProvider {
+		case "", "prompt", "totp":
+		default:
+			errs = append(errs, fmt.Errorf("profile %q: unknown otp_provider %q", name, p.OTPProvider))
+		}
+		if p.STSEndpoint != "" {
+			if u, err := url.Parse(p.STSEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("profile %q: sts_endpoint %q is not an http or https URL", name, p.STSEndpoint))
+			}
+		}
+		switch p.STSRegionalEndpoints {
+		case "", "regional", "legacy":
+		default:
+			errs = append(errs, fmt.Errorf("profile %q: unknown sts_regional_endpoints %q", name, p.STSRegionalEndpoints))
+		}
+		if p.EndOfDay != "" {
+			if _, _, err := ParseEndOfDay(p.EndOfDay); err != nil {
+				errs = append(errs, fmt.Errorf("profile %q: %w", name, err))
+			}
+		}
+		if p.MinRemaining != "" {
+			if d, err := time.ParseDuration(p.MinRemaining); err != nil || d < 0 {
+				errs = append(errs, fmt.Errorf("profile %q: min_remaining %q is not a non-negative duration such as \"10m\"", name, p.MinRemaining))
+			}
+		}
+		if p.HookTimeout != "" {
+			if d, err := time.ParseDuration(p.HookTimeout); err != nil || d <= 0 {
+				errs = append(errs, fmt.Errorf("profile %q: hook_timeout %q is not a positive duration such as \"30s\"", name, p.HookTimeout))
+			}
+		}
+		for _, before := range p.NotifyBefore {
+			if d, err := time.ParseDuration(before); err != nil || d <= 0 {
+				errs = append(errs, fmt.Errorf("profile %q: notify_before %q is not a positive duration such as \"10m\"", name, before))
+			}
+		}
+		if p.MaxKeyAge != nil && *p.MaxKeyAge < 0 {
+			errs = append(errs, fmt.Errorf("profile %q: max_key_age %d is negative", name, *p.MaxKeyAge))
+		}
+		if p.HookSecrets && p.PostRefresh == "" {
+			errs = append(errs, fmt.Errorf("profile %q: hook_secrets is set but there is no post_refresh hook", name))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `default = "dev"
+
+[profiles.dev]
+source = "dev-long-term"
+target = "dev"
+mfa_arn = "arn:aws:iam::123456789012:mfa/alice"
+duration = 43200
+otp_provider = "totp"
+region = "eu-west-1"
+max_key_age = 0
+
+[profiles.prod-admin]
+source = "dev-long-term"
+target = "prod-admin"
+role_arn = "arn:aws:iam::210987654321:role/Admin"
+duration = "1h30m"
+end_of_day = "18:00"
+sts_endpoint = "https://sts.example.internal"
+use_fips = true
+sts_regional_endpoints = "legacy"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(cfg.Profiles) != 2 {
+		t.Fatalf("Expected 2 profiles, got %d", len(cfg.Profiles))
+	}
+
+	profile, ok, err := cfg.Lookup("")
+	if err != nil || !ok {
+		t.Fatalf("Expected default profile, got ok=%v err=%v", ok, err)
+	}
+	if profile.Source != "dev-long-term" || profile.Duration != 43200 || profile.Region != "eu-west-1" || profile.OTPProvider != "totp" || profile.MaxKeyAge == nil || *profile.MaxKeyAge != 0 {
+		t.Errorf("Unexpected default profile: %+v", profile)
+	}
+
+	profile, _, err = cfg.Lookup("prod-admin")
+	if err != nil || profile.RoleArn != "arn:aws:iam::210987654321:role/Admin" || profile.Duration != 5400 || profile.EndOfDay != "18:00" || profile.STSEndpoint != "https://sts.example.internal" || !profile.UseFIPS || profile.STSRegionalEndpoints != "legacy" {
+		t.Errorf("Unexpected prod-admin profile: %+v (err %v)", profile, err)
+	}
+	if _, _, err := cfg.Lookup("missing"); err == nil {
+		t.Errorf("Expected error for missing profile, got nil")
+	}
+	if errs := cfg.Validate("status"); len(errs) != 0 {
+		t.Errorf("Expected valid config, got %v", errs)
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.toml"))
+	if err != nil {
+		t.Fatalf("Expected no error for missing file, got %v", err)
+	}
+	if _, ok, err := cfg.Lookup(""); ok || err != nil {
+		t.Errorf("Expected no profile from empty config, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestLoad_Malformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[profiles.dev\nsource = "), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Errorf("Expected error for malformed config, got nil")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `default = "missing"
+
+[profiles.status]
+source = "a"
+
+[profiles.bad]
+source = "same"
+target = "same"
+mfa_arn = "arn:aws:iam::123456789012:user/alice"
+role_arn = "Admin"
+duration = 60
+otp_provider = "sms"
+sts_endpoint = "localhost:8080"
+sts_regional_endpoints = "global"
+min_remaining = "soon"
+end_of_day = "6pm"
+hook_timeout = "-1s"
+hook_secrets = true
+notify_before = ["30m", "0s"]
+max_key_age = -1
+colour = "blue"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	var msgs []string
+	for _, err := range cfg.Validate("status") {
+		msgs = append(msgs, err.Error())
+	}
+	all := strings.Join(msgs, "\n")
+	for _, want := range []string{
+		`unknown key "profiles.bad.colour"`,
+		`default profile "missing" is not defined`,
+		`"status": name clashes`,
+		"source and target must differ",
+		"is not an MFA device ARN",
+		"is not an IAM role ARN",
+		"duration 60 is outside",
+		`unknown otp_provider "sms"`,
+		`sts_endpoint "localhost:8080" is not an http or https URL`,
+		`unknown sts_regional_endpoints "global"`,
+		`min_remaining "soon" is not a non-negative duration`,
+		`invalid end of day "6pm"`,
+		`hook_timeout "-1s" is not a positive duration`,
+		"hook_secrets is set but there is no post_refresh hook",
+		`notify_before "0s" is not a positive duration`,
+		"max_key_age -1 is negative",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("Expected validation error containing %q, got:\n%s", want, all)
+		}
+	}
+}
+
+func TestValidate_RoleDuration(t *testing.T) {
+	cfg := &Config{Profiles: map[string]Profile{
+		"admin": {RoleArn: "arn:aws:iam::210987654321:role/Admin", Duration: 43201},
+		"dev":   {Duration: 129600},
+	}}
+	errs := cfg.Validate()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `profile "admin": duration 43201 is outside 900-43200 seconds`) {
+		t.Errorf("Expected the role duration to be rejected, got %v", errs)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"43200", 43200, false},
+		{"12h", 43200, false},
+		{"1h30m", 5400, false},
+		{"90s", 90, false},
+		{"1.5s", 0, true},
+		{"eight hours", 0, true},
+		{"2147483647", 2147483647, false},
+		{"4294996096", 0, true},
+		{"-3600", 0, true},
+		{"1000000h", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %d, %v; expected %d (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLoad_DurationOutOfRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[profiles.dev]\nduration = 4294996096\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("Expected out of range error, got %v", err)
+	}
+}
+
+func TestLoad_InvalidDuration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[profiles.dev]\nduration = \"all day\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "invalid duration") {
+		t.Errorf("Expected invalid duration error, got %v", err)
+	}
+}
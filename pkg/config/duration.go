@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Duration is a session duration in seconds. In the config file it is written as a
+// number of seconds or as a string with units, such as "12h".
+type Duration int
+
+// UnmarshalTOML implements toml.Unmarshaler.
+func (d *Duration) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case int64:
+		if v < 0 || v > math.MaxInt32 {
+			return fmt.Errorf("invalid duration %d: out of range", v)
+		}
+		*d = Duration(v)
+		return nil
+	case string:
+		seconds, err := ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(seconds)
+		return nil
+	}
+	return fmt.Errorf("duration must be a number of seconds or a string such as \"12h\", got %v", v)
+}
+
+// ParseDuration parses a number of seconds ("43200") or a duration with units
+// ("12h", "1h30m") into whole seconds. The result fits in an int32, as STS durations do.
+func ParseDuration(s string) (int, error) {
+	if seconds, err := strconv.ParseInt(s, 10, 32); err == nil {
+		if seconds < 0 {
+			return 0, fmt.Errorf("invalid duration %q: out of range", s)
+		}
+		return int(seconds), nil
+	} else if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("invalid duration %q: out of range", s)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: use seconds or units such as 12h", s)
+	}
+	if d%time.Second != 0 {
+		return 0, fmt.Errorf("invalid duration %q: must be a whole number of seconds", s)
+	}
+	if d < 0 || d/time.Second > math.MaxInt32 {
+		return 0, fmt.Errorf("invalid duration %q: out of range", s)
+	}
+	return int(d / time.Second), nil
+}
+
+// ParseEndOfDay parses a local time of day written as HH:MM.
+func ParseEndOfDay(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid end of day %q: use HH:MM, such as 18:00", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// New returns a logger writing records at level and above to w in the given
+// format ("text" or "json"), with secrets scrubbed by r.
+func New(w io.Writer, level slog.Level, format string, r *Redactor) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch format {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unsupported log format %q", format)
+	}
+	return slog.New(NewRedactingHandler(h, r)), nil
+}
+
+// redactingHandler scrubs secrets from records before passing them on.
+type redactingHandler struct {
+	next slog.Handler
+	r    *Redactor
+}
+
+// NewRedactingHandler wraps next so that the message and every attribute of a record
+// are scrubbed by r, and attributes with sensitive keys are replaced entirely.
+func NewRedactingHandler(next slog.Handler, r *Redactor) slog.Handler {
+	return &redactingHandler{next: next, r: r}
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	scrubbed := slog.NewRecord(record.Time, record.Level, h.r.String(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		scrubbed.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, scrubbed)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	scrubbed := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		scrubbed[i] = h.redactAttr(a)
+	}
+	return &redactingHandler{next: h.next.WithAttrs(scrubbed), r: h.r}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name), r: h.r}
+}
+
+// redactAttr scrubs one attribute. Values other than strings, groups and plain
+// numbers, booleans and times are rendered to strings so their contents can be scrubbed.
+func (h *redactingHandler) redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if IsSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.r.String(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		scrubbed := make([]any, len(group))
+		for i, ga := range group {
+			scrubbed[i] = h.redactAttr(ga)
+		}
+		return slog.Group(a.Key, scrubbed...)
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, h.r.String(err.Error()))
+		}
+		return slog.String(a.Key, h.r.String(fmt.Sprintf("%+v", a.Value.Any())))
+	}
+	return a
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// fakeSecrets are the credential values used by the pkg/aws tests.
+var fakeSecrets = []string{
+	"newAccessKey", "newSecretKey", "newSessionToken",
+	"roleAccessKey", "roleSecretKey", "roleSessionToken",
+	"DUMMYSECRET", "DUMMYTOKEN", "LONGSECRET", "OLDSECRETKEY", "NEWSECRETKEY", "NEWSESSIONTOKEN",
+}
+
+type sessionCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+func TestRedactingHandler(t *testing.T) {
+	for _, format := range []string{"text", "json"} {
+		t.Run(format, func(t *testing.T) {
+			r := &Redactor{}
+			r.Add(fakeSecrets...)
+			r.Add("654321")
+			var buf bytes.Buffer
+			logger, err := New(&buf, slog.LevelDebug, format, r)
+			if err != nil {
+				t.Fatalf("New returned error: %v", err)
+			}
+
+			creds := sessionCredentials{AccessKeyID: "newAccessKey", SecretAccessKey: "newSecretKey", SessionToken: "newSessionToken"}
+			logger.Debug("obtained credentials newAccessKey", "creds", creds, "profile", "default")
+			logger.Info("otp read", "otp", "654321", "code", "654321")
+			logger.Warn("update failed", "err", errors.New("write DUMMYSECRET: permission denied"))
+			logger.With("session_token", "roleSessionToken").WithGroup("role").Info("assumed", "key", "roleAccessKey", "secret", "roleSecretKey")
+			logger.Error("failed", slog.Group("old", "token", "OLDSECRETKEY", "detail", fmt.Sprint("NEWSECRETKEY/NEWSESSIONTOKEN")))
+			logger.Info("legacy", "line", "aws_session_token = DUMMYTOKEN", "long", "LONGSECRET")
+
+			out := buf.String()
+			for _, secret := range append(fakeSecrets, "654321") {
+				if strings.Contains(out, secret) {
+					t.Errorf("Secret %q leaked into %s log output:\n%s", secret, format, out)
+				}
+			}
+			if !strings.Contains(out, "default") || !strings.Contains(out, "permission denied") {
+				t.Errorf("Expected non-secret values to be kept:\n%s", out)
+			}
+			if format == "json" {
+				for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+					if !json.Valid([]byte(line)) {
+						t.Errorf("Invalid JSON log line: %s", line)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRedactingHandler_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, slog.LevelWarn, "text", &Redactor{})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("Unexpected output for level warn:\n%s", buf.String())
+	}
+
+	if _, err := New(&buf, slog.LevelInfo, "xml", &Redactor{}); err == nil {
+		t.Errorf("Expected an unsupported format to be rejected")
+	}
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secret values in log output.
+const Redacted = "[REDACTED]"
+
+// minSecretLength is the shortest value Add registers, so that trivial strings are not scrubbed everywhere.
+const minSecretLength = 4
+
+// secretPatterns match secrets by shape even if they were never registered:
+// access key IDs, and the long base64 blobs used for session tokens.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:AKIA|ASIA|AROA|AIDA)[A-Z0-9]{12,}\b`),
+	regexp.MustCompile(`[A-Za-z0-9/+=]{100,}`),
+}
+
+// sensitiveKeys are substrings of attribute keys whose values are always redacted.
+// Keys are compared in lower case with "_" and "-" removed.
+var sensitiveKeys = []string{"secret", "token", "password", "otp", "accesskey", "credential"}
+
+// Redactor scrubs secrets from strings. Known secret values are registered with Add;
+// values that look like access key IDs or session tokens are scrubbed regardless.
+// A Redactor is safe for concurrent use.
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// Add registers secret values to be scrubbed. Empty and very short values are ignored.
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range secrets {
+		if len(s) < minSecretLength {
+			continue
+		}
+		r.secrets = append(r.secrets, s)
+	}
+	// Replace longer secrets first so one that contains another is scrubbed whole.
+	sort.SliceStable(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// String returns s with every registered secret and secret-shaped value replaced by Redacted.
+func (r *Redactor) String(s string) string {
+	r.mu.RLock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	r.mu.RUnlock()
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+// IsSensitiveKey reports whether values logged under key must never be shown.
+func IsSensitiveKey(key string) bool {
+	key = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	for _, k := range sensitiveKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Names of the freedesktop notification service.
+const (
+	notificationsName  = "org.freedesktop.Notifications"
+	notificationsPath  = dbus.ObjectPath("/org/freedesktop/Notifications")
+	notificationsIface = "org.freedesktop.Notifications"
+)
+
+// busTimeout bounds each exchange with the bus, including the handshake, so that a bus
+// that never answers cannot block the caller.
+const busTimeout = 10 * time.Second
+
+// busObject is the part of a dbus.BusObject the notifier uses.
+type busObject interface {
+	CallWithContext(ctx context.Context, method string, flags dbus.Flags, args ...any) *dbus.Call
+}
+
+// DBusNotifier shows notifications through the freedesktop notification service.
+type DBusNotifier struct {
+	appName string
+	conn    *dbus.Conn
+	obj     busObject
+
+	mu       sync.Mutex
+	handlers map[uint32]func(string)
+}
+
+// DialSessionBus connects to the notification service on the user's session bus.
+func DialSessionBus(ctx context.Context, appName string) (*DBusNotifier, error) {
+	conn, err := dbus.SessionBusPrivateNoAutoStartup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the session bus: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, busTimeout)
+	defer cancel()
+
+	// The handshake does not take a context; closing the connection interrupts it.
+	done := make(chan error, 1)
+	go func() {
+		if err := conn.Auth(nil); err != nil {
+			done <- err
+			return
+		}
+		done <- conn.Hello()
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		conn.Close()
+		<-done
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to the session bus: %w", err)
+	}
+	return NewDBusNotifier(ctx, conn, appName)
+}
+
+// NewDBusNotifier uses conn, an authenticated connection to a message bus, to show
+// notifications. The notifier owns conn and closes it when it is closed.
+func NewDBusNotifier(ctx context.Context, conn *dbus.Conn, appName string) (*DBusNotifier, error) {
+	ctx, cancel := context.WithTimeout(ctx, busTimeout)
+	defer cancel()
+	if err := conn.AddMatchSignalContext(ctx, dbus.WithMatchInterface(notificationsIface), dbus.WithMatchObjectPath(notificationsPath)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to subscribe to notification actions: %w", err)
+	}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	n := newDBusNotifier(conn.Object(notificationsName, notificationsPath), signals, appName)
+	n.conn = conn
+	return n, nil
+}
+
+// newDBusNotifier returns a notifier that calls obj and handles the signals received on signals.
+func newDBusNotifier(obj busObject, signals <-chan *dbus.Signal, appName string) *DBusNotifier {
+	n := &DBusNotifier{appName: appName, obj: obj, handlers: map[uint32]func(string){}}
+	go func() {
+		for s := range signals {
+			n.signal(s)
+		}
+	}()
+	return n
+}
+
+// Notify shows n. onAction is called when the user picks one of its actions.
+func (n *DBusNotifier) Notify(ctx context.Context, notification Notification, onAction func(key string)) error {
+	actions := make([]string, 0, 2*len(notification.Actions))
+	for _, a := range notification.Actions {
+		actions = append(actions, a.Key, a.Label)
+	}
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(byte(notification.Urgency))}
+	ctx, cancel := context.WithTimeout(ctx, busTimeout)
+	defer cancel()
+	var id uint32
+	err := n.obj.CallWithContext(ctx, notificationsIface+".Notify", 0,
+		n.appName, uint32(0), "", notification.Summary, notification.Body, actions, hints, int32(-1)).Store(&id)
+	if err != nil {
+		return fmt.Errorf("failed to show notification: %w", err)
+	}
+	if onAction != nil {
+		n.mu.Lock()
+		n.handlers[id] = onAction
+		n.mu.Unlock()
+	}
+	return nil
+}
+
+// signal handles the notification service's ActionInvoked and NotificationClosed signals.
+func (n *DBusNotifier) signal(s *dbus.Signal) {
+	if s.Path != notificationsPath || len(s.Body) < 2 {
+		return
+	}
+	id, _ := s.Body[0].(uint32)
+	n.mu.Lock()
+	handler := n.handlers[id]
+	if s.Name == notificationsIface+".NotificationClosed" {
+		delete(n.handlers, id)
+	}
+	n.mu.Unlock()
+	// The handler runs apart from the signal loop so that it can call Notify.
+	if key, ok := s.Body[1].(string); ok && s.Name == notificationsIface+".ActionInvoked" && handler != nil {
+		go handler(key)
+	}
+}
+
+// Close closes the connection to the bus.
+func (n *DBusNotifier) Close() error {
+	if n.conn == nil {
+		return nil
+	}
+	return n.conn.Close()
+}
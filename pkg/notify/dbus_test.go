@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeNotifications is the notification service's bus object, recording the calls made to it.
+type fakeNotifications struct {
+	mu     sync.Mutex
+	calls  []*dbus.Call
+	nextID uint32
+	err    error
+}
+
+func (f *fakeNotifications) CallWithContext(ctx context.Context, method string, flags dbus.Flags, args ...any) *dbus.Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	call := &dbus.Call{Method: method, Args: args, Body: []any{f.nextID}, Err: f.err}
+	f.calls = append(f.calls, call)
+	return call
+}
+
+func TestDBusNotifier(t *testing.T) {
+	service := &fakeNotifications{nextID: 41}
+	signals := make(chan *dbus.Signal)
+	defer close(signals)
+	n := newDBusNotifier(service, signals, "aws-otp-auth")
+
+	picked := make(chan string, 1)
+	notification := Notification{
+		Summary: "AWS session dev expires in 10m",
+		Body:    "Refresh it now?",
+		Urgency: UrgencyCritical,
+		Actions: []Action{{Key: "refresh", Label: "Refresh"}},
+	}
+	if err := n.Notify(context.Background(), notification, func(key string) { picked <- key }); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+
+	if len(service.calls) != 1 || service.calls[0].Method != "org.freedesktop.Notifications.Notify" {
+		t.Fatalf("Expected a Notify call, got %+v", service.calls)
+	}
+	want := []any{"aws-otp-auth", uint32(0), "", notification.Summary, notification.Body, []string{"refresh", "Refresh"},
+		map[string]dbus.Variant{"urgency": dbus.MakeVariant(byte(UrgencyCritical))}, int32(-1)}
+	if !reflect.DeepEqual(service.calls[0].Args, want) {
+		t.Errorf("Expected Notify arguments %#v, got %#v", want, service.calls[0].Args)
+	}
+
+	// Signals for other notifications are ignored.
+	invoke := func(id uint32, key string) {
+		signals <- &dbus.Signal{Path: notificationsPath, Name: notificationsIface + ".ActionInvoked", Body: []any{id, key}}
+	}
+	invoke(7, "refresh")
+	invoke(42, "refresh")
+	select {
+	case key := <-picked:
+		if key != "refresh" {
+			t.Errorf("Expected the refresh action, got %q", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the action")
+	}
+
+	// Once the notification is closed, its actions are forgotten.
+	signals <- &dbus.Signal{Path: notificationsPath, Name: notificationsIface + ".NotificationClosed", Body: []any{uint32(42), uint32(2)}}
+	invoke(42, "refresh")
+	select {
+	case key := <-picked:
+		t.Errorf("Expected no action after the notification closed, got %q", key)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDBusNotifier_Error(t *testing.T) {
+	service := &fakeNotifications{err: errors.New("org.freedesktop.DBus.Error.ServiceUnknown")}
+	signals := make(chan *dbus.Signal)
+	defer close(signals)
+	n := newDBusNotifier(service, signals, "aws-otp-auth")
+	if err := n.Notify(context.Background(), Notification{Summary: "test"}, nil); err == nil {
+		t.Errorf("Expected an error when the service fails")
+	}
+}
+
+func TestDialSessionBus_Unresponsive(t *testing.T) {
+	// A bus that accepts the connection but never answers the handshake.
+	socket := filepath.Join(t.TempDir(), "bus")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+socket)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := DialSessionBus(ctx, "aws-otp-auth"); err == nil {
+		t.Fatalf("Expected an error from a bus that never answers")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected DialSessionBus to give up with the context, took %s", elapsed)
+	}
+}
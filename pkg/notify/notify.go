@@ -0,0 +1,131 @@
+// Package notify shows desktop notifications, through the freedesktop notification
+// service on the D-Bus session bus or through a user-supplied command.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Urgency is the urgency level of a notification.
+type Urgency byte
+
+// Urgency levels defined by the freedesktop notification specification.
+const (
+	UrgencyLow      Urgency = 0
+	UrgencyNormal   Urgency = 1
+	UrgencyCritical Urgency = 2
+)
+
+// String returns the name used by notify-send.
+func (u Urgency) String() string {
+	switch u {
+	case UrgencyLow:
+		return "low"
+	case UrgencyCritical:
+		return "critical"
+	}
+	return "normal"
+}
+
+// Action is a button offered with a notification.
+type Action struct {
+	Key   string
+	Label string
+}
+
+// Notification is a message shown to the user.
+type Notification struct {
+	Summary string
+	Body    string
+	Urgency Urgency
+	Actions []Action
+}
+
+// Notifier shows notifications.
+type Notifier interface {
+	// Notify shows n and returns without waiting for the user. If the user picks one of
+	// its actions, onAction is called with the action's key from another goroutine.
+	Notify(ctx context.Context, n Notification, onAction func(key string)) error
+	Close() error
+}
+
+// CommandNotifier shows notifications by running a shell command, such as
+// notify-send. The command receives the notification in AWS_OTP_AUTH_NOTIFY_SUMMARY,
+// AWS_OTP_AUTH_NOTIFY_BODY, AWS_OTP_AUTH_NOTIFY_URGENCY and AWS_OTP_AUTH_NOTIFY_ACTIONS
+// (key=label pairs separated by spaces). If it prints the key of an action, that
+// action is taken to have been picked.
+type CommandNotifier struct {
+	// Command is run with sh -c.
+	Command string
+	// Env is the command's environment, to which the notification is added.
+	Env []string
+	// Stderr receives the command's error output.
+	Stderr io.Writer
+	// Timeout bounds how long the command may run, including while it waits for the user.
+	Timeout time.Duration
+
+	wg sync.WaitGroup
+}
+
+// Notify starts the command and returns. A command that fails to start is reported as
+// an error; one that fails later is reported to Stderr.
+func (c *CommandNotifier) Notify(ctx context.Context, n Notification, onAction func(key string)) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = time.Hour
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	actions := make([]string, 0, len(n.Actions))
+	for _, a := range n.Actions {
+		actions = append(actions, a.Key+"="+a.Label)
+	}
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Env = append(append([]string{}, c.Env...),
+		"AWS_OTP_AUTH_NOTIFY_SUMMARY="+n.Summary,
+		"AWS_OTP_AUTH_NOTIFY_BODY="+n.Body,
+		"AWS_OTP_AUTH_NOTIFY_URGENCY="+n.Urgency.String(),
+		"AWS_OTP_AUTH_NOTIFY_ACTIONS="+strings.Join(actions, " "),
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = c.Stderr
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("failed to run notification command: %w", err)
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer cancel()
+		if err := cmd.Wait(); err != nil {
+			if c.Stderr != nil {
+				fmt.Fprintf(c.Stderr, "Warning: notification command failed: %v\n", err)
+			}
+			return
+		}
+		picked := strings.TrimSpace(stdout.String())
+		for _, a := range n.Actions {
+			if picked == a.Key && onAction != nil {
+				onAction(a.Key)
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Close waits for running commands to finish.
+func (c *CommandNotifier) Close() error {
+	c.wg.Wait()
+	return nil
+}
@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandNotifier(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "env")
+	c := &CommandNotifier{Command: "env > " + envFile + "; echo refresh", Env: []string{"PATH=" + os.Getenv("PATH")}}
+
+	picked := make(chan string, 1)
+	n := Notification{Summary: "Session expires", Body: "in 10m", Urgency: UrgencyCritical, Actions: []Action{{Key: "refresh", Label: "Refresh now"}}}
+	if err := c.Notify(context.Background(), n, func(key string) { picked <- key }); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	c.Close()
+
+	select {
+	case key := <-picked:
+		if key != "refresh" {
+			t.Errorf("Expected the refresh action, got %q", key)
+		}
+	default:
+		t.Errorf("Expected the action printed by the command to be picked")
+	}
+	data, err := os.ReadFile(envFile)
+	if err != nil {
+		t.Fatalf("Failed to read the command's environment: %v", err)
+	}
+	for _, want := range []string{"AWS_OTP_AUTH_NOTIFY_SUMMARY=Session expires", "AWS_OTP_AUTH_NOTIFY_BODY=in 10m", "AWS_OTP_AUTH_NOTIFY_URGENCY=critical", "AWS_OTP_AUTH_NOTIFY_ACTIONS=refresh=Refresh now"} {
+		if !strings.Contains(string(data), want+"\n") {
+			t.Errorf("Expected %s in the command's environment:\n%s", want, data)
+		}
+	}
+}
+
+func TestCommandNotifier_Failure(t *testing.T) {
+	var stderr bytes.Buffer
+	c := &CommandNotifier{Command: "echo refresh; sleep 10", Stderr: &stderr, Timeout: 100 * time.Millisecond}
+	called := false
+	if err := c.Notify(context.Background(), Notification{Actions: []Action{{Key: "refresh", Label: "Refresh"}}}, func(string) { called = true }); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	c.Close()
+	if called {
+		t.Errorf("Expected no action from a command that was killed")
+	}
+	if !strings.Contains(stderr.String(), "notification command failed") {
+		t.Errorf("Expected the failure on stderr, got %q", stderr.String())
+	}
+}
@@ -9,7 +9,7 @@ import (
 )
 
 // GetOTP returns the provided OTP if non-empty.
-// Otherwise, it prompts the user with "Enter OTP:" and reads input from the given reader.
+// Otherwise, it prompts the user with "Enter OTP:" on stderr and reads input from the given reader.
 func GetOTP(providedOTP string, inReader io.Reader) (string, error) {
 	if providedOTP != "" {
 		return providedOTP, nil
@@ -17,7 +17,7 @@ func GetOTP(providedOTP string, inReader io.Reader) (string, error) {
 	if inReader == nil {
 		inReader = os.Stdin
 	}
-	fmt.Print("Enter OTP: ")
+	fmt.Fprint(os.Stderr, "Enter OTP: ")
 	reader := bufio.NewReader(inReader)
 	otp, err := reader.ReadString('\n')
 	if err != nil {
@@ -0,0 +1,103 @@
+package otp
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"strings"
+)
+
+// qrQuietZone is the number of light modules drawn around the rendered code.
+const qrQuietZone = 2
+
+// RenderQRCode converts a QR code PNG (such as the one returned by IAM's
+// CreateVirtualMFADevice) into a block-character rendering for the terminal.
+// Light modules are drawn with the foreground colour, which suits the usual
+// light-on-dark terminal and is accepted by authenticator apps.
+func RenderQRCode(pngData []byte) (string, error) {
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode QR code image: %w", err)
+	}
+	modules, err := decodeQRModules(img)
+	if err != nil {
+		return "", err
+	}
+
+	size := len(modules)
+	light := func(row, col int) bool {
+		row -= qrQuietZone
+		col -= qrQuietZone
+		if row < 0 || col < 0 || row >= size || col >= size {
+			return true
+		}
+		return !modules[row][col]
+	}
+
+	var b strings.Builder
+	total := size + 2*qrQuietZone
+	for row := 0; row < total; row += 2 {
+		for col := 0; col < total; col++ {
+			top, bottom := light(row, col), light(row+1, col)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// decodeQRModules samples a rendered QR code image back into its module grid,
+// where true marks a dark module. The module size is derived from the width of
+// the top-left finder pattern, which is always seven modules wide.
+func decodeQRModules(img image.Image) ([][]bool, error) {
+	bounds := img.Bounds()
+	dark := func(x, y int) bool {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return (r+g+b)/3 < 0x8000
+	}
+
+	minX, minY, maxX, maxY := bounds.Max.X, bounds.Max.Y, bounds.Min.X-1, bounds.Min.Y-1
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if dark(x, y) {
+				minX, minY = min(minX, x), min(minY, y)
+				maxX, maxY = max(maxX, x), max(maxY, y)
+			}
+		}
+	}
+	if maxX < minX {
+		return nil, fmt.Errorf("QR code image is blank")
+	}
+
+	finder := 0
+	for x := minX; x <= maxX && dark(x, minY); x++ {
+		finder++
+	}
+	moduleSize := float64(finder) / 7
+	size := int(math.Round(float64(maxX-minX+1) / moduleSize))
+	if moduleSize < 1 || size < 21 {
+		return nil, fmt.Errorf("QR code image is not recognisable")
+	}
+
+	modules := make([][]bool, size)
+	for row := range modules {
+		modules[row] = make([]bool, size)
+		for col := range modules[row] {
+			x := minX + int((float64(col)+0.5)*moduleSize)
+			y := minY + int((float64(row)+0.5)*moduleSize)
+			modules[row][col] = dark(x, y)
+		}
+	}
+	return modules, nil
+}
@@ -0,0 +1,107 @@
+package otp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// testQRMatrix builds a 21x21 module grid with the three finder patterns and a
+// deterministic data area.
+func testQRMatrix() [][]bool {
+	const size = 21
+	m := make([][]bool, size)
+	for row := range m {
+		m[row] = make([]bool, size)
+		for col := range m[row] {
+			m[row][col] = (row*7+col*3)%5 == 0
+		}
+	}
+	finder := func(r0, c0 int) {
+		for r := 0; r < 7; r++ {
+			for c := 0; c < 7; c++ {
+				ring := r == 0 || r == 6 || c == 0 || c == 6
+				core := r >= 2 && r <= 4 && c >= 2 && c <= 4
+				m[r0+r][c0+c] = ring || core
+			}
+		}
+	}
+	finder(0, 0)
+	finder(0, size-7)
+	finder(size-7, 0)
+	return m
+}
+
+// renderTestPNG scales the module grid into a PNG with a quiet zone, like IAM does.
+func renderTestPNG(t *testing.T, m [][]bool, scale, border int) []byte {
+	size := len(m)*scale + 2*border
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	for row := range m {
+		for col := range m[row] {
+			if !m[row][col] {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray(border+col*scale+dx, border+row*scale+dy, color.Gray{Y: 0})
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeQRModules(t *testing.T) {
+	want := testQRMatrix()
+	data := renderTestPNG(t, want, 5, 12)
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode test PNG: %v", err)
+	}
+
+	got, err := decodeQRModules(img)
+	if err != nil {
+		t.Fatalf("decodeQRModules returned error: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d modules per side, got %d", len(want), len(got))
+	}
+	for row := range want {
+		for col := range want[row] {
+			if got[row][col] != want[row][col] {
+				t.Fatalf("Module (%d,%d) mismatch: expected %v, got %v", row, col, want[row][col], got[row][col])
+			}
+		}
+	}
+}
+
+func TestRenderQRCode(t *testing.T) {
+	out, err := RenderQRCode(renderTestPNG(t, testQRMatrix(), 4, 8))
+	if err != nil {
+		t.Fatalf("RenderQRCode returned error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	// 21 modules plus a quiet zone of two on each side, two module rows per line.
+	if len(lines) != 13 {
+		t.Errorf("Expected 13 lines, got %d", len(lines))
+	}
+	if strings.Count(lines[0], "█") != 25 {
+		t.Errorf("Expected first line to be all quiet zone, got %q", lines[0])
+	}
+
+	if _, err := RenderQRCode([]byte("not a png")); err == nil {
+		t.Errorf("Expected error for invalid PNG, got nil")
+	}
+}
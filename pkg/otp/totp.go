@@ -0,0 +1,94 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// totpPeriod is the time step used by AWS virtual MFA devices.
+const totpPeriod = 30 * time.Second
+
+// GenerateTOTP returns the six digit RFC 6238 code for the base32 encoded secret at the given time.
+func GenerateTOTP(secret string, t time.Time) (string, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.TrimSpace(secret), "="))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(totpPeriod/time.Second)))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", code%1000000), nil
+}
+
+// OTPAuthURI returns the otpauth:// URI authenticator apps use to import a TOTP secret.
+func OTPAuthURI(issuer, accountName, secret string) string {
+	label := url.PathEscape(issuer + ":" + accountName)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// ValidateSecretName reports an error if name cannot name a stored TOTP secret: it must
+// not be empty or contain path separators or "..", so that it stays inside the secret directory.
+func ValidateSecretName(name string) error {
+	if name == "" || name == "." || strings.Contains(name, "..") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid TOTP secret name %q: it must not be empty or contain path separators or \"..\"", name)
+	}
+	return nil
+}
+
+// secretPath returns the location of the stored TOTP secret for the given name.
+func secretPath(name string) (string, error) {
+	if err := ValidateSecretName(name); err != nil {
+		return "", err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine user home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "aws-otp-auth", "totp", name), nil
+}
+
+// SaveTOTPSecret stores the TOTP secret under the given name, readable only by the current user.
+func SaveTOTPSecret(name, secret string) error {
+	path, err := secretPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create TOTP secret directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to save TOTP secret: %w", err)
+	}
+	return nil
+}
+
+// LoadTOTPSecret reads the TOTP secret stored under the given name.
+func LoadTOTPSecret(name string) (string, error) {
+	path, err := secretPath(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read TOTP secret for %s: %w", name, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
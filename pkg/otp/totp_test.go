@@ -0,0 +1,91 @@
+package otp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateTOTP(t *testing.T) {
+	// Test vectors from RFC 6238 (SHA1), truncated to six digits.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := GenerateTOTP(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("GenerateTOTP returned error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("GenerateTOTP at %d: expected %s, got %s", tt.unix, tt.want, got)
+		}
+	}
+
+	if _, err := GenerateTOTP("not base32!", time.Now()); err == nil {
+		t.Errorf("Expected error for invalid secret, got nil")
+	}
+}
+
+func TestOTPAuthURI(t *testing.T) {
+	uri := OTPAuthURI("AWS", "alice@123456789012", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/AWS:alice@123456789012?") {
+		t.Errorf("Unexpected URI prefix: %s", uri)
+	}
+	if !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") || !strings.Contains(uri, "issuer=AWS") {
+		t.Errorf("URI missing parameters: %s", uri)
+	}
+}
+
+func TestSaveAndLoadTOTPSecret(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+
+	if err := SaveTOTPSecret("default-long-term", "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatalf("SaveTOTPSecret returned error: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(tempDir, ".config", "aws-otp-auth", "totp", "default-long-term"))
+	if err != nil {
+		t.Fatalf("Secret file was not created: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected secret file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	secret, err := LoadTOTPSecret("default-long-term")
+	if err != nil {
+		t.Fatalf("LoadTOTPSecret returned error: %v", err)
+	}
+	if secret != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Expected stored secret, got %s", secret)
+	}
+
+	if _, err := LoadTOTPSecret("missing"); err == nil {
+		t.Errorf("Expected error for missing secret, got nil")
+	}
+}
+
+func TestSaveTOTPSecret_InvalidName(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+
+	for _, name := range []string{"", ".", "..", "../../.ssh/x", "a/b", `a\b`, "dev..prod"} {
+		if err := SaveTOTPSecret(name, "JBSWY3DPEHPK3PXP"); err == nil {
+			t.Errorf("Expected error for secret name %q", name)
+		}
+		if _, err := LoadTOTPSecret(name); err == nil {
+			t.Errorf("Expected error loading secret name %q", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, ".ssh")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing written outside the secret directory")
+	}
+}